@@ -0,0 +1,275 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/annotations.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/25/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the annotations of moves (BM, DO, IT, TE),
+ *	and of positions (GB, GW, DM, UC, HO and V): typed getters and setters
+ *	for each node, which keep the exclusions of FF[4], and finding the
+ *	nodes of a GameTree by their annotations.
+ */
+
+package sgf
+
+import (
+	"strconv"
+
+	"github.com/Ken1JF/ah"
+)
+
+// MoveAnnotation is the annotation of a move.
+type MoveAnnotation uint8
+
+const (
+	MoveNotAnnotated MoveAnnotation = iota
+	BadMove                         // BM
+	Doubtful                        // DO
+	Interesting                     // IT
+	Tesuji                          // TE
+)
+
+var MoveAnnotationNames = []string{"none", "bad move", "doubtful", "interesting", "tesuji"}
+
+// moveAnnotationProps are the properties of each MoveAnnotation.
+var moveAnnotationProps = []PropertyDefIdx{UnknownPropIdx, BM_idx, DO_idx, IT_idx, TE_idx}
+
+// PositionAnnotation is the judgement of a position.
+type PositionAnnotation uint8
+
+const (
+	PositionNotAnnotated PositionAnnotation = iota
+	GoodForBlack                            // GB
+	GoodForWhite                            // GW
+	EvenPosition                            // DM
+	UnclearPosition                         // UC
+)
+
+var PositionAnnotationNames = []string{"none", "good for Black", "good for White", "even", "unclear"}
+
+// positionAnnotationProps are the properties of each PositionAnnotation.
+var positionAnnotationProps = []PropertyDefIdx{UnknownPropIdx, GB_idx, GW_idx, DM_idx, UC_idx}
+
+// doubleValue returns the emphasis of a Double value: 2 for "2", and 1 otherwise.
+func doubleValue(str string) int {
+	if str == "2" {
+		return 2
+	}
+	return 1
+}
+
+// annotationOf returns the first annotation of node n among props, and its emphasis.
+func (gamT *GameTree) annotationOf(n TreeNodeIdx, props []PropertyDefIdx) (idx int, emph int) {
+	for i, pt := range props {
+		if i == 0 {
+			continue
+		}
+		if pIdx := gamT.findProp(n, pt); pIdx != nilPropIdx {
+			if gamT.propertyValues[pIdx].ValType == None {
+				return i, 1
+			}
+			return i, doubleValue(string(gamT.propertyValues[pIdx].StrValue))
+		}
+	}
+	return 0, 0
+}
+
+// MoveQuality returns the annotation of the move at node n, and its emphasis:
+// 1 for normal, or 2 for emphasized. DO and IT have no emphasis, and return 1.
+// MoveNotAnnotated, and 0, are returned if the move is not annotated.
+func (gamT *GameTree) MoveQuality(n TreeNodeIdx) (MoveAnnotation, int) {
+	i, emph := gamT.annotationOf(n, moveAnnotationProps)
+	return MoveAnnotation(i), emph
+}
+
+// PositionJudgement returns the judgement of the position at node n, and its emphasis:
+// 1 for normal, or 2 for emphasized.
+// PositionNotAnnotated, and 0, are returned if the position is not annotated.
+func (gamT *GameTree) PositionJudgement(n TreeNodeIdx) (PositionAnnotation, int) {
+	i, emph := gamT.annotationOf(n, positionAnnotationProps)
+	return PositionAnnotation(i), emph
+}
+
+// Hotspot returns the emphasis of HO at node n: 1 or 2, or 0 if the node is not a hotspot.
+func (gamT *GameTree) Hotspot(n TreeNodeIdx) int {
+	if pIdx := gamT.findProp(n, HO_idx); pIdx != nilPropIdx {
+		return doubleValue(string(gamT.propertyValues[pIdx].StrValue))
+	}
+	return 0
+}
+
+// Value returns the estimated score of node n, from V, and true if it is set.
+func (gamT *GameTree) Value(n TreeNodeIdx) (float64, bool) {
+	pIdx := gamT.findProp(n, V_idx)
+	if pIdx == nilPropIdx {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(string(gamT.propertyValues[pIdx].StrValue), 64)
+	return v, err == nil
+}
+
+// hasMove returns true if node n has a B or W property.
+func (gamT *GameTree) hasMove(n TreeNodeIdx) bool {
+	switch gamT.treeNodes[n].TNodType {
+	case BlackMoveNode, WhiteMoveNode:
+		return true
+	}
+	return gamT.findProp(n, B_idx) != nilPropIdx || gamT.findProp(n, W_idx) != nilPropIdx
+}
+
+// setAnnotation replaces the annotation of node n among props with the one at index idx.
+// Index 0 removes the annotation.
+func (gamT *GameTree) setAnnotation(n TreeNodeIdx, props []PropertyDefIdx, idx int, emph int, double bool) (err ah.ErrorList) {
+	for i, pt := range props {
+		if i > 0 {
+			gamT.removeProps(n, pt)
+		}
+	}
+	if idx == 0 {
+		return err
+	}
+	var pv PropertyValue
+	pv.PropType = props[idx]
+	pv.NextProp = nilPropIdx
+	if double {
+		pv.StrValue = []byte(strconv.Itoa(emph))
+		pv.ValType = Double
+	} else {
+		pv.ValType = None
+	}
+	return gamT.AddAProp(n, pv)
+}
+
+// checkEmphasis returns an error if emph is not 1 or 2.
+func checkEmphasis(what string, emph int) (err ah.ErrorList) {
+	if emph != 1 && emph != 2 {
+		err.Add(ah.NoPos, what+": emphasis "+strconv.Itoa(emph)+" not 1 or 2")
+	}
+	return err
+}
+
+// SetMoveQuality sets the annotation of the move at node n, replacing any other,
+// since BM, DO, IT and TE exclude each other. emph is 1 for normal, or 2 for emphasized,
+// and is ignored for DO and IT. MoveNotAnnotated removes the annotation.
+// An error is returned if n has no move, or emph is not 1 or 2.
+func (gamT *GameTree) SetMoveQuality(n TreeNodeIdx, q MoveAnnotation, emph int) (err ah.ErrorList) {
+	if q != MoveNotAnnotated && !gamT.hasMove(n) {
+		err.Add(ah.NoPos, "SetMoveQuality: node "+strconv.Itoa(int(n))+" has no move")
+		return err
+	}
+	double := q == BadMove || q == Tesuji
+	if double {
+		if err = checkEmphasis("SetMoveQuality", emph); len(err) != 0 {
+			return err
+		}
+	}
+	return gamT.setAnnotation(n, moveAnnotationProps, int(q), emph, double)
+}
+
+// SetPositionJudgement sets the judgement of the position at node n, replacing any other,
+// since GB, GW, DM and UC exclude each other. emph is 1 for normal, or 2 for emphasized.
+// PositionNotAnnotated removes the judgement.
+func (gamT *GameTree) SetPositionJudgement(n TreeNodeIdx, j PositionAnnotation, emph int) (err ah.ErrorList) {
+	if j != PositionNotAnnotated {
+		if err = checkEmphasis("SetPositionJudgement", emph); len(err) != 0 {
+			return err
+		}
+	}
+	return gamT.setAnnotation(n, positionAnnotationProps, int(j), emph, true)
+}
+
+// SetHotspot sets HO at node n, with emphasis 1 or 2. Emphasis 0 removes it.
+func (gamT *GameTree) SetHotspot(n TreeNodeIdx, emph int) (err ah.ErrorList) {
+	gamT.removeProps(n, HO_idx)
+	if emph == 0 {
+		return err
+	}
+	if err = checkEmphasis("SetHotspot", emph); len(err) != 0 {
+		return err
+	}
+	var pv PropertyValue
+	pv.PropType = HO_idx
+	pv.StrValue = []byte(strconv.Itoa(emph))
+	pv.ValType = Double
+	pv.NextProp = nilPropIdx
+	return gamT.AddAProp(n, pv)
+}
+
+// SetValue sets V, the estimated score, at node n: positive is good for Black.
+func (gamT *GameTree) SetValue(n TreeNodeIdx, v float64) (err ah.ErrorList) {
+	gamT.removeProps(n, V_idx)
+	var pv PropertyValue
+	pv.PropType = V_idx
+	pv.StrValue = []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	pv.ValType = Real
+	pv.NextProp = nilPropIdx
+	return gamT.AddAProp(n, pv)
+}
+
+// CheckAnnotations returns an error for each breach of the exclusions of FF[4] at node n:
+// more than one of BM, DO, IT and TE, a move annotation without a move,
+// and more than one of GB, GW, DM and UC.
+func (gamT *GameTree) CheckAnnotations(n TreeNodeIdx) (err ah.ErrorList) {
+	count := func(props []PropertyDefIdx) (k int) {
+		for _, pt := range props[1:] {
+			if gamT.findProp(n, pt) != nilPropIdx {
+				k += 1
+			}
+		}
+		return k
+	}
+	node := "node " + strconv.Itoa(int(n)) + ": "
+	if k := count(moveAnnotationProps); k > 1 {
+		err.Add(ah.NoPos, node+"more than one of BM, DO, IT and TE")
+	} else if k == 1 && !gamT.hasMove(n) {
+		err.Add(ah.NoPos, node+"move annotation without a move")
+	}
+	if count(positionAnnotationProps) > 1 {
+		err.Add(ah.NoPos, node+"more than one of GB, GW, DM and UC")
+	}
+	return err
+}
+
+// An Annotation is the annotation of one node.
+//	Emphasis is 1 for normal, or 2 for emphasized, and 0 if not annotated.
+type Annotation struct {
+	Node             TreeNodeIdx
+	Move             MoveAnnotation
+	MoveEmphasis     int
+	Position         PositionAnnotation
+	PositionEmphasis int
+	Hotspot          int
+	Value            float64
+	ValueSet         bool
+}
+
+// GetAnnotation returns the annotation of node n.
+func (gamT *GameTree) GetAnnotation(n TreeNodeIdx) (a Annotation) {
+	a.Node = n
+	a.Move, a.MoveEmphasis = gamT.MoveQuality(n)
+	a.Position, a.PositionEmphasis = gamT.PositionJudgement(n)
+	a.Hotspot = gamT.Hotspot(n)
+	a.Value, a.ValueSet = gamT.Value(n)
+	return a
+}
+
+// Annotated returns true if the node has any annotation.
+func (a Annotation) Annotated() bool {
+	return a.Move != MoveNotAnnotated || a.Position != PositionNotAnnotated || a.Hotspot != 0 || a.ValueSet
+}
+
+// FindAnnotations returns the annotations of the nodes of gamT, in node order,
+// for which match returns true. A nil match returns all the annotated nodes.
+func (gamT *GameTree) FindAnnotations(match func(a Annotation) bool) (found []Annotation) {
+	for n := range gamT.treeNodes {
+		a := gamT.GetAnnotation(TreeNodeIdx(n))
+		if !a.Annotated() {
+			continue
+		}
+		if match == nil || match(a) {
+			found = append(found, a)
+		}
+	}
+	return found
+}
@@ -0,0 +1,291 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/batch.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/10/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file parses many SGF files at once, from a directory, a glob pattern,
+ *	a .zip file, or a .tar.gz file, using a pool of workers.
+ */
+
+package sgf
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/Ken1JF/ah"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchOptions control ParseBatch.
+//	Workers is the number of files parsed at once, 0 means runtime.NumCPU().
+//	Mode and MoveLimit are passed to each parser.
+//	Stats, if not nil, collects the data base statistics of all the files.
+//	Rules, if not RulesFromRU, are used to check the moves, with ParserPlay.
+type BatchOptions struct {
+	Workers   int
+	Mode      ParserMode
+	MoveLimit int
+	Stats     *DBStatistics
+	Rules     RuleSet
+}
+
+// A FileDiagnostic holds the errors and warnings of one file,
+// and the illegal moves found with ParserPlay.
+type FileDiagnostic struct {
+	File     string
+	Errors   ah.ErrorList
+	Warnings ah.ErrorList
+	Moves    []MoveDiagnostic
+}
+
+// A BatchGame is a file parsed by ParseBatch.
+type BatchGame struct {
+	File   string
+	Parser *Parser
+}
+
+// A BatchResult is the result of ParseBatch.
+//	Games holds the files without errors, in order of their names,
+//	when ParseBatch is not given a callback.
+//	Diagnostics holds the files with errors or warnings, in order of their names.
+type BatchResult struct {
+	Files       int // number of files parsed
+	Failed      int // number of files with errors
+	Games       []BatchGame
+	Diagnostics []FileDiagnostic
+}
+
+// A BatchFunc is called by ParseBatch for each file parsed without errors.
+// It is called from several goroutines at once, and must be safe for that.
+// If it returns an error, ParseBatch stops, and returns that error.
+type BatchFunc func(file string, prsr *Parser) error
+
+// batchJob is a file to parse. If data is nil, the file is read by the worker.
+type batchJob struct {
+	name string
+	data []byte
+}
+
+// Type byDiagFile implements the sort.Interface based on the file name.
+type byDiagFile []FileDiagnostic
+
+func (bf byDiagFile) Len() int           { return len(bf) }
+func (bf byDiagFile) Swap(i, j int)      { bf[i], bf[j] = bf[j], bf[i] }
+func (bf byDiagFile) Less(i, j int) bool { return bf[i].File < bf[j].File }
+
+// Type byGameFile implements the sort.Interface based on the file name.
+type byGameFile []BatchGame
+
+func (bg byGameFile) Len() int           { return len(bg) }
+func (bg byGameFile) Swap(i, j int)      { bg[i], bg[j] = bg[j], bg[i] }
+func (bg byGameFile) Less(i, j int) bool { return bg[i].File < bg[j].File }
+
+// isSGFName returns true for a file name ending in .sgf, in any case.
+func isSGFName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".sgf")
+}
+
+// ParseBatch parses the SGF files of src, using a pool of workers. src is:
+//	a directory: the .sgf files in it, and its subdirectories
+//	a file ending in .zip: the .sgf files in the zip file
+//	a file ending in .tar.gz or .tgz: the .sgf files in the compressed tar file
+//	otherwise, a glob pattern, as used by filepath.Glob: all the matching files
+// The files in archives are named archive/member. A glob that matches no files is an error.
+//
+// If fn is not nil, it is called for each file without errors.
+// Otherwise, the files and their parsers are returned in res.Games.
+//
+// ParseBatch stops early if ctx is cancelled, or fn returns an error,
+// and returns that error, with the results of the files parsed so far.
+func ParseBatch(ctx context.Context, src string, opt BatchOptions, fn BatchFunc) (res BatchResult, err error) {
+	nWorkers := opt.Workers
+	if nWorkers <= 0 {
+		nWorkers = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex // protects res and err
+	setErr := func(e error) {
+		mu.Lock()
+		if err == nil {
+			err = e
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	jobs := make(chan batchJob, nWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < nWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue // drain the jobs
+				}
+				var data interface{}
+				if job.data != nil {
+					data = job.data
+				}
+				prsr, errL := parseSource(job.name, data, opt.Mode, opt.MoveLimit, opt.Stats, opt.Rules)
+				var warn ah.ErrorList
+				var movs []MoveDiagnostic
+				if prsr != nil {
+					warn = prsr.warnings
+					movs = prsr.moveDiags
+				}
+				mu.Lock()
+				res.Files += 1
+				if len(errL) != 0 {
+					res.Failed += 1
+				}
+				if len(errL) != 0 || len(warn) != 0 || len(movs) != 0 {
+					res.Diagnostics = append(res.Diagnostics, FileDiagnostic{File: job.name, Errors: errL, Warnings: warn, Moves: movs})
+				}
+				if len(errL) == 0 && fn == nil {
+					res.Games = append(res.Games, BatchGame{job.name, prsr})
+				}
+				mu.Unlock()
+				if len(errL) == 0 && fn != nil {
+					if e := fn(job.name, prsr); e != nil {
+						setErr(e)
+					}
+				}
+			}
+		}()
+	}
+
+	// send the jobs, until done or cancelled
+	send := func(job batchJob) bool {
+		select {
+		case jobs <- job:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	srcErr := batchSource(src, send)
+	close(jobs)
+	wg.Wait()
+	if srcErr != nil {
+		setErr(srcErr)
+	}
+	if ctx.Err() != nil {
+		setErr(ctx.Err())
+	}
+	sort.Sort(byDiagFile(res.Diagnostics))
+	sort.Sort(byGameFile(res.Games))
+	return res, err
+}
+
+// batchSource calls send for each file of src, until send returns false.
+// Files in a directory, or matched by a glob, are read by the workers.
+func batchSource(src string, send func(job batchJob) bool) error {
+	lower := strings.ToLower(src)
+	info, statErr := os.Stat(src)
+	switch {
+	case statErr == nil && info.IsDir():
+		stop := errors.New("stop")
+		err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !isSGFName(path) {
+				return nil
+			}
+			if !send(batchJob{name: path}) {
+				return stop
+			}
+			return nil
+		})
+		if err == stop {
+			err = nil
+		}
+		return err
+
+	case statErr == nil && strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.OpenReader(src)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || !isSGFName(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return errors.New(src + "/" + f.Name + ": " + err.Error())
+			}
+			data, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return errors.New(src + "/" + f.Name + ": " + err.Error())
+			}
+			if !send(batchJob{name: src + "/" + f.Name, data: data}) {
+				return nil
+			}
+		}
+		return nil
+
+	case statErr == nil && (strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")):
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return errors.New(src + ": " + err.Error())
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.New(src + ": " + err.Error())
+			}
+			if !hdr.FileInfo().Mode().IsRegular() || !isSGFName(hdr.Name) {
+				continue
+			}
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return errors.New(src + "/" + hdr.Name + ": " + err.Error())
+			}
+			if !send(batchJob{name: src + "/" + hdr.Name, data: data}) {
+				return nil
+			}
+		}
+	}
+
+	names, err := filepath.Glob(src)
+	if err != nil {
+		return errors.New(src + ": " + err.Error())
+	}
+	if len(names) == 0 {
+		return errors.New(src + ": no matching files")
+	}
+	for _, name := range names {
+		if !send(batchJob{name: name}) {
+			return nil
+		}
+	}
+	return nil
+}
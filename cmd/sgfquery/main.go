@@ -0,0 +1,105 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/cmd/sgfquery/main.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/3/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	sgfquery searches the game-info of an archive of SGF files.
+ *	The index is kept in a file, and updated when files are added, changed, or removed.
+ *
+ *	Usage:
+ *		sgfquery [-dir archive] [-index file] [-spec file] [-aliases file] [-q] query
+ *
+ *	For example:
+ *		sgfquery -dir ~/GoGoD 'PB="Go Seigen" AND DT>=1950 AND RE~"B+"'
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Ken1JF/sgf"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var dir = flag.String("dir", ".", "directory of the SGF archive")
+var indexFile = flag.String("index", "", "index file (default: sgfquery.idx in the archive directory)")
+var specFile = flag.String("spec", filepath.Join(os.Getenv("GOPATH"), "src/github.com/Ken1JF/sgf/sgf_properties_spec.txt"),
+	"SGF properties specification file")
+var quiet = flag.Bool("q", false, "only print the file names of matching games")
+var aliasFile = flag.String("aliases", "", "player aliases file, such as sgf_player_aliases.txt")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sgfquery [flags] query")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "query example: PB=\"Go Seigen\" AND DT>=1950 AND RE~\"B+\"")
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+	query := strings.Join(flag.Args(), " ")
+	var aliases *sgf.PlayerAliases
+	if *aliasFile != "" {
+		var err error
+		aliases, err = sgf.ReadPlayerAliases(*aliasFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	gq, errL := sgf.ParseGameQueryAliases(query, aliases)
+	if len(errL) != 0 {
+		fmt.Fprintln(os.Stderr, errL.Error())
+		os.Exit(2)
+	}
+	if sgf.SetupSGFProperties(*specFile, false, false) != 0 {
+		fmt.Fprintln(os.Stderr, "Can't read Specification file:", *specFile)
+		os.Exit(1)
+	}
+	if *indexFile == "" {
+		*indexFile = filepath.Join(*dir, "sgfquery.idx")
+	}
+
+	// read the index, or start a new one, and bring it up to date
+	idx, err := sgf.ReadGameInfoIndex(*indexFile)
+	if err != nil || idx.Dir != *dir {
+		idx = new(sgf.GameInfoIndex)
+		idx.Dir = *dir
+	}
+	added, changed, removed, errL := idx.Update()
+	for _, e := range errL {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	if err != nil || added+changed+removed > 0 {
+		if err := idx.WriteIndex(*indexFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	n := 0
+	for i := range idx.Games {
+		g := &idx.Games[i]
+		if !gq.Match(g) {
+			continue
+		}
+		n += 1
+		if *quiet {
+			fmt.Println(filepath.Join(idx.Dir, g.File))
+		} else {
+			fmt.Printf("%s: %s (%s) vs. %s (%s), %s, %s, %d moves\n", filepath.Join(idx.Dir, g.File),
+				g.PB, g.BR, g.PW, g.WR, g.DT, g.RE, g.Moves)
+		}
+	}
+	if !*quiet {
+		fmt.Println(n, "of", len(idx.Games), "games match")
+	}
+}
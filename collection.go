@@ -0,0 +1,364 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/collection.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/27/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the games of a collection: each game has its own
+ *	Game, with its board and cached properties, and one of them is selected
+ *	as the Game of the GameTree. Games can be counted, selected, appended,
+ *	removed, and moved within the collection.
+ */
+
+package sgf
+
+import (
+	"strconv"
+
+	"github.com/Ken1JF/ah"
+)
+
+// gameNodes returns the GameInfoNodes of the collection, in order.
+func (gamT *GameTree) gameNodes() (nodes []TreeNodeIdx) {
+	if len(gamT.treeNodes) == 0 {
+		return nodes
+	}
+	coll := gamT.firstChild(0)
+	if coll == nilTreeNodeIdx {
+		return nodes
+	}
+	tail := gamT.treeNodes[coll].Children
+	if tail == nilTreeNodeIdx {
+		return nodes
+	}
+	for n := gamT.treeNodes[tail].NextSib; ; n = gamT.treeNodes[n].NextSib {
+		nodes = append(nodes, n)
+		if n == tail {
+			break
+		}
+	}
+	return nodes
+}
+
+// initGames sets up the games of a GameTree that was not made by the Parser,
+// such as a pattern tree. The first game is selected, with the state of the GameTree.
+func (gamT *GameTree) initGames() {
+	if len(gamT.games) != 0 {
+		return
+	}
+	nodes := gamT.gameNodes()
+	if len(nodes) == 0 {
+		return
+	}
+	gamT.games = make([]Game, len(nodes))
+	for i, n := range nodes {
+		gamT.games[i].InfoNode = n
+	}
+	gamT.InfoNode = nodes[0]
+	gamT.selGame = 0
+}
+
+// addGame adds the state of a new game, at GameInfoNode n, and selects it.
+// The first game keeps the state set up before it. The others start with
+// the default board of FF[4], 19 by 19, until SZ is set, played if play is true.
+func (gamT *GameTree) addGame(n TreeNodeIdx, play bool) {
+	if len(gamT.games) == 0 {
+		gamT.games = append(gamT.games, Game{InfoNode: n})
+		gamT.InfoNode = n
+		gamT.selGame = 0
+		return
+	}
+	gamT.games[gamT.selGame] = gamT.Game
+	gamT.games = append(gamT.games, Game{InfoNode: n})
+	gamT.selGame = len(gamT.games) - 1
+	gamT.Game = Game{InfoNode: n}
+	gamT.InitAbstHier(19, 19, ah.StringLevel, play)
+}
+
+// selectedGameNode returns the GameInfoNode of the selected game,
+// or nilTreeNodeIdx if gamT has no games.
+func (gamT *GameTree) selectedGameNode() TreeNodeIdx {
+	gamT.initGames()
+	if len(gamT.games) == 0 {
+		return nilTreeNodeIdx
+	}
+	return gamT.InfoNode
+}
+
+// gameIndex returns the index of the game that holds node n, or -1.
+func (gamT *GameTree) gameIndex(n TreeNodeIdx) int {
+	gamT.initGames()
+	for nod := n; int(nod) < len(gamT.treeNodes); nod = gamT.treeNodes[nod].Parent {
+		if gamT.treeNodes[nod].TNodType == GameInfoNode {
+			for i := range gamT.games {
+				if gamT.games[i].InfoNode == nod {
+					return i
+				}
+			}
+			break
+		}
+	}
+	return -1
+}
+
+// checkGame returns an error if there is no game i.
+func (gamT *GameTree) checkGame(what string, i int) (err ah.ErrorList) {
+	if i < 0 || i >= len(gamT.games) {
+		err.Add(ah.NoPos, what+": no game "+strconv.Itoa(i)+" of "+strconv.Itoa(len(gamT.games)))
+	}
+	return err
+}
+
+// NumGames returns the number of games in the collection.
+func (gamT *GameTree) NumGames() int {
+	gamT.initGames()
+	return len(gamT.games)
+}
+
+// SelectedGame returns the index of the selected game, or -1 if there are no games.
+// After parsing, the first game is selected.
+func (gamT *GameTree) SelectedGame() int {
+	gamT.initGames()
+	if len(gamT.games) == 0 {
+		return -1
+	}
+	return gamT.selGame
+}
+
+// SelectGame makes game i the Game of gamT: the board, and the cached properties,
+// used by the methods of GameTree (GetPB, GetResult, GetSize, CheckProperties, ...)
+// are those of game i.
+func (gamT *GameTree) SelectGame(i int) (err ah.ErrorList) {
+	gamT.initGames()
+	if err = gamT.checkGame("SelectGame", i); len(err) != 0 {
+		return err
+	}
+	if i != gamT.selGame {
+		gamT.games[gamT.selGame] = gamT.Game
+		gamT.Game = gamT.games[i]
+		gamT.selGame = i
+	}
+	return err
+}
+
+// GetGame returns the Game of game i, or nil if there is no game i.
+// The Game is valid until another game is selected,
+// or the games are appended, removed or moved.
+func (gamT *GameTree) GetGame(i int) *Game {
+	gamT.initGames()
+	if i < 0 || i >= len(gamT.games) {
+		return nil
+	}
+	if i == gamT.selGame {
+		return &gamT.Game
+	}
+	return &gamT.games[i]
+}
+
+// AppendGame adds a new game at the end of the collection, with FF[4], GM[1],
+// and SZ for a board of nCol by nRow, and returns its index.
+// The selected game does not change, unless the collection was empty.
+func (gamT *GameTree) AppendGame(nCol int, nRow int) (i int, err ah.ErrorList) {
+	if nCol < 1 || nRow < 1 || nCol > MaxBoardSize || nRow > MaxBoardSize {
+		err.Add(ah.NoPos, "AppendGame: bad size "+FormatSize(nCol, nRow))
+		return -1, err
+	}
+	gamT.initGames()
+	if len(gamT.treeNodes) == 0 {
+		gamT.initGameTree()
+	}
+	coll := gamT.firstChild(0)
+	if coll == nilTreeNodeIdx {
+		if coll, err = gamT.AddChild(0, CollectionNode, 0); len(err) != 0 {
+			return -1, err
+		}
+	}
+	n, err := gamT.AddChild(coll, GameInfoNode, 0)
+	if len(err) != 0 {
+		return -1, err
+	}
+	prev := gamT.SelectedGame()
+	gamT.addGame(n, true)
+	for _, p := range []struct {
+		typ PropertyDefIdx
+		val string
+	}{{FF_idx, "4"}, {GM_idx, "1"}, {SZ_idx, FormatSize(nCol, nRow)}} {
+		var pv PropertyValue
+		pv.PropType = p.typ
+		pv.StrValue = []byte(p.val)
+		pv.ValType = GetProperty(p.typ).Value
+		pv.NextProp = nilPropIdx
+		err = append(err, gamT.AddAProp(n, pv)...)
+	}
+	gamT.SetFF([]byte("4"))
+	gamT.InitAbstHier(ah.ColSize(nCol), ah.RowSize(nRow), ah.StringLevel, true)
+	i = gamT.selGame
+	if prev >= 0 {
+		gamT.SelectGame(prev)
+	}
+	return i, err
+}
+
+// RemoveGame removes game i, and its nodes, from the collection.
+// If game i is selected, the game that takes its place, or else the last game, is selected.
+// The nodes after the removed nodes are renumbered, so a TreeNodeIdx
+// kept from before is not valid after.
+func (gamT *GameTree) RemoveGame(i int) (err ah.ErrorList) {
+	gamT.initGames()
+	if err = gamT.checkGame("RemoveGame", i); len(err) != 0 {
+		return err
+	}
+	n := gamT.games[i].InfoNode
+	if i != gamT.selGame {
+		gamT.games = append(gamT.games[:i], gamT.games[i+1:]...)
+		if i < gamT.selGame {
+			gamT.selGame -= 1
+		}
+	} else {
+		gamT.games = append(gamT.games[:i], gamT.games[i+1:]...)
+		switch {
+		case len(gamT.games) == 0:
+			gamT.Game = Game{}
+			gamT.selGame = 0
+		case i < len(gamT.games):
+			gamT.Game = gamT.games[i]
+		default:
+			gamT.selGame = len(gamT.games) - 1
+			gamT.Game = gamT.games[gamT.selGame]
+		}
+	}
+	gamT.removeSubtree(n)
+	return err
+}
+
+// MoveGame moves game from to index to, in the collection,
+// and the games between them by one place.
+// The selected game stays selected, at its new index.
+func (gamT *GameTree) MoveGame(from int, to int) (err ah.ErrorList) {
+	gamT.initGames()
+	if err = gamT.checkGame("MoveGame", from); len(err) != 0 {
+		return err
+	}
+	if err = gamT.checkGame("MoveGame", to); len(err) != 0 {
+		return err
+	}
+	if from == to {
+		return err
+	}
+	gamT.games[gamT.selGame] = gamT.Game
+	g := gamT.games[from]
+	gamT.games = append(gamT.games[:from], gamT.games[from+1:]...)
+	gamT.games = append(gamT.games[:to], append([]Game{g}, gamT.games[to:]...)...)
+	// relink the GameInfoNodes, in the new order
+	coll := gamT.firstChild(0)
+	last := len(gamT.games) - 1
+	for k := range gamT.games {
+		gamT.treeNodes[gamT.games[k].InfoNode].NextSib = gamT.games[(k+1)%len(gamT.games)].InfoNode
+		if gamT.games[k].InfoNode == gamT.InfoNode {
+			gamT.selGame = k
+		}
+	}
+	gamT.treeNodes[coll].Children = gamT.games[last].InfoNode
+	return err
+}
+
+// removeSubtree removes node n, and the nodes below it, from gamT.
+// Their properties are put on the avail list, and the nodes that remain are renumbered.
+func (gamT *GameTree) removeSubtree(n TreeNodeIdx) {
+	// unlink n from the children of its parent
+	par := gamT.treeNodes[n].Parent
+	if par != nilTreeNodeIdx {
+		tail := gamT.treeNodes[par].Children
+		if gamT.treeNodes[n].NextSib == n {
+			gamT.treeNodes[par].Children = nilTreeNodeIdx
+		} else {
+			prev := tail
+			for gamT.treeNodes[prev].NextSib != n {
+				prev = gamT.treeNodes[prev].NextSib
+			}
+			gamT.treeNodes[prev].NextSib = gamT.treeNodes[n].NextSib
+			if tail == n {
+				gamT.treeNodes[par].Children = prev
+			}
+		}
+	}
+	// mark the nodes of the subtree, and release their properties
+	dead := make([]bool, len(gamT.treeNodes))
+	stack := []TreeNodeIdx{n}
+	for len(stack) > 0 {
+		nod := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dead[nod] = true
+		if tail := gamT.treeNodes[nod].Children; tail != nilTreeNodeIdx {
+			for ch := gamT.treeNodes[tail].NextSib; ; ch = gamT.treeNodes[ch].NextSib {
+				stack = append(stack, ch)
+				if ch == tail {
+					break
+				}
+			}
+		}
+		switch gamT.treeNodes[nod].TNodType {
+		case BlackMoveNode, WhiteMoveNode, SequenceNode:
+			continue
+		}
+		if tail := gamT.treeNodes[nod].propListOrNodeLoc; tail != nilPropIdx {
+			var props []PropIdx
+			for pIdx := gamT.propertyValues[tail].NextProp; ; pIdx = gamT.propertyValues[pIdx].NextProp {
+				props = append(props, pIdx)
+				if pIdx == tail {
+					break
+				}
+			}
+			for _, pIdx := range props {
+				gamT.AddToAvailProps(pIdx)
+			}
+		}
+	}
+	// renumber the nodes that remain
+	newIdx := make([]TreeNodeIdx, len(gamT.treeNodes))
+	k := 0
+	for i := range gamT.treeNodes {
+		if dead[i] {
+			newIdx[i] = nilTreeNodeIdx
+		} else {
+			newIdx[i] = TreeNodeIdx(k)
+			k += 1
+		}
+	}
+	remap := func(nod TreeNodeIdx) TreeNodeIdx {
+		if nod == nilTreeNodeIdx {
+			return nod
+		}
+		return newIdx[nod]
+	}
+	nodes := gamT.treeNodes[:0]
+	var hashes []uint64
+	for i, tn := range gamT.treeNodes {
+		if dead[i] {
+			continue
+		}
+		tn.Parent = remap(tn.Parent)
+		tn.Children = remap(tn.Children)
+		tn.NextSib = remap(tn.NextSib)
+		nodes = append(nodes, tn)
+		if len(gamT.nodeHashes) == len(gamT.treeNodes) {
+			hashes = append(hashes, gamT.nodeHashes[i])
+		}
+	}
+	gamT.treeNodes = nodes
+	gamT.nodeHashes = hashes
+	if gamT.patInfo != nil {
+		patInfo := make(map[TreeNodeIdx]PatternInfo, len(gamT.patInfo))
+		for nod, inf := range gamT.patInfo {
+			if !dead[nod] {
+				patInfo[newIdx[nod]] = inf
+			}
+		}
+		gamT.patInfo = patInfo
+	}
+	for i := range gamT.games {
+		gamT.games[i].InfoNode = remap(gamT.games[i].InfoNode)
+	}
+	gamT.InfoNode = remap(gamT.InfoNode)
+}
@@ -0,0 +1,349 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/date.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/17/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the dates of games, as found in DT properties:
+ *	parsing FF[4] lists of partial dates, and common non-standard forms,
+ *	writing them in FF[4] syntax, and keys for sorting games by date.
+ */
+
+package sgf
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// DatePrecision is the precision of a PartialDate.
+type DatePrecision uint8
+
+const (
+	DateYear  DatePrecision = iota // only the year is known: 1996
+	DateMonth                      // the year and month are known: 1996-05
+	DateDay                        // the full date is known: 1996-05-06
+)
+
+var DatePrecisionNames = []string{"year", "month", "day"}
+
+// A PartialDate is one date of a DT property.
+// Month is 0 if only the year is known, and Day is 0 if only the year and month are known.
+type PartialDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// Precision returns the precision of the date.
+func (d PartialDate) Precision() DatePrecision {
+	switch {
+	case d.Month == 0:
+		return DateYear
+	case d.Day == 0:
+		return DateMonth
+	}
+	return DateDay
+}
+
+// String formats the date in full FF[4] form: YYYY, YYYY-MM, or YYYY-MM-DD.
+func (d PartialDate) String() string {
+	s := strconv.Itoa(d.Year)
+	for len(s) < 4 {
+		s = "0" + s
+	}
+	if d.Month > 0 {
+		s += "-" + twoDigits(d.Month)
+		if d.Day > 0 {
+			s += "-" + twoDigits(d.Day)
+		}
+	}
+	return s
+}
+
+// Key returns a key for sorting dates: YYYY-MM-DD, with 00 for the unknown parts.
+// So a partial date sorts before the full dates within it.
+func (d PartialDate) Key() string {
+	s := strconv.Itoa(d.Year)
+	for len(s) < 4 {
+		s = "0" + s
+	}
+	return s + "-" + twoDigits(d.Month) + "-" + twoDigits(d.Day)
+}
+
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// daysIn returns the number of days in a month.
+func daysIn(year int, month int) int {
+	switch month {
+	case 2:
+		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	}
+	return 31
+}
+
+// valid returns an error if the month or day of d is out of range.
+func (d PartialDate) valid() error {
+	if d.Month < 0 || d.Month > 12 {
+		return errors.New("month " + strconv.Itoa(d.Month) + " out of range")
+	}
+	if d.Day < 0 || (d.Month > 0 && d.Day > daysIn(d.Year, d.Month)) || (d.Month == 0 && d.Day != 0) {
+		return errors.New("day " + strconv.Itoa(d.Day) + " out of range")
+	}
+	return nil
+}
+
+// monthNames are the names of months, and seasons, compared by their first three letters.
+var monthNames = map[string][]int{
+	"jan": {1}, "feb": {2}, "mar": {3}, "apr": {4}, "may": {5}, "jun": {6},
+	"jul": {7}, "aug": {8}, "sep": {9}, "oct": {10}, "nov": {11}, "dec": {12},
+	"spr": {3, 4, 5}, "sum": {6, 7, 8}, "aut": {9, 10, 11}, "fal": {9, 10, 11}, "win": {12},
+}
+
+// ParseDate parses the value of a DT property into a list of partial dates.
+// It accepts the FF[4] forms:
+//	1996, 1996-05, 1996-05-06		a year, a month, or a day
+//	1996-05-06,07,08			days, in the same month
+//	1996-05,06				months, in the same year
+//	1996-12-27,28,1997-01-03,04	dates in different months, or years
+// and common non-standard forms:
+//	1996/05/06, 1996.05.06, 19960506	other separators
+//	1996-05-06~08			a range of days, or months
+//	May 6, 1996, 6 May 1996	names of months
+//	Autumn 1934				seasons, as a list of months
+func ParseDate(s string) (dates []PartialDate, err error) {
+	fail := func(msg string) ([]PartialDate, error) {
+		return nil, errors.New("date \"" + s + "\": " + msg)
+	}
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return fail("empty")
+	}
+	if strings.IndexFunc(t, isDateLetter) >= 0 {
+		dates, err = parseTextDate(t)
+	} else {
+		dates, err = parseNumDates(t)
+	}
+	if err != nil {
+		return fail(err.Error())
+	}
+	for _, d := range dates {
+		if err := d.valid(); err != nil {
+			return fail(err.Error())
+		}
+	}
+	return dates, nil
+}
+
+// isDateLetter returns true for the letters of the names of months and seasons.
+func isDateLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// parseNumDates parses a list of numeric dates, separated by commas.
+func parseNumDates(t string) (dates []PartialDate, err error) {
+	for _, item := range strings.FieldsFunc(t, func(r rune) bool { return r == ',' || r == ';' }) {
+		item = strings.TrimSpace(item)
+		ends := strings.Split(item, "~")
+		if len(ends) > 2 {
+			return nil, errors.New("bad range \"" + item + "\"")
+		}
+		var prev *PartialDate
+		if len(dates) > 0 {
+			prev = &dates[len(dates)-1]
+		}
+		d, err := parseNumDate(strings.TrimSpace(ends[0]), prev)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, d)
+		if len(ends) == 2 {
+			e, err := parseNumDate(strings.TrimSpace(ends[1]), &d)
+			if err != nil {
+				return nil, err
+			}
+			if e.Precision() != d.Precision() || e.Key() < d.Key() {
+				return nil, errors.New("bad range \"" + item + "\"")
+			}
+			// expand the range, one day or month at a time
+			for n := 0; d.Key() < e.Key(); n++ {
+				if n > 366 {
+					return nil, errors.New("range too long \"" + item + "\"")
+				}
+				if d.Precision() == DateDay {
+					d.Day += 1
+					if d.Day > daysIn(d.Year, d.Month) {
+						d.Day, d.Month = 1, d.Month+1
+					}
+				} else if d.Precision() == DateMonth {
+					d.Month += 1
+				} else {
+					d.Year += 1
+				}
+				if d.Month > 12 {
+					d.Month, d.Year = 1, d.Year+1
+				}
+				dates = append(dates, d)
+			}
+		}
+	}
+	if len(dates) == 0 {
+		return nil, errors.New("no dates")
+	}
+	return dates, nil
+}
+
+// parseNumDate parses one numeric date, which may be abbreviated,
+// as allowed by FF[4], if it follows prev.
+func parseNumDate(item string, prev *PartialDate) (d PartialDate, err error) {
+	item = strings.NewReplacer("/", "-", ".", "-").Replace(item)
+	if len(item) == 8 && strings.IndexByte(item, '-') < 0 {
+		item = item[0:4] + "-" + item[4:6] + "-" + item[6:8]
+	}
+	parts := strings.Split(item, "-")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 {
+			return d, errors.New("bad number \"" + p + "\"")
+		}
+		nums[i] = n
+	}
+	full := len(parts[0]) == 4
+	for i, n := range nums {
+		if n == 0 && (i > 0 || !full) {
+			return d, errors.New("month or day 0")
+		}
+	}
+	switch {
+	case full && len(nums) <= 3:
+		d.Year = nums[0]
+		if len(nums) > 1 {
+			d.Month = nums[1]
+		}
+		if len(nums) > 2 {
+			d.Day = nums[2]
+		}
+	case prev == nil:
+		return d, errors.New("must start with a year")
+	case prev.Precision() == DateDay && len(nums) == 1:
+		d = PartialDate{prev.Year, prev.Month, nums[0]}
+	case prev.Precision() == DateDay && len(nums) == 2:
+		d = PartialDate{prev.Year, nums[0], nums[1]}
+	case prev.Precision() == DateMonth && len(nums) == 1:
+		d = PartialDate{prev.Year, nums[0], 0}
+	default:
+		return d, errors.New("bad abbreviation \"" + item + "\"")
+	}
+	return d, nil
+}
+
+// parseTextDate parses a date with the names of months or seasons, such as
+// "May 6, 1996", or "Autumn 1934". Other words, such as "circa", are ignored.
+func parseTextDate(t string) (dates []PartialDate, err error) {
+	words := strings.FieldsFunc(t, func(r rune) bool {
+		return !isDateLetter(r) && (r < '0' || r > '9')
+	})
+	year, day := 0, 0
+	var months []int
+	for _, w := range words {
+		if n, errN := strconv.Atoi(w); errN == nil {
+			switch {
+			case len(w) == 4 && year == 0:
+				year = n
+			case len(w) <= 2 && day == 0 && n >= 1:
+				day = n
+			default:
+				return nil, errors.New("unexpected number \"" + w + "\"")
+			}
+		} else if len(w) >= 3 && months == nil {
+			months = monthNames[strings.ToLower(w[0:3])]
+		}
+	}
+	if year == 0 {
+		return nil, errors.New("no year")
+	}
+	if months == nil {
+		if day != 0 {
+			return nil, errors.New("day without a month")
+		}
+		return []PartialDate{{year, 0, 0}}, nil
+	}
+	if day != 0 && len(months) > 1 {
+		return nil, errors.New("day in a season")
+	}
+	for _, m := range months {
+		dates = append(dates, PartialDate{year, m, day})
+	}
+	return dates, nil
+}
+
+// FormatDate writes a list of dates in FF[4] syntax, abbreviating each date
+// that has the same precision, and year (and month, for days), as the one before it.
+func FormatDate(dates []PartialDate) string {
+	var b []byte
+	for i, d := range dates {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		switch {
+		case i == 0 || d.Precision() != dates[i-1].Precision() || d.Year != dates[i-1].Year:
+			b = append(b, d.String()...)
+		case d.Precision() == DateDay && d.Month == dates[i-1].Month:
+			b = append(b, twoDigits(d.Day)...)
+		case d.Precision() == DateDay:
+			b = append(b, twoDigits(d.Month)+"-"+twoDigits(d.Day)...)
+		case d.Precision() == DateMonth:
+			b = append(b, twoDigits(d.Month)...)
+		default:
+			b = append(b, d.String()...)
+		}
+	}
+	return string(b)
+}
+
+// NormalizeDate parses the value of a DT property, and writes it in FF[4] syntax.
+func NormalizeDate(s string) (string, error) {
+	dates, err := ParseDate(s)
+	if err != nil {
+		return "", err
+	}
+	return FormatDate(dates), nil
+}
+
+// DateKey returns the key (see PartialDate.Key) of the earliest date of a DT property,
+// or "" if it cannot be parsed.
+func DateKey(s string) string {
+	dates, err := ParseDate(s)
+	if err != nil {
+		return ""
+	}
+	key := dates[0].Key()
+	for _, d := range dates[1:] {
+		if k := d.Key(); k < key {
+			key = k
+		}
+	}
+	return key
+}
+
+// GetDates returns the parsed DT of the game.
+// An error is returned if DT is set, but cannot be parsed.
+func (gam *Game) GetDates() ([]PartialDate, error) {
+	if gam.dT == nil {
+		return nil, nil
+	}
+	return ParseDate(string(gam.dT))
+}
@@ -0,0 +1,494 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/duplicates.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/5/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file finds duplicate games in a data base: the same game from different
+ *	sources, with different headers, endings, or orientations.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultDupMinMoves is the MinMoves of a DupDetector that does not set it.
+const DefaultDupMinMoves = 30
+
+// DupKind is the relation of a game in a DupCluster to the game to keep.
+type DupKind uint8
+
+const (
+	DupKeep     DupKind = iota // the game to keep
+	DupExact                   // the same moves
+	DupPrefix                  // the moves are a prefix of the kept game
+	DupDiverges                // the moves agree for at least MinMoves, and the headers match
+)
+
+var DupKindNames = []string{"keep", "exact", "prefix", "diverges"}
+
+// A DupGame is a game in a DupCluster.
+//	Game is the index of the game in File, see SelectGame.
+//	Trans is the symmetry that maps the game to the orientation of the kept game.
+//	Swapped is true if the colors of the moves are reversed, compared to the kept game.
+//	Common is the number of moves in common with the kept game.
+//	HeadersMatch is true if PB, PW, and DT are consistent with the kept game.
+type DupGame struct {
+	File         string
+	Game         int
+	Moves        int
+	Kind         DupKind
+	Trans        ah.BoardTrans
+	Swapped      bool
+	Common       int
+	HeadersMatch bool
+}
+
+// A DupCluster is a group of copies of the same game. Games[0] is the copy to keep:
+// the longest game, then the one with the most complete game-info.
+type DupCluster struct {
+	Games  []DupGame
+	Reason string // why Games[0] was chosen
+}
+
+// dupGame is the fingerprint of one game.
+//	key is the board size, the setup points, and the moves, in their least
+//	orientation (see fingerprint). The colors are not included.
+type dupGame struct {
+	file       string
+	game       int // the index of the game in file
+	key        string
+	keyHead    int // length of the part of key before the moves
+	nMoves     int
+	trans      ah.BoardTrans   // maps the game to key
+	ties       []ah.BoardTrans // all the symmetries that map the game to key, including trans
+	firstColr  ah.PointStatus
+	pB, pW, dT string
+	nInfo      int // number of game-info properties present
+	hasRE      bool
+}
+
+// A DupDetector collects the fingerprints of games, and finds the duplicates.
+//	MinMoves is the least number of moves in common for a game to be reported
+//	as a prefix of a longer game, or as diverging from it (0 => DefaultDupMinMoves)
+type DupDetector struct {
+	MinMoves int
+	games    []dupGame
+}
+
+// Type byDupKey implements the sort.Interface based on the key, then the file and game.
+type byDupKey []dupGame
+
+func (bk byDupKey) Len() int      { return len(bk) }
+func (bk byDupKey) Swap(i, j int) { bk[i], bk[j] = bk[j], bk[i] }
+func (bk byDupKey) Less(i, j int) bool {
+	if bk[i].key != bk[j].key {
+		return bk[i].key < bk[j].key
+	}
+	return bk[i].before(&bk[j])
+}
+
+// before returns true if g is before h, in order of their file, and game in the file.
+func (g *dupGame) before(h *dupGame) bool {
+	if g.file != h.file {
+		return g.file < h.file
+	}
+	return g.game < h.game
+}
+
+// fingerprint returns the key of a game, under the symmetries that make it least.
+// Each point is two bytes, column then row, with 0xFF 0xFF for a pass.
+// The symmetries that swap the rows and columns are only used on square boards.
+// ties has more than one symmetry when the game is symmetric, as a short game may be.
+func fingerprint(nCol int, nRow int, setup []ah.NodeLoc, movs []ah.NodeLoc) (key string, head int, ties []ah.BoardTrans) {
+	prefix := strconv.Itoa(nCol) + "x" + strconv.Itoa(nRow) + ":"
+	enc := func(t ah.BoardTrans, nl ah.NodeLoc) []byte {
+		if nl == ah.PassNodeLoc {
+			return []byte{0xFF, 0xFF}
+		}
+		c, r := ah.GetColRow(nl)
+		x, y := transLocal(t, int(c), int(r), nCol, nRow)
+		return []byte{byte(x), byte(y)}
+	}
+	first := true
+	for _, t := range boardSymmetries(nCol, nRow) {
+		var pts []string
+		for _, nl := range setup {
+			pts = append(pts, string(enc(t, nl)))
+		}
+		sort.Strings(pts)
+		k := []byte(prefix + strings.Join(pts, "") + "|")
+		h := len(k)
+		for _, nl := range movs {
+			k = append(k, enc(t, nl)...)
+		}
+		switch {
+		case first || string(k) < key:
+			key, head, ties = string(k), h, []ah.BoardTrans{t}
+			first = false
+		case string(k) == key:
+			ties = append(ties, t)
+		}
+	}
+	return key, head, ties
+}
+
+// AddGame adds the main line of the selected game in gamT to the detector.
+//	file is the name reported in the clusters, with the index of the selected game.
+func (dd *DupDetector) AddGame(file string, gamT *GameTree) {
+	nCol, nRow := gamT.boardSize()
+	var setup []ah.NodeLoc
+	setup = append(setup, gamT.aB...)
+	setup = append(setup, gamT.aW...)
+	var movs []ah.NodeLoc
+	g := dupGame{file: file, game: gamT.SelectedGame(), firstColr: ah.Black}
+	for i, mov := range gamT.mainLineMoves() {
+		if i == 0 {
+			g.firstColr = mov.colr
+		}
+		movs = append(movs, mov.loc)
+	}
+	g.key, g.keyHead, g.ties = fingerprint(nCol, nRow, setup, movs)
+	g.trans = g.ties[0]
+	g.nMoves = len(movs)
+	g.pB = string(gamT.pB)
+	g.pW = string(gamT.pW)
+	g.dT = string(gamT.dT)
+	for _, p := range [][]byte{gamT.pB, gamT.pW, gamT.bR, gamT.wR, gamT.dT, gamT.eV, gamT.rO, gamT.pC, gamT.rE.val} {
+		if len(p) > 0 {
+			g.nInfo += 1
+		}
+	}
+	if gamT.kM.set {
+		g.nInfo += 1
+	}
+	g.hasRE = len(gamT.rE.val) > 0
+	dd.games = append(dd.games, g)
+}
+
+// commonMoves returns the number of moves in common between a and b,
+// and -1 if they have different board sizes or setup points.
+func commonMoves(a *dupGame, b *dupGame) int {
+	if a.keyHead != b.keyHead || a.key[:a.keyHead] != b.key[:b.keyHead] {
+		return -1
+	}
+	i := a.keyHead
+	for i+1 < len(a.key) && i+1 < len(b.key) && a.key[i:i+2] == b.key[i:i+2] {
+		i += 2
+	}
+	return (i - a.keyHead) / 2
+}
+
+// normName returns a player name, in lower case, with its words sorted,
+// so "Go Seigen", "go seigen", and "Seigen, Go" are the same.
+func normName(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r >= 0x80)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			d := prev[j-1]
+			if a[i-1] != b[j-1] {
+				d += 1
+			}
+			if prev[j]+1 < d {
+				d = prev[j] + 1
+			}
+			if cur[j-1]+1 < d {
+				d = cur[j-1] + 1
+			}
+			cur[j] = d
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// namesMatch returns true if two player names may be the same player.
+// A missing name matches any name. Small spelling differences are allowed,
+// and a name may be part of the other name, as "Kitani" and "Kitani Minoru".
+func namesMatch(a string, b string) bool {
+	a, b = normName(a), normName(b)
+	if a == "" || b == "" || a == b {
+		return true
+	}
+	if editDistance(a, b) <= 2 {
+		return true
+	}
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) > len(wb) {
+		wa, wb = wb, wa
+	}
+	for _, w := range wa {
+		found := false
+		for _, v := range wb {
+			if w == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// datesMatch returns true if two dates may be the same day.
+// Only the digits are compared, so 1951-06-12 matches 1951, and 1951.06.12
+func datesMatch(a string, b string) bool {
+	digits := func(s string) string {
+		var d []byte
+		for i := 0; i < len(s) && i < 10; i++ {
+			if s[i] >= '0' && s[i] <= '9' {
+				d = append(d, s[i])
+			}
+		}
+		return string(d)
+	}
+	a, b = digits(a), digits(b)
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return strings.HasPrefix(b, a)
+}
+
+// headersMatch returns true if the PB, PW, and DT of a and b are consistent.
+// If swapped, the players of b are compared with the opposite players of a.
+func headersMatch(a *dupGame, b *dupGame, swapped bool) bool {
+	pB, pW := b.pB, b.pW
+	if swapped {
+		pB, pW = pW, pB
+	}
+	return namesMatch(a.pB, pB) && namesMatch(a.pW, pW) && datesMatch(a.dT, b.dT)
+}
+
+// Clusters returns the groups of duplicate games, in order of the file of the kept game.
+// Games with the same moves (exact), games whose moves are a prefix of a longer game,
+// and games that agree for MinMoves moves (diverges) are put in the same cluster.
+// Prefixes and diverging games must also have matching headers (see headersMatch).
+func (dd *DupDetector) Clusters() (clusters []DupCluster) {
+	minMoves := dd.MinMoves
+	if minMoves == 0 {
+		minMoves = DefaultDupMinMoves
+	}
+	games := append([]dupGame(nil), dd.games...)
+	sort.Sort(byDupKey(games))
+	// In order of their keys, the games that share a prefix are next to each other,
+	// in blocks of games that share at least minMoves moves, or are the same.
+	// Each game is compared with the last game of each cluster in its block,
+	// and the clusters are joined with union-find. So the time is proportional to
+	// the number of games times the number of clusters in a block, not to the square
+	// of the number of games, when a block holds many copies of a game.
+	root := make([]int, len(games))
+	for i := range root {
+		root[i] = i
+	}
+	find := func(i int) int {
+		for root[i] != i {
+			root[i] = root[root[i]]
+			i = root[i]
+		}
+		return i
+	}
+	// joins returns true if the games are the same, or may be the same.
+	// Exact duplicates are always joined, prefixes and diverging games only
+	// if their headers match, so a short game is not used to join two different games
+	joins := func(prev *dupGame, cur *dupGame, n int) bool {
+		if n == prev.nMoves && n == cur.nMoves {
+			return true
+		}
+		return n >= minMoves && headersMatch(prev, cur, prev.firstColr != cur.firstColr)
+	}
+	last := []int{0} // the last game of each cluster in the block
+	for i := 1; i < len(games); i++ {
+		cur := &games[i]
+		n := commonMoves(&games[i-1], cur)
+		if n < 0 || (n < minMoves && !(n == games[i-1].nMoves && n == cur.nMoves)) {
+			last = last[:0]
+		}
+		for _, j := range last {
+			if find(j) != find(i) && joins(&games[j], cur, commonMoves(&games[j], cur)) {
+				root[find(i)] = find(j)
+			}
+		}
+		// cur is now the last game of its cluster, which may have joined others
+		keep := last[:0]
+		for _, j := range last {
+			if find(j) != find(i) {
+				keep = append(keep, j)
+			}
+		}
+		last = append(keep, i)
+	}
+	groups := make(map[int][]dupGame)
+	for i := range games {
+		r := find(i)
+		groups[r] = append(groups[r], games[i])
+	}
+	for _, grp := range groups {
+		if len(grp) > 1 {
+			clusters = append(clusters, makeCluster(grp))
+		}
+	}
+	sort.Sort(byKeptFile(clusters))
+	return clusters
+}
+
+// transOf returns the symmetry that maps game g to the orientation of the kept game k.
+// g is mapped to the key by one of its ties, and the key to k by the inverse of k.trans.
+// A prefix may be symmetric, where the longer game is not: then each of its ties agrees
+// with k, and the identity is used, if it is one of them, so a copy is not reported as rotated.
+func (k *dupGame) transOf(g *dupGame) ah.BoardTrans {
+	inv := ah.InverseTrans[k.trans]
+	for _, t := range g.ties {
+		if ah.ComposeTrans[t][inv] == ah.T_IDENTITY {
+			return ah.T_IDENTITY
+		}
+	}
+	return ah.ComposeTrans[g.trans][inv]
+}
+
+// Type byKeptFile implements the sort.Interface based on the file, and game, of the kept game.
+type byKeptFile []DupCluster
+
+func (bk byKeptFile) Len() int           { return len(bk) }
+func (bk byKeptFile) Swap(i, j int)      { bk[i], bk[j] = bk[j], bk[i] }
+func (bk byKeptFile) Less(i, j int) bool { return bk[i].Games[0].before(&bk[j].Games[0]) }
+
+// Type byDupFile implements the sort.Interface based on the file, and game.
+type byDupFile []DupGame
+
+func (bf byDupFile) Len() int           { return len(bf) }
+func (bf byDupFile) Swap(i, j int)      { bf[i], bf[j] = bf[j], bf[i] }
+func (bf byDupFile) Less(i, j int) bool { return bf[i].before(&bf[j]) }
+
+// before returns true if d is before e, in order of their File, and Game in the file.
+func (d *DupGame) before(e *DupGame) bool {
+	if d.File != e.File {
+		return d.File < e.File
+	}
+	return d.Game < e.Game
+}
+
+// makeCluster chooses the game to keep, and describes the others relative to it.
+func makeCluster(games []dupGame) (cl DupCluster) {
+	keep := 0
+	for i := 1; i < len(games); i++ {
+		g, k := &games[i], &games[keep]
+		switch {
+		case g.nMoves != k.nMoves:
+			if g.nMoves > k.nMoves {
+				keep = i
+			}
+		case g.nInfo != k.nInfo:
+			if g.nInfo > k.nInfo {
+				keep = i
+			}
+		case g.hasRE != k.hasRE:
+			if g.hasRE {
+				keep = i
+			}
+		case g.before(k):
+			keep = i
+		}
+	}
+	k := &games[keep]
+	cl.Reason = "first file name"
+	for i := range games {
+		g := &games[i]
+		if g.nMoves < k.nMoves {
+			cl.Reason = "most moves"
+			break
+		} else if g.nInfo < k.nInfo {
+			cl.Reason = "most complete game-info"
+		} else if g.hasRE != k.hasRE && cl.Reason == "first file name" {
+			cl.Reason = "has a result"
+		}
+	}
+	cl.Games = append(cl.Games, DupGame{File: k.file, Game: k.game, Moves: k.nMoves, Kind: DupKeep,
+		Trans: ah.T_IDENTITY, Common: k.nMoves, HeadersMatch: true})
+	for i := range games {
+		if i == keep {
+			continue
+		}
+		g := &games[i]
+		n := commonMoves(k, g)
+		swapped := k.firstColr != g.firstColr
+		d := DupGame{File: g.file, Game: g.game, Moves: g.nMoves, Common: n, Swapped: swapped,
+			HeadersMatch: headersMatch(k, g, swapped)}
+		d.Trans = k.transOf(g)
+		switch {
+		case n == g.nMoves && n == k.nMoves:
+			d.Kind = DupExact
+		case n == g.nMoves:
+			d.Kind = DupPrefix
+		default:
+			d.Kind = DupDiverges
+		}
+		cl.Games = append(cl.Games, d)
+	}
+	sort.Sort(byDupFile(cl.Games[1:]))
+	return cl
+}
+
+// FindDuplicates parses the .sgf files in dir, and its subdirectories,
+// and returns the clusters of duplicate games, with the DefaultDupMinMoves.
+// A file with errors is reported, and not included.
+func FindDuplicates(dir string) (clusters []DupCluster, errs ah.ErrorList) {
+	var dd DupDetector
+	errs = dd.AddDir(dir)
+	return dd.Clusters(), errs
+}
+
+// AddDir parses the .sgf files in dir, and its subdirectories, and adds each of their games,
+// named by their path relative to dir. A file with errors is reported, and not added.
+func (dd *DupDetector) AddDir(dir string) (errs ah.ErrorList) {
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs.Add(ah.NoPos, path+": "+err.Error())
+			return nil
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".sgf") {
+			return nil
+		}
+		prsr, errL := ParseFile(path, nil, 0, 0)
+		if len(errL) != 0 {
+			errs.Add(ah.NoPos, path+": "+errL.Error())
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		for g := 0; g < prsr.NumGames(); g++ {
+			prsr.SelectGame(g)
+			dd.AddGame(rel, &prsr.GameTree)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		errs.Add(ah.NoPos, dir+": "+walkErr.Error())
+	}
+	return errs
+}
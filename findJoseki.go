@@ -0,0 +1,30 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/findJoseki.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 2/11/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file walks SGF game trees, and records joseki (corner) patterns.
+ *	The corners are one of the regions of findRegions.go.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+)
+
+// AddJosekiPatterns adds the corner sequences from the main line of a game to a joseki tree.
+//	josTree is the GameTree which holds the joseki patterns.
+//		Note: may be nil on first use, or empty (see PatternOptions).
+//	moveLimit is the maximum number of moves of the game to examine (0 => no limit)
+//	patternLimit is the maximum number of moves in a corner sequence (0 => no limit)
+//
+//	The name of the game (see GetSourceName) is recorded with each node reached.
+//	See AddRegionPatterns.
+//
+// returns an Error if one is detected, and the updated josTree
+func (gamT *GameTree) AddJosekiPatterns(josTree *GameTree, moveLimit int, patternLimit int) (err ah.ErrorList, upJosTree *GameTree) {
+	return gamT.AddRegionPatterns(josTree, CornerRegion, "", moveLimit, patternLimit)
+}
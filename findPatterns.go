@@ -21,6 +21,115 @@ type traversePoint struct {
 	mkGd       bool
 }
 
+// The options used by a pattern tree that does not set them.
+const (
+	DefaultSettleMoves    = 10
+	DefaultMaxSampleGames = 5
+)
+
+// PatternOptions are the options of a pattern tree, kept in its PatternOpts.
+//	SettleMoves is the number of moves played elsewhere on the board, after which
+//		a local sequence is considered to have settled (0 => DefaultSettleMoves)
+//	MaxSampleGames is the largest number of sample games reported for a next move
+//		(0 => DefaultMaxSampleGames)
+// To set them before the first game is added, pass an empty GameTree with its
+// PatternOpts set, instead of nil, to AddRegionPatterns or AddTeachingPattern.
+type PatternOptions struct {
+	SettleMoves    int
+	MaxSampleGames int
+}
+
+func (opts PatternOptions) settleMoves() int {
+	if opts.SettleMoves == 0 {
+		return DefaultSettleMoves
+	}
+	return opts.SettleMoves
+}
+
+func (opts PatternOptions) maxSampleGames() int {
+	if opts.MaxSampleGames == 0 {
+		return DefaultMaxSampleGames
+	}
+	return opts.MaxSampleGames
+}
+
+// isNewPatternTree returns true if pattTree is nil, or an empty GameTree:
+// one that is replaced by newPatternTree, keeping its PatternOpts.
+func (pattTree *GameTree) isNewPatternTree() bool {
+	return pattTree == nil || len(pattTree.treeNodes) == 0
+}
+
+// newPatternTree creates a GameTree to hold patterns, with a CollectionNode
+// and a GameInfoNode, and the root properties needed to write it as an SGF file.
+//	szCol, szRow is the board size
+//	ha is the handicap, the handicap stones are added with an AB property
+//	from is nil, or an empty GameTree whose PatternOpts are used
+// returns the new tree, and the index of its GameInfoNode
+func newPatternTree(szCol ah.ColSize, szRow ah.RowSize, ha int, from *GameTree) (pattTree *GameTree, gInfoPatt TreeNodeIdx, err ah.ErrorList) {
+	var collPatt TreeNodeIdx
+	var pv PropertyValue
+	pattTree = new(GameTree)
+	if from != nil {
+		pattTree.PatternOpts = from.PatternOpts
+	}
+	pattTree.initGameTree()
+	collPatt, err = pattTree.AddChild(0, CollectionNode, 0)
+	if len(err) != 0 {
+		return nil, gInfoPatt, err
+	}
+	gInfoPatt, err = pattTree.AddChild(collPatt, GameInfoNode, 0)
+	if len(err) != 0 {
+		return nil, gInfoPatt, err
+	}
+	// TODO: are these needed? FF, GM, CA, AP, ST?
+	// ADD FF
+	pv.StrValue = []byte("4")
+	pv.PropType = FF_idx
+	pv.ValType = Num_1_4
+	pattTree.AddAProp(gInfoPatt, pv)
+	// ADD GM
+	pv.StrValue = []byte("1")
+	pv.PropType = GM_idx
+	pv.ValType = Num_1_5_or_7_16
+	pattTree.AddAProp(gInfoPatt, pv)
+	// ADD CA
+	pv.StrValue = []byte("UTF-8")
+	pv.PropType = CA_idx
+	pv.ValType = SimpText
+	pattTree.AddAProp(gInfoPatt, pv)
+	// ADD AP
+	// TODO: make it vary with releases?
+	pv.StrValue = []byte("test-ahgo:0.8")
+	pv.PropType = AP_idx
+	pv.ValType = CompSimpText_simpText
+	pattTree.AddAProp(gInfoPatt, pv)
+	// ADD ST
+	pv.StrValue = []byte("1")
+	pv.PropType = ST_idx
+	pv.ValType = Num_0_3
+	pattTree.AddAProp(gInfoPatt, pv)
+
+	// Add SZ
+	pv.StrValue = []byte(FormatSize(int(szCol), int(szRow)))
+	pv.PropType = SZ_idx
+	pattTree.AddAProp(gInfoPatt, pv)
+	// Add HA
+	pv.StrValue = []byte(strconv.Itoa(ha))
+	pv.PropType = HA_idx
+	pattTree.AddAProp(gInfoPatt, pv)
+	pattTree.InitAbstHier(szCol, szRow, ah.StringLevel, true)
+	pattTree.SetHandicap(ha)
+	pv.StrValue = pattTree.PlaceHandicap(ha, int(szCol), int(szRow))
+
+	if pv.StrValue != nil {
+		// Add the AB for handicap points
+		pv.PropType = AB_idx
+		pv.ValType = ListOfStone
+		pattTree.AddAProp(gInfoPatt, pv)
+	}
+	return pattTree, gInfoPatt, err
+}
+
 // AddTeachingPattern adds one or more patterns from a GameTree, to a global pattern tree (DAG)
 //	szCol, sizRow is the board size
 //	ha is the handicap
@@ -28,19 +137,21 @@ type traversePoint struct {
 //		But, some games have a starting pattern placed on the board with AB, AW, etc.
 //			at least one game has first move by White (skip it (them?)).
 //	pattTree is the GameTree which needs the pattern to be added, based on the handicap.
-//		Note: may be nil on first use.
+//		Note: may be nil on first use, or empty (see PatternOptions).
 //	pattType is the type of Pattern being stored in pattTree
 //		WHOLE_BOARD_PATTERN, etc.
 // TODO: add ohter types of patterns
 //	moveLimit is the maximum move number to place in the pattTree
 //		Does not include handicap or other pre-placed stones.
 //
+//	Each node visited in pattTree has its win counts (WB, WW, WO) incremented, based on the RE of gamT.
+//	When patternLimit stops a line that continues in gamT, a continuation (WC) is recorded.
+//
 // returns an Error if one is detected, a translation that takes the first move into a canonical location, and the updated pattTree
 func (gamT *GameTree) AddTeachingPattern(szCol ah.ColSize, szRow ah.RowSize, ha int, pattTree *GameTree,
 	pattType ah.PatternType, moveLimit int, patternLimit int, skipFiles int) (err ah.ErrorList, trans ah.BoardTrans, upPattTree *GameTree) {
-	var collPatt, gInfoPatt TreeNodeIdx
+	var gInfoPatt TreeNodeIdx
 	var curGam, curPatt TreeNodeIdx
-	var pv PropertyValue
 	var nodColr ah.PointStatus = ah.White
 	var traverseStack []traversePoint
 	var onMain bool = true
@@ -55,6 +166,9 @@ func (gamT *GameTree) AddTeachingPattern(szCol ah.ColSize, szRow ah.RowSize, ha
 	if ha == 0 {
 		firstMoveColor = ah.Black
 	}
+	// the game is recorded in the win counts and continuations of the pattTree
+	var srcIdx int
+	win := gamT.winner()
 	// count the moves:
 	nMoves := 0
 	found := false
@@ -78,75 +192,30 @@ func (gamT *GameTree) AddTeachingPattern(szCol ah.ColSize, szRow ah.RowSize, ha
 			pattTree.treeNodes[idxx].propListOrNodeLoc = PropIdx(newNL)
 			idx = idxx
 		}
+		pattTree.countPattern(idx, srcIdx, win)
 		return idx
 	}
-	// if pattTree doesn't exist, create it, and initialize it
-	if pattTree == nil {
-		pattTree = new(GameTree)
-		pattTree.initGameTree()
-		collPatt, err = pattTree.AddChild(0, CollectionNode, 0)
-		if len(err) != 0 {
-			return err, trans, upPattTree
+	addPropOnce := func(n TreeNodeIdx, pv PropertyValue) { // add pv, unless n already has one
+		if pattTree.findProp(n, pv.PropType) == nilPropIdx {
+			pattTree.AddAProp(n, pv)
 		}
-		gInfoPatt, err = pattTree.AddChild(collPatt, GameInfoNode, 0)
+	}
+	// if pattTree doesn't exist, create it, and initialize it
+	if pattTree.isNewPatternTree() {
+		pattTree, gInfoPatt, err = newPatternTree(szCol, szRow, ha, pattTree)
 		if len(err) != 0 {
 			return err, trans, upPattTree
 		}
 		// set the curPatt node in the pattTree
 		curPatt = gInfoPatt
-		// TODO: are these needed? FF, GM, CA, AP, ST?
-		// ADD FF
-		pv.StrValue = []byte("4")
-		pv.PropType = FF_idx
-		pv.ValType = Num_1_4
-		pattTree.AddAProp(gInfoPatt, pv)
-		// ADD GM
-		pv.StrValue = []byte("1")
-		pv.PropType = GM_idx
-		pv.ValType = Num_1_5_or_7_16
-		pattTree.AddAProp(gInfoPatt, pv)
-		// ADD CA
-		pv.StrValue = []byte("UTF-8")
-		pv.PropType = CA_idx
-		pv.ValType = SimpText
-		pattTree.AddAProp(gInfoPatt, pv)
-		// ADD AP
-		// TODO: make it vary with releases?
-		pv.StrValue = []byte("test-ahgo:0.8")
-		pv.PropType = AP_idx
-		pv.ValType = CompSimpText_simpText
-		pattTree.AddAProp(gInfoPatt, pv)
-		// ADD ST
-		pv.StrValue = []byte("1")
-		pv.PropType = ST_idx
-		pv.ValType = Num_0_3
-		pattTree.AddAProp(gInfoPatt, pv)
-
-		// TODO: support n x m boards. Add SZ
-		pv.StrValue = []byte(strconv.Itoa(int(szCol)))
-		pv.PropType = SZ_idx
-		pattTree.AddAProp(gInfoPatt, pv)
-		// Add HA
-		pv.StrValue = []byte(strconv.Itoa(ha))
-		pv.PropType = HA_idx
-		pattTree.AddAProp(gInfoPatt, pv)
-		pattTree.InitAbstHier(szCol, szRow, ah.StringLevel, true)
-		pattTree.SetHandicap(ha)
-		pv.StrValue = pattTree.PlaceHandicap(ha, int(szCol))
-
-		if pv.StrValue != nil {
-			// Add the AB for handicap points
-			pv.PropType = AB_idx
-			pv.ValType = ListOfStone
-			pattTree.AddAProp(gInfoPatt, pv)
-		}
 		//		fmt.Printf("Created pattTree: collPatt %d gInfoPatt %d curPatt %d\n", collPatt, gInfoPatt, curPatt)
 	} else {
-		collPatt = 1  // CollectionNode is child of RootNode
+		// CollectionNode (1) is child of RootNode
 		gInfoPatt = 2 // GameInfoNode is child of CollectionNode
 		curPatt = gInfoPatt
 		//		fmt.Printf("Reuse pattTree: collPatt %d gInfoPatt %d curPatt %d\n", collPatt, gInfoPatt, curPatt)
 	}
+	srcIdx = pattTree.addPatternSource(gamT.srcName)
 
 	// traverse gamT
 	// first visit the main line of play, via children (tail of circular linked list)
@@ -187,7 +256,7 @@ findFirst:
 			if (nMoves == 1) && (firstMoveColor == nodColr) {
 				//					str := strconv.Itoa(int(nodColr))
 				found = true
-				newNodLoc, trans = gamT.FindCanonicalRep(nodLoc, ah.BoardHandicapSymmetry[ha])
+				newNodLoc, trans = gamT.canonicalRep(nodLoc, ha)
 				// check that nod has no siblings
 				//					if nod.NextSib != nilTreeNodeIdx {
 				//						err.Add(ah.NoPos, "AddTeachingPattern: unsupported sibling of first node")
@@ -212,39 +281,35 @@ again:
 			if onMain && markBad {
 				var pv PropertyValue
 				// BM Bad Move
-				pv.StrValue = []byte("1")
-				pv.NextProp = nilPropIdx
-				pv.PropType = BM_idx
-				pv.ValType = Double
-				// TODO: something missing here. pv not saved before overwritten.
+				if q, _ := pattTree.MoveQuality(curPatt); q == MoveNotAnnotated {
+					pattTree.SetMoveQuality(curPatt, BadMove, 1)
+				}
 				// TR Triangle
 				pv.StrValue = SGFCoords(newNodLoc, gamT.IsFF4())
 				pv.NextProp = nilPropIdx
 				pv.PropType = TR_idx
 				pv.ValType = ListOfPoint
 				if newNodLoc != ah.PassNodeLoc {
-					pattTree.AddAProp(curPatt, pv)
+					addPropOnce(curPatt, pv)
 				}
 			}
 			if markGood {
 				var pv PropertyValue
-				// GB Good for Black or GW Good for White
-				pv.StrValue = []byte("1")
-				pv.NextProp = nilPropIdx
-				if nodColr == ah.Black {
-					pv.PropType = GB_idx
-				} else {
-					pv.PropType = GW_idx
+				// GB Good for Black or GW Good for White, unless already judged
+				if j, _ := pattTree.PositionJudgement(curPatt); j == PositionNotAnnotated {
+					if nodColr == ah.Black {
+						pattTree.SetPositionJudgement(curPatt, GoodForBlack, 1)
+					} else {
+						pattTree.SetPositionJudgement(curPatt, GoodForWhite, 1)
+					}
 				}
-				pv.ValType = Double
-				// TODO: something missing here. pv not saved before overwritten.
 				// SQ Square
 				pv.StrValue = SGFCoords(newNodLoc, gamT.IsFF4())
 				pv.NextProp = nilPropIdx
 				pv.PropType = SQ_idx
 				pv.ValType = ListOfPoint
 				if newNodLoc != ah.PassNodeLoc {
-					pattTree.AddAProp(curPatt, pv)
+					addPropOnce(curPatt, pv)
 				}
 				markGood = false
 			}
@@ -274,6 +339,11 @@ again:
 				}
 			}
 
+			// if the limit stops the pattern, record where the game continues
+			if limitReached && (gamT.treeNodes[curGam].Children != nilTreeNodeIdx) {
+				pattTree.addPatternCont(curPatt, srcIdx, patternDepth)
+			}
+
 			// move down to next generation
 			curGam = gamT.treeNodes[curGam].Children
 			if curGam != nilTreeNodeIdx {
@@ -282,8 +352,7 @@ again:
 				if len(err) != 0 {
 					return
 				}
-				c, r := ah.GetColRow(nodLoc)
-				newNodLoc = gamT.TransNodeLoc(trans, c, r)
+				newNodLoc = gamT.transNodeLoc(trans, nodLoc)
 			}
 		}
 	}
@@ -305,8 +374,7 @@ again:
 		if len(err) != 0 {
 			return
 		}
-		c, r := ah.GetColRow(nodLoc)
-		newNodLoc = gamT.TransNodeLoc(trans, c, r)
+		newNodLoc = gamT.transNodeLoc(trans, nodLoc)
 		found = true
 		goto again
 	}
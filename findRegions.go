@@ -0,0 +1,375 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/findRegions.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 2/11/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file walks SGF game trees, and records local patterns:
+ *	joseki (corner), side, quadrant, and center patterns.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+)
+
+// RegionType identifies the part of the board a local pattern comes from.
+type RegionType uint8
+
+const (
+	CornerRegion   RegionType = iota // joseki, half the lines from each edge
+	SideRegion                       // an edge, between the corners
+	QuadrantRegion                   // a quarter of the board, including the center lines
+	CenterRegion                     // the middle third of the board
+)
+
+var RegionTypeNames = []string{
+	"CornerRegion",
+	"SideRegion",
+	"QuadrantRegion",
+	"CenterRegion",
+}
+
+// maxRegionInst is the largest number of instances of a region on a board (4 corners, etc.)
+const maxRegionInst = 4
+
+// A regionPoint is a board point, located in one instance of a region.
+//	x, y are the local coordinates, in a frame of w by h points.
+//	offC, offR place the local frame on the board of the pattern tree.
+type regionPoint struct {
+	inst       int
+	x, y       int
+	w, h       int
+	offC, offR int
+}
+
+// regionLayout divides a board into the instances of a region type.
+type regionLayout struct {
+	typ        RegionType
+	nCol, nRow int
+}
+
+// regionSize returns the size of the board used for a pattern tree of type typ.
+// Side patterns are stored on the top edge, so a rectangular board is made square.
+func regionSize(typ RegionType, szCol ah.ColSize, szRow ah.RowSize) (ah.ColSize, ah.RowSize) {
+	if typ == SideRegion {
+		if int(szRow) > int(szCol) {
+			return ah.ColSize(szRow), szRow
+		}
+		return szCol, ah.RowSize(szCol)
+	}
+	return szCol, szRow
+}
+
+// symmetries returns the symmetries of a local frame of w by h points, for the region type.
+//	Corners and quadrants are symmetric about the diagonal.
+//	Sides have only the reflection along the edge.
+//	The center has all eight symmetries (four, if it is not square).
+func (lay *regionLayout) symmetries(w int, h int) []ah.BoardTrans {
+	switch lay.typ {
+	case CornerRegion, QuadrantRegion:
+		if w == h {
+			return []ah.BoardTrans{ah.T_IDENTITY, ah.T_FLP_BACK}
+		}
+	case SideRegion:
+		return []ah.BoardTrans{ah.T_IDENTITY, ah.T_FLP_VERT}
+	case CenterRegion:
+		if w == h {
+			return []ah.BoardTrans{ah.T_IDENTITY, ah.T_ROTA_090, ah.T_ROTA_180, ah.T_ROTA_270,
+				ah.T_FLP_SLAS, ah.T_FLP_VERT, ah.T_FLP_BACK, ah.T_FLP_HORI}
+		}
+		return []ah.BoardTrans{ah.T_IDENTITY, ah.T_ROTA_180, ah.T_FLP_VERT, ah.T_FLP_HORI}
+	}
+	return []ah.BoardTrans{ah.T_IDENTITY}
+}
+
+// locate returns the instances of the region that contain c, r.
+// Quadrants overlap on the center lines, so a point may be in more than one.
+func (lay *regionLayout) locate(c int, r int) (pts []regionPoint) {
+	nCol := lay.nCol
+	nRow := lay.nRow
+	switch lay.typ {
+	case CornerRegion, QuadrantRegion:
+		spanC := nCol / 2
+		spanR := nRow / 2
+		if lay.typ == QuadrantRegion {
+			spanC = (nCol + 1) / 2
+			spanR = (nRow + 1) / 2
+		}
+		for k := 0; k < maxRegionInst; k++ {
+			x, y := c, r
+			if k&1 != 0 {
+				x = nCol - 1 - c
+			}
+			if k&2 != 0 {
+				y = nRow - 1 - r
+			}
+			if x < spanC && y < spanR {
+				pts = append(pts, regionPoint{inst: k, x: x, y: y, w: spanC, h: spanR})
+			}
+		}
+	case SideRegion:
+		// top and bottom
+		lo := nCol / 4
+		depth := nRow / 3
+		if c >= lo && c < nCol-lo {
+			if r < depth {
+				pts = append(pts, regionPoint{inst: 0, x: c - lo, y: r, w: nCol - 2*lo, h: depth, offC: lo})
+			} else if r >= nRow-depth {
+				pts = append(pts, regionPoint{inst: 1, x: c - lo, y: nRow - 1 - r, w: nCol - 2*lo, h: depth, offC: lo})
+			}
+		}
+		// left and right
+		lo = nRow / 4
+		depth = nCol / 3
+		if r >= lo && r < nRow-lo {
+			if c < depth {
+				pts = append(pts, regionPoint{inst: 2, x: r - lo, y: c, w: nRow - 2*lo, h: depth, offC: lo})
+			} else if c >= nCol-depth {
+				pts = append(pts, regionPoint{inst: 3, x: r - lo, y: nCol - 1 - c, w: nRow - 2*lo, h: depth, offC: lo})
+			}
+		}
+	case CenterRegion:
+		loC := nCol / 3
+		loR := nRow / 3
+		if c >= loC && c < nCol-loC && r >= loR && r < nRow-loR {
+			pts = append(pts, regionPoint{inst: 0, x: c - loC, y: r - loR, w: nCol - 2*loC, h: nRow - 2*loR, offC: loC, offR: loR})
+		}
+	}
+	return pts
+}
+
+// transLocal applies a symmetry to x, y in a local frame of w by h points.
+// It is the counterpart of TransNodeLoc, for the frames that have no board
+// of their own: regions, rectangular boards, and pattern windows.
+// The rotations and diagonal flips are only used when w == h.
+func transLocal(t ah.BoardTrans, x int, y int, w int, h int) (int, int) {
+	switch t {
+	case ah.T_ROTA_090:
+		return y, w - 1 - x
+	case ah.T_ROTA_180:
+		return w - 1 - x, h - 1 - y
+	case ah.T_ROTA_270:
+		return h - 1 - y, x
+	case ah.T_FLP_SLAS:
+		return h - 1 - y, w - 1 - x
+	case ah.T_FLP_VERT:
+		return w - 1 - x, y
+	case ah.T_FLP_BACK:
+		return y, x
+	case ah.T_FLP_HORI:
+		return x, h - 1 - y
+	}
+	return x, y
+}
+
+// regionState holds the state of one instance of a region while a game is walked.
+//	The moves in a region are normalized:
+//		the region is mapped to the local frame,
+//		the symmetry is chosen move by move, keeping those that put the move on
+//		the least point (first by row, then by column), until one is left,
+//		the colors are swapped if needed, so the first move is Black.
+type regionState struct {
+	active   bool            // a move has been played in the region
+	done     bool            // the region has settled, or has setup stones
+	cands    []ah.BoardTrans // symmetries consistent with the moves so far
+	swapColr bool            // swap the colors
+	lastColr ah.PointStatus  // normalized color of the last local move
+	lastMove int             // index of the last local move, in the game
+	depth    int             // number of moves (including tenuki) in the sequence
+	curPatt  TreeNodeIdx     // current node in the pattern tree
+	win      ah.PointStatus  // normalized color of the winner of the game
+}
+
+// canonicalPoint narrows the candidate symmetries, and returns the transformed point.
+func (st *regionState) canonicalPoint(p regionPoint) (x int, y int) {
+	keep := st.cands[:0]
+	first := true
+	for _, t := range st.cands {
+		tx, ty := transLocal(t, p.x, p.y, p.w, p.h)
+		if first || ty < y || (ty == y && tx < x) {
+			keep = keep[:0]
+			x, y = tx, ty
+			first = false
+		}
+		if tx == x && ty == y {
+			keep = append(keep, t)
+		}
+	}
+	st.cands = keep
+	return x, y
+}
+
+// addMove finds or adds a child of the current pattern node, for nl played by colr.
+// The count of the node found or added is incremented.
+func (st *regionState) addMove(pattTree *GameTree, nl ah.NodeLoc, colr ah.PointStatus, src int) (err ah.ErrorList) {
+	idx := pattTree.FindChild(st.curPatt, nl)
+	if idx == nilTreeNodeIdx { // not found, add it
+		moveType := WhiteMoveNode
+		if colr == ah.Black {
+			moveType = BlackMoveNode
+		}
+		idx, err = pattTree.AddChild(st.curPatt, moveType, 0)
+		if len(err) != 0 {
+			return err
+		}
+		pattTree.treeNodes[idx].propListOrNodeLoc = PropIdx(nl)
+	}
+	pattTree.countPattern(idx, src, st.win)
+	st.curPatt = idx
+	st.depth += 1
+	st.lastColr = colr
+	return err
+}
+
+// finish ends the sequence in a region, and records where the game continues.
+func (st *regionState) finish(pattTree *GameTree, src int) {
+	st.done = true
+	pattTree.addPatternCont(st.curPatt, src, st.lastMove+1)
+}
+
+// AddRegionPatterns adds the local sequences from the main line of a game to a pattern tree.
+//	pattTree is the GameTree which holds the patterns of type regTyp.
+//		Note: may be nil on first use, or empty (see PatternOptions). Each region type needs its own tree.
+//	src is the name of the game, recorded with each node reached ("" => use GetSourceName)
+//	moveLimit is the maximum number of moves of the game to examine (0 => no limit)
+//	patternLimit is the maximum number of moves in a local sequence (0 => no limit)
+//
+//	Each instance of the region (four corners, four sides, etc.) is examined.
+//	The sequence is transformed to a single frame: corners and quadrants to the upper left,
+//	sides to the top edge, and reduced by the symmetries of the region.
+//	The colors are swapped if White played first in the region.
+//	When the same color plays twice in a row in a region, a pass (tenuki) is added for the other color.
+//	A sequence ends when the SettleMoves of pattTree are played elsewhere,
+//	or patternLimit is reached. Regions with setup (AB or AW) stones are skipped.
+//
+//	The count of each node in pattTree is incremented for each sequence that reaches it.
+//	The count of the GameInfoNode is the number of sequences added.
+//	The win counts are incremented based on the RE property of the game (swapped, if the colors are).
+//	When a sequence ends before the game does, a continuation (WC) to the game is recorded
+//	at the last node of the sequence.
+//
+// returns an Error if one is detected, and the updated pattTree
+func (gamT *GameTree) AddRegionPatterns(pattTree *GameTree, regTyp RegionType, src string,
+	moveLimit int, patternLimit int) (err ah.ErrorList, upPattTree *GameTree) {
+	var regions [maxRegionInst]regionState
+	var gInfoPatt TreeNodeIdx
+
+	nCol, nRow := gamT.boardSize()
+	lay := regionLayout{typ: regTyp, nCol: nCol, nRow: nRow}
+
+	// if pattTree doesn't exist, create it, and initialize it
+	if pattTree.isNewPatternTree() {
+		pCol, pRow := regionSize(regTyp, ah.ColSize(nCol), ah.RowSize(nRow))
+		pattTree, gInfoPatt, err = newPatternTree(pCol, pRow, 0, pattTree)
+		if len(err) != 0 {
+			return err, upPattTree
+		}
+		// write the tenuki passes as B[] and W[]
+		pattTree.SetFF([]byte("4"))
+	} else {
+		gInfoPatt = 2 // GameInfoNode is child of CollectionNode
+	}
+	if src == "" {
+		src = gamT.srcName
+	}
+	srcIdx := pattTree.addPatternSource(src)
+	win := gamT.winner()
+
+	// regions with setup stones are not recorded
+	for _, nl := range append(append(ah.NodeLocList(nil), gamT.aB...), gamT.aW...) {
+		c, r := ah.GetColRow(nl)
+		for _, p := range lay.locate(int(c), int(r)) {
+			regions[p.inst].done = true
+		}
+	}
+
+	settle := pattTree.PatternOpts.settleMoves()
+	movs := gamT.mainLineMoves()
+	for i, mov := range movs {
+		if (moveLimit > 0) && (i >= moveLimit) {
+			// the game continues after the limit
+			for k := range regions {
+				if regions[k].active && !regions[k].done {
+					regions[k].finish(pattTree, srcIdx)
+				}
+			}
+			break
+		}
+		// check for regions that have settled
+		for k := range regions {
+			if regions[k].active && !regions[k].done && (i-regions[k].lastMove > settle) {
+				regions[k].finish(pattTree, srcIdx)
+			}
+		}
+		if mov.loc == ah.PassNodeLoc {
+			continue
+		}
+		c, r := ah.GetColRow(mov.loc)
+		for _, p := range lay.locate(int(c), int(r)) {
+			st := &regions[p.inst]
+			if st.done {
+				continue
+			}
+			if !st.active {
+				st.active = true
+				st.cands = lay.symmetries(p.w, p.h)
+				st.swapColr = (mov.colr == ah.White)
+				st.lastColr = ah.White
+				st.curPatt = gInfoPatt
+				st.win = win
+				if st.swapColr && win != ah.Unocc {
+					st.win = ah.OppositeColor(win)
+				}
+				pattTree.countPattern(gInfoPatt, srcIdx, st.win)
+			}
+			colr := mov.colr
+			if st.swapColr {
+				colr = ah.OppositeColor(colr)
+			}
+			x, y := st.canonicalPoint(p)
+			if colr == st.lastColr { // the opponent played elsewhere
+				err = st.addMove(pattTree, ah.PassNodeLoc, ah.OppositeColor(colr), srcIdx)
+				if len(err) != 0 {
+					return err, upPattTree
+				}
+			}
+			nl := ah.MakeNodeLoc(ah.ColValue(x+p.offC), ah.RowValue(y+p.offR))
+			err = st.addMove(pattTree, nl, colr, srcIdx)
+			if len(err) != 0 {
+				return err, upPattTree
+			}
+			st.lastMove = i
+			if (patternLimit > 0) && (st.depth >= patternLimit) {
+				if i+1 < len(movs) { // the game continues
+					st.finish(pattTree, srcIdx)
+				} else {
+					st.done = true
+				}
+			}
+		}
+	}
+
+	upPattTree = pattTree
+	return err, upPattTree
+}
+
+// AddSidePatterns adds the side sequences from the main line of a game to a side pattern tree.
+// See AddRegionPatterns.
+func (gamT *GameTree) AddSidePatterns(sideTree *GameTree, src string, moveLimit int, patternLimit int) (err ah.ErrorList, upSideTree *GameTree) {
+	return gamT.AddRegionPatterns(sideTree, SideRegion, src, moveLimit, patternLimit)
+}
+
+// AddQuadrantPatterns adds the quadrant sequences from the main line of a game to a quadrant pattern tree.
+// See AddRegionPatterns.
+func (gamT *GameTree) AddQuadrantPatterns(quadTree *GameTree, src string, moveLimit int, patternLimit int) (err ah.ErrorList, upQuadTree *GameTree) {
+	return gamT.AddRegionPatterns(quadTree, QuadrantRegion, src, moveLimit, patternLimit)
+}
+
+// AddCenterPatterns adds the center sequences from the main line of a game to a center pattern tree.
+// See AddRegionPatterns.
+func (gamT *GameTree) AddCenterPatterns(centTree *GameTree, src string, moveLimit int, patternLimit int) (err ah.ErrorList, upCentTree *GameTree) {
+	return gamT.AddRegionPatterns(centTree, CenterRegion, src, moveLimit, patternLimit)
+}
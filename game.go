@@ -13,6 +13,7 @@ package sgf
 
 import (
 	"github.com/Ken1JF/ah"
+	"math"
 	"strconv"
 )
 
@@ -32,6 +33,7 @@ type Komi struct {
 // or the number of moves known. So this structure allows the specification of
 // a number that occurs in the comment, and a left separator: either "{" or "(".
 // The boolean "both" indicates that a matching right separator was found.
+// The parsed value is returned by GetResult.
 type Result struct {
 	val  []byte
 	com  []byte
@@ -41,12 +43,14 @@ type Result struct {
 }
 
 // Set functions for SGF properties:
+// These set only the fields of a Game that cache the properties, as they are parsed.
+// SetGameInfo changes the properties in the tree, and the cached fields.
 
-func (gam *GameTree) SetFF(f []byte) {
+func (gam *Game) SetFF(f []byte) {
 	gam.fF = f
 }
 
-func (gam *GameTree) IsFF4() (ret bool) {
+func (gam *Game) IsFF4() (ret bool) {
 	if gam.fF == nil {
 		ret = false
 	} else {
@@ -56,63 +60,73 @@ func (gam *GameTree) IsFF4() (ret bool) {
 	return ret
 }
 
-func (gam *GameTree) SetST(s []byte) {
+// boardSize returns the size of the board of the game.
+// With no SZ property, it is the FF[4] default, 19 by 19.
+func (gam *Game) boardSize() (nCol int, nRow int) {
+	szCol, szRow := gam.GetSize()
+	if szCol == 0 || szRow == 0 {
+		return 19, 19
+	}
+	return int(szCol), int(szRow)
+}
+
+func (gam *Game) SetST(s []byte) {
 	gam.sT = s
 }
 
-func (gam *GameTree) SetPB(p []byte) {
+func (gam *Game) SetPB(p []byte) {
 	gam.pB = p
 }
 
-func (gam *GameTree) GetPB() []byte {
+func (gam *Game) GetPB() []byte {
 	return gam.pB
 }
 
-func (gam *GameTree) SetBR(p []byte) {
+func (gam *Game) SetBR(p []byte) {
 	gam.bR = p
 }
 
-func (gam *GameTree) GetBR() []byte {
+func (gam *Game) GetBR() []byte {
 	return gam.bR
 }
 
-func (gam *GameTree) SetBT(p []byte) {
+func (gam *Game) SetBT(p []byte) {
 	gam.bT = p
 }
 
-func (gam *GameTree) GetPW() []byte {
+func (gam *Game) GetPW() []byte {
 	return gam.pW
 }
 
-func (gam *GameTree) SetPW(p []byte) {
+func (gam *Game) SetPW(p []byte) {
 	gam.pW = p
 }
 
-func (gam *GameTree) SetWR(p []byte) {
+func (gam *Game) SetWR(p []byte) {
 	gam.wR = p
 }
 
-func (gam *GameTree) GetWR() []byte {
+func (gam *Game) GetWR() []byte {
 	return gam.wR
 }
 
-func (gam *GameTree) SetWT(p []byte) {
+func (gam *Game) SetWT(p []byte) {
 	gam.wT = p
 }
 
-func (gam *GameTree) SetDT(p []byte) {
+func (gam *Game) SetDT(p []byte) {
 	gam.dT = p
 }
 
-func (gam *GameTree) SetPC(p []byte) {
+func (gam *Game) SetPC(p []byte) {
 	gam.pC = p
 }
 
-func (gam *GameTree) SetRU(p []byte) {
+func (gam *Game) SetRU(p []byte) {
 	gam.rU = p
 }
 
-func (gam *GameTree) SetRE(v []byte, b []byte, num int, ch byte, two bool) {
+func (gam *Game) SetRE(v []byte, b []byte, num int, ch byte, two bool) {
 	// TODO: set Board value
 	// set SGF details
 	gam.rE.val = v
@@ -122,39 +136,52 @@ func (gam *GameTree) SetRE(v []byte, b []byte, num int, ch byte, two bool) {
 	gam.rE.both = two
 }
 
-func (gam *GameTree) SetGC(p []byte) {
+// winner returns the color of the winner, from the RE property.
+// returns ah.Unocc for a draw, void game, unknown or missing result.
+func (gam *Game) winner() ah.PointStatus {
+	r, _ := gam.GetResult()
+	switch r.Winner {
+	case WinBlack:
+		return ah.Black
+	case WinWhite:
+		return ah.White
+	}
+	return ah.Unocc
+}
+
+func (gam *Game) SetGC(p []byte) {
 	gam.gC = p
 }
 
-func (gam *GameTree) SetEV(p []byte) {
+func (gam *Game) SetEV(p []byte) {
 	gam.eV = p
 }
 
-func (gam *GameTree) SetRO(p []byte) {
+func (gam *Game) SetRO(p []byte) {
 	gam.rO = p
 }
 
-func (gam *GameTree) SetAP(p []byte) {
+func (gam *Game) SetAP(p []byte) {
 	gam.aP = p
 }
 
-func (gam *GameTree) SetAN(p []byte) {
+func (gam *Game) SetAN(p []byte) {
 	gam.aN = p
 }
 
-func (gam *GameTree) SetCP(p []byte) {
+func (gam *Game) SetCP(p []byte) {
 	gam.cP = p
 }
 
-func (gam *GameTree) SetSO(p []byte) {
+func (gam *Game) SetSO(p []byte) {
 	gam.sO = p
 }
 
-func (gam *GameTree) SetUS(p []byte) {
+func (gam *Game) SetUS(p []byte) {
 	gam.uS = p
 }
 
-func (gam *GameTree) SetKM(p float32, k bool) {
+func (gam *Game) SetKM(p float32, k bool) {
 	// Set the Board value
 	if k {
 		gam.SetKomi(p)
@@ -167,11 +194,11 @@ func (gam *GameTree) SetKM(p float32, k bool) {
 	}
 }
 
-func (gam *GameTree) SetTM(p float32) {
+func (gam *Game) SetTM(p float32) {
 	gam.tM = p
 }
 
-func (gam *GameTree) DoAB(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
+func (gam *Game) DoAB(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
 	movType := ah.Unocc
 	gam.aB = append(gam.aB, p)
 	bp := &gam.Graphs[ah.PointLevel].Nodes[p]
@@ -196,7 +223,7 @@ func (gam *GameTree) DoAB(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
 	return err
 }
 
-func (gam *GameTree) DoAE(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
+func (gam *Game) DoAE(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
 	movType := ah.Unocc
 	//	gam.aE = append(gam.aE, p)
 	bp := &gam.Graphs[ah.PointLevel].Nodes[p]
@@ -215,7 +242,7 @@ func (gam *GameTree) DoAE(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
 	return err
 }
 
-func (gam *GameTree) DoAW(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
+func (gam *Game) DoAW(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
 	movType := ah.Unocc
 	gam.aW = append(gam.aW, p)
 	bp := &gam.Graphs[ah.PointLevel].Nodes[p]
@@ -240,119 +267,72 @@ func (gam *GameTree) DoAW(p ah.NodeLoc, doPlay bool) (err ah.ErrorList) {
 	return err
 }
 
-func (gam *GameTree) DoB(nl ah.NodeLoc, doPlay bool) (movN int, err ah.ErrorList) {
+func (gam *Game) DoB(nl ah.NodeLoc, doPlay bool) (movN int, err ah.ErrorList) {
 	movN, err = gam.DoBoardMove(nl, ah.Black, doPlay)
 	return movN, err
 }
 
-func (gam *GameTree) DoW(nl ah.NodeLoc, doPlay bool) (movN int, err ah.ErrorList) {
+func (gam *Game) DoW(nl ah.NodeLoc, doPlay bool) (movN int, err ah.ErrorList) {
 	movN, err = gam.DoBoardMove(nl, ah.White, doPlay)
 	return movN, err
 }
 
-func (gam *GameTree) SetOH(p []byte) {
+func (gam *Game) SetOH(p []byte) {
 	gam.oH = p
 }
 
-func (gam *GameTree) GetOH() []byte {
+func (gam *Game) GetOH() []byte {
 	return gam.oH
 }
 
-func (gam *GameTree) SetHA(p int) {
+func (gam *Game) SetHA(p int) {
 	gam.SetHandicap(p)
 }
 
-func (gam *GameTree) GetHA() int {
+func (gam *Game) GetHA() int {
 	return gam.GetHandicap()
 }
 
 // PlaceHandicap sets the handicap stones, and returns the list of points
-func (gam *GameTree) PlaceHandicap(n int, siz int) (pts []uint8) {
-	var lin, mid int
-	//	var nl ah.NodeLoc
-	//	var play bool = true
-	place := func(c int, r int) {
-		nl := ah.MakeNodeLoc(ah.ColValue(c), ah.RowValue(r))
+// The stones are placed on the fixed points of HandicapPoints, on a board of nCol by nRow.
+func (gam *Game) PlaceHandicap(n int, nCol int, nRow int) (pts []uint8) {
+	for _, nl := range HandicapPoints(n, nCol, nRow) {
 		gam.DoAB(nl, true)
 		pts = append(pts, SGFCoords(nl, gam.IsFF4())...)
 	}
-	lin = 3
-	if siz < 13 {
-		lin = 2
-	}
-	mid = (siz - 1) / 2
-	switch n {
-	case 0:
-	case 2:
-		place(siz-(lin+1), lin) // UpperRight
-		place(lin, siz-(lin+1)) // LowerLeft
-	case 3:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-	case 4:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-		place(lin, lin)                 // UpperLeft
-	case 5:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-		place(lin, lin)                 // UpperLeft
-		place(mid, mid)                 // mid point
-	case 6:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-		place(lin, lin)                 // UpperLeft
-		place(lin, mid)                 // mid point Left side
-		place(siz-(lin+1), mid)         // mid point Right side
-	case 7:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-		place(lin, lin)                 // UpperLeft
-		place(lin, mid)                 // mid point Left side
-		place(siz-(lin+1), mid)         // mid point Right side
-		place(mid, mid)                 // mid point
-	case 8:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-		place(lin, lin)                 // UpperLeft
-		place(lin, mid)                 // mid point Left side
-		place(siz-(lin+1), mid)         // mid point Right side
-		place(mid, lin)                 // mid point Top side
-		place(mid, siz-(lin+1))         // mid point Bottom side
-	case 9:
-		place(siz-(lin+1), lin)         // UpperRight
-		place(lin, siz-(lin+1))         // LowerLeft
-		place(siz-(lin+1), siz-(lin+1)) // LowerRight
-		place(lin, lin)                 // UpperLeft
-		place(lin, mid)                 // mid point Left side
-		place(siz-(lin+1), mid)         // mid point Right side
-		place(mid, lin)                 // mid point Top side
-		place(mid, siz-(lin+1))         // mid point Bottom side
-		place(mid, mid)                 // mid point
-	}
 	return pts
 }
 
-func (gam *GameTree) DoAR(p []byte) {
-	// TODO: implement Arrows
-	// string format is:
-	//	t1:h1t2:h2 ... tN:hN
-	// where ti is the tail of arrow i
-	// and hi is the head of arrow i
+// DoAR records the arrows of an AR property.
+// string format is:
+//	t1:h1t2:h2 ... tN:hN
+// where ti is the tail of arrow i
+// and hi is the head of arrow i
+func (gam *Game) DoAR(p []byte) (err ah.ErrorList) {
+	lines, err := parseLines(p)
+	for _, l := range lines {
+		if l.From == l.To {
+			err.Add(ah.NoPos, "DoAR: "+l.String()+" has length zero")
+		}
+		gam.aR = append(gam.aR, [2]ah.NodeLoc{l.From, l.To})
+	}
+	return err
 }
 
-func (gam *GameTree) DoLN(p []byte) {
-	// TODO: implement Lines
-	// string format is:
-	//	t1:h1t2:h2 ... tN:hN
-	// where ti is the start of line i
-	// and hi is the end of line i
+// DoLN records the lines of an LN property.
+// string format is:
+//	t1:h1t2:h2 ... tN:hN
+// where ti is the start of line i
+// and hi is the end of line i
+func (gam *Game) DoLN(p []byte) (err ah.ErrorList) {
+	lines, err := parseLines(p)
+	for _, l := range lines {
+		if l.From == l.To {
+			err.Add(ah.NoPos, "DoLN: "+l.String()+" has length zero")
+		}
+		gam.lN = append(gam.lN, [2]ah.NodeLoc{l.From, l.To})
+	}
+	return err
 }
 
 // CheckProperties is called after parsing an SGF file.
@@ -377,9 +357,17 @@ func (gam *GameTree) CheckProperties(gogod bool) (errstr string) {
 	// where B gets HA, and makes first move, on another handicap point.
 	// Check how many, and consider "correcting" the game record(s).)
 	// TODO: check PL?
+	// Handicap stones played as moves, and stones not on the fixed points,
+	// are checked by CheckHandicap, with the rules of RU.
 	handi := gam.GetHandicap()
 	mov1, set := gam.GetMov1()
-	if handi > 0 {
+	hs := gam.GetHandicapSetup()
+	if hs.PassFirst { // Black passed, to give White the first move
+		mov1, set = ah.White, true
+	}
+	if hs.FromMoves {
+		// no stones added
+	} else if handi > 0 {
 		if set {
 			if mov1 == ah.Black {
 				if handi != (len(gam.aW)-len(gam.aB)) &&
@@ -421,9 +409,60 @@ func (gam *GameTree) CheckProperties(gogod bool) (errstr string) {
 		}
 	}
 
-	// TODO: check OH (GoGoD specific property) for consistency with HA, and ranks.
+	// Check OH (GoGoD specific property) for consistency with HA,
+	// and HA for consistency with the ranks.
+	errstr = errstr + gam.CheckHandicap(RulesFromRU)
+	if oh := string(gam.GetOH()); oh != "" && handi > 0 {
+		if n, err := strconv.Atoi(oh); err == nil && n >= 2 && n != handi {
+			errstr = errstr + "OH not equal HA "
+		}
+	}
+	if handi >= 2 {
+		br, errB := gam.GetBlackRank()
+		wr, errW := gam.GetWhiteRank()
+		if errB == nil && errW == nil {
+			if d, ok := br.Diff(wr); ok && d >= 0 {
+				errstr = errstr + "HA, but B rank not below W rank "
+			}
+		}
+	}
+
+	// Check the game-info nodes: one on each path, and the RE of each.
+	// The checks above, and of RE below, are of the game-info of the main line.
+	errstr = errstr + gam.CheckGameInfo()
+
+	// Check the annotations of each node, for the exclusions of FF[4].
+	for n := range gam.treeNodes {
+		if len(gam.CheckAnnotations(TreeNodeIdx(n))) != 0 {
+			errstr = errstr + "annotations conflict at node " + strconv.Itoa(n) + " "
+		}
+	}
 
-	// TODO: check RE with evaluation of final position.
+	// Check RE, and its margin: a multiple of 0.5, except for a seki with the Ing rules,
+	// with the fraction of KM, and with the score of the final position, if it is marked with TB and TW.
+	if gam.rE.val != nil {
+		res, err := gam.GetResult()
+		if err != nil {
+			errstr = errstr + "RE not valid "
+		} else {
+			if rs, known := RuleSetFor(string(gam.rU)); !res.HalfPoints() && !(known && rs == RulesIng) {
+				errstr = errstr + "RE margin not a multiple of 0.5 "
+			}
+			if res.Reason == ReasonScore && gam.kM.set && gam.kM.known && res.HalfPoints() {
+				mFrac := math.Mod(float64(res.Margin), 1)
+				kFrac := math.Abs(math.Mod(float64(gam.kM.val), 1))
+				if mFrac != kFrac {
+					errstr = errstr + "RE margin not consistent with KM "
+				}
+			}
+			if gam.HasTerritory() {
+				sc, errS := gam.ScoreGame(nil)
+				if len(errS) == 0 && !sc.Matches(res) {
+					errstr = errstr + "RE not equal score of final position "
+				}
+			}
+		}
+	}
 
 	return errstr
 }
@@ -0,0 +1,567 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/gameIndex.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/3/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements an index of the game-info of an archive of SGF files,
+ *	which can be saved, updated when files change, and searched with queries
+ *	such as: PB="Go Seigen" AND DT>=1950 AND RE~"B+"
+ */
+
+package sgf
+
+import (
+	"encoding/gob"
+	"errors"
+	"github.com/Ken1JF/ah"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A GameRecord is the game-info of one game in a GameInfoIndex.
+// Each game of a collection has its own record.
+type GameRecord struct {
+	File    string // relative to the Dir of the index
+	Game    int    // the index of the game in the file, see SelectGame
+	ModTime int64  // modification time of the file when indexed, in nanoseconds
+	Size    int64  // size of the file when indexed
+	PB, BR  string
+	PW, WR  string
+	DT      string
+	EV, RO  string
+	RE, RU  string
+	KM      float32
+	KMSet   bool // false if there was no KM property, or KM[?]
+	HA      int
+	Moves   int // number of moves in the main line
+}
+
+// A GameInfoIndex is the game-info of the .sgf files in Dir, and its subdirectories.
+// The Games are in order of their File, and of the Game in each file.
+type GameInfoIndex struct {
+	Dir     string
+	Games   []GameRecord
+	aliases *PlayerAliases // not saved, see SetAliases
+}
+
+// SetAliases sets the table used to resolve the names of players in queries.
+// It is not saved with the index.
+func (idx *GameInfoIndex) SetAliases(pa *PlayerAliases) {
+	idx.aliases = pa
+}
+
+// Type byFile implements the sort.Interface based on the File name,
+// and the index of the Game in the file.
+type byFile []GameRecord
+
+func (bf byFile) Len() int      { return len(bf) }
+func (bf byFile) Swap(i, j int) { bf[i], bf[j] = bf[j], bf[i] }
+func (bf byFile) Less(i, j int) bool {
+	if bf[i].File != bf[j].File {
+		return bf[i].File < bf[j].File
+	}
+	return bf[i].Game < bf[j].Game
+}
+
+// newGameRecord returns the game-info of the selected game of gamT.
+func newGameRecord(file string, gamT *GameTree) (rec GameRecord) {
+	rec.File = file
+	rec.Game = gamT.SelectedGame()
+	rec.PB = string(gamT.pB)
+	rec.BR = string(gamT.bR)
+	rec.PW = string(gamT.pW)
+	rec.WR = string(gamT.wR)
+	rec.DT = string(gamT.dT)
+	rec.EV = string(gamT.eV)
+	rec.RO = string(gamT.rO)
+	rec.RE = string(gamT.rE.val)
+	rec.RU = string(gamT.rU)
+	rec.KM = gamT.kM.val
+	rec.KMSet = gamT.kM.set && gamT.kM.known
+	rec.HA = gamT.GetHA()
+	rec.Moves = len(gamT.mainLineMoves())
+	return rec
+}
+
+// BuildGameInfoIndex parses the .sgf files in dir, and its subdirectories,
+// and returns an index of their game-info.
+// A file with errors is reported. Its games are indexed, unless it has none.
+func BuildGameInfoIndex(dir string) (idx *GameInfoIndex, errs ah.ErrorList) {
+	idx = new(GameInfoIndex)
+	idx.Dir = dir
+	_, _, _, errs = idx.Update()
+	return idx, errs
+}
+
+// Update brings the index up to date with the files in idx.Dir.
+// Only new files, and files whose size or modification time have changed, are parsed.
+// Each game of a file is indexed. The errors of a file are reported, and the games
+// that were parsed are indexed; only a file that cannot be read, or has no games, is dropped.
+// Games whose files have been removed are dropped from the index.
+// returns the number of games added, changed, and removed.
+func (idx *GameInfoIndex) Update() (added int, changed int, removed int, errs ah.ErrorList) {
+	old := make(map[string][]int, len(idx.Games))
+	for i, rec := range idx.Games {
+		old[rec.File] = append(old[rec.File], i)
+	}
+	seen := make(map[string]bool, len(idx.Games))
+	var games []GameRecord
+	walkErr := filepath.Walk(idx.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs.Add(ah.NoPos, path+": "+err.Error())
+			return nil
+		}
+		if info.IsDir() || !isSGFName(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(idx.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		seen[rel] = true
+		oldRecs := old[rel]
+		if len(oldRecs) > 0 && idx.Games[oldRecs[0]].ModTime == info.ModTime().UnixNano() &&
+			idx.Games[oldRecs[0]].Size == info.Size() {
+			for _, i := range oldRecs {
+				games = append(games, idx.Games[i])
+			}
+			return nil
+		}
+		prsr, errL := ParseFile(path, nil, 0, 0)
+		if len(errL) != 0 {
+			errs.Add(ah.NoPos, path+": "+errL.Error())
+		}
+		nGames := 0
+		if prsr != nil {
+			nGames = prsr.NumGames()
+		}
+		for g := 0; g < nGames; g++ {
+			prsr.SelectGame(g)
+			rec := newGameRecord(rel, &prsr.GameTree)
+			rec.ModTime = info.ModTime().UnixNano()
+			rec.Size = info.Size()
+			games = append(games, rec)
+			if g < len(oldRecs) {
+				changed += 1
+			} else {
+				added += 1
+			}
+		}
+		if len(oldRecs) > nGames {
+			removed += len(oldRecs) - nGames
+		}
+		return nil
+	})
+	if walkErr != nil {
+		errs.Add(ah.NoPos, idx.Dir+": "+walkErr.Error())
+	}
+	for _, rec := range idx.Games {
+		if !seen[rec.File] {
+			removed += 1
+		}
+	}
+	sort.Sort(byFile(games))
+	idx.Games = games
+	return added, changed, removed, errs
+}
+
+// WriteIndex saves the index in a file.
+func (idx *GameInfoIndex) WriteIndex(fileName string) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return errors.New("WriteIndex: " + fileName + " " + err.Error())
+	}
+	defer f.Close()
+	err = gob.NewEncoder(f).Encode(idx)
+	if err != nil {
+		return errors.New("WriteIndex: " + fileName + " " + err.Error())
+	}
+	return f.Close()
+}
+
+// ReadGameInfoIndex reads an index saved by WriteIndex.
+func ReadGameInfoIndex(fileName string) (idx *GameInfoIndex, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.New("ReadGameInfoIndex: " + fileName + " " + err.Error())
+	}
+	defer f.Close()
+	idx = new(GameInfoIndex)
+	err = gob.NewDecoder(f).Decode(idx)
+	if err != nil {
+		return nil, errors.New("ReadGameInfoIndex: " + fileName + " " + err.Error())
+	}
+	return idx, nil
+}
+
+// Query returns the games that match the query q. See ParseGameQuery.
+// If the index has aliases (see SetAliases), players are compared by their canonical names.
+func (idx *GameInfoIndex) Query(q string) (recs []GameRecord, err ah.ErrorList) {
+	gq, err := ParseGameQueryAliases(q, idx.aliases)
+	if len(err) != 0 {
+		return recs, err
+	}
+	for i := range idx.Games {
+		if gq.Match(&idx.Games[i]) {
+			recs = append(recs, idx.Games[i])
+		}
+	}
+	return recs, err
+}
+
+// A GameQuery is a parsed query, which can be matched against GameRecords.
+type GameQuery struct {
+	src   string
+	match func(rec *GameRecord) bool
+}
+
+// Match returns true if rec satisfies the query.
+func (gq *GameQuery) Match(rec *GameRecord) bool {
+	return gq.match(rec)
+}
+
+// String returns the text of the query.
+func (gq *GameQuery) String() string {
+	return gq.src
+}
+
+// queryField describes a field of a GameRecord that can be used in a query.
+//	str returns the value of a string field, num the value of a numeric field.
+//	num returns false if the field has no value.
+type queryField struct {
+	str func(rec *GameRecord) string
+	num func(rec *GameRecord) (float64, bool)
+}
+
+var queryFields = map[string]queryField{
+	"PB":    {str: func(r *GameRecord) string { return r.PB }},
+	"BR":    {str: func(r *GameRecord) string { return r.BR }},
+	"PW":    {str: func(r *GameRecord) string { return r.PW }},
+	"WR":    {str: func(r *GameRecord) string { return r.WR }},
+	"DT":    {str: func(r *GameRecord) string { return r.DT }},
+	"EV":    {str: func(r *GameRecord) string { return r.EV }},
+	"RO":    {str: func(r *GameRecord) string { return r.RO }},
+	"RE":    {str: func(r *GameRecord) string { return r.RE }},
+	"RU":    {str: func(r *GameRecord) string { return r.RU }},
+	"FILE":  {str: func(r *GameRecord) string { return r.File }},
+	"KM":    {num: func(r *GameRecord) (float64, bool) { return float64(r.KM), r.KMSet }},
+	"HA":    {num: func(r *GameRecord) (float64, bool) { return float64(r.HA), true }},
+	"MOVES": {num: func(r *GameRecord) (float64, bool) { return float64(r.Moves), true }},
+}
+
+// Token kinds of the query language.
+type queryTokKind uint8
+
+const (
+	qEOF queryTokKind = iota
+	qWord
+	qString
+	qOper
+	qLParen
+	qRParen
+)
+
+type queryToken struct {
+	kind queryTokKind
+	text string
+	pos  int // byte offset in the query
+}
+
+// scanQuery splits a query into tokens.
+func scanQuery(q string) (toks []queryToken, err ah.ErrorList) {
+	i := 0
+	for i < len(q) {
+		ch := q[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i += 1
+		case ch == '(':
+			toks = append(toks, queryToken{qLParen, "(", i})
+			i += 1
+		case ch == ')':
+			toks = append(toks, queryToken{qRParen, ")", i})
+			i += 1
+		case ch == '=' || ch == '~':
+			toks = append(toks, queryToken{qOper, q[i : i+1], i})
+			i += 1
+		case ch == '<' || ch == '>' || ch == '!':
+			if i+1 < len(q) && q[i+1] == '=' {
+				toks = append(toks, queryToken{qOper, q[i : i+2], i})
+				i += 2
+			} else if ch == '!' {
+				err.Add(ah.NoPos, "query: \"!\" must be followed by \"=\", at "+strconv.Itoa(i))
+				return toks, err
+			} else {
+				toks = append(toks, queryToken{qOper, q[i : i+1], i})
+				i += 1
+			}
+		case ch == '"':
+			var val []byte
+			j := i + 1
+			for ; j < len(q) && q[j] != '"'; j++ {
+				if q[j] == '\\' && j+1 < len(q) {
+					j += 1
+				}
+				val = append(val, q[j])
+			}
+			if j >= len(q) {
+				err.Add(ah.NoPos, "query: missing closing quote, at "+strconv.Itoa(i))
+				return toks, err
+			}
+			toks = append(toks, queryToken{qString, string(val), i})
+			i = j + 1
+		default:
+			j := i
+			for j < len(q) && !strings.ContainsRune(" \t\n\r()=~<>!\"", rune(q[j])) {
+				j += 1
+			}
+			toks = append(toks, queryToken{qWord, q[i:j], i})
+			i = j
+		}
+	}
+	toks = append(toks, queryToken{qEOF, "", len(q)})
+	return toks, err
+}
+
+// queryParser is a recursive descent parser for the query language.
+type queryParser struct {
+	toks    []queryToken
+	pos     int
+	err     ah.ErrorList
+	aliases *PlayerAliases
+}
+
+func (qp *queryParser) peek() queryToken {
+	return qp.toks[qp.pos]
+}
+
+func (qp *queryParser) next() queryToken {
+	t := qp.toks[qp.pos]
+	if t.kind != qEOF {
+		qp.pos += 1
+	}
+	return t
+}
+
+func (qp *queryParser) isKeyword(kw string) bool {
+	t := qp.peek()
+	return t.kind == qWord && strings.ToUpper(t.text) == kw
+}
+
+func (qp *queryParser) errorAt(t queryToken, msg string) {
+	if len(qp.err) == 0 {
+		qp.err.Add(ah.NoPos, "query: "+msg+", at "+strconv.Itoa(t.pos))
+	}
+}
+
+// parseOr parses: and { OR and }
+func (qp *queryParser) parseOr() func(*GameRecord) bool {
+	left := qp.parseAnd()
+	for len(qp.err) == 0 && qp.isKeyword("OR") {
+		qp.next()
+		l, r := left, qp.parseAnd()
+		left = func(rec *GameRecord) bool { return l(rec) || r(rec) }
+	}
+	return left
+}
+
+// parseAnd parses: not { AND not }
+func (qp *queryParser) parseAnd() func(*GameRecord) bool {
+	left := qp.parseNot()
+	for len(qp.err) == 0 && qp.isKeyword("AND") {
+		qp.next()
+		l, r := left, qp.parseNot()
+		left = func(rec *GameRecord) bool { return l(rec) && r(rec) }
+	}
+	return left
+}
+
+// parseNot parses: NOT not | ( or ) | term
+func (qp *queryParser) parseNot() func(*GameRecord) bool {
+	if qp.isKeyword("NOT") {
+		qp.next()
+		e := qp.parseNot()
+		return func(rec *GameRecord) bool { return !e(rec) }
+	}
+	if qp.peek().kind == qLParen {
+		qp.next()
+		e := qp.parseOr()
+		if t := qp.next(); t.kind != qRParen {
+			qp.errorAt(t, "expected \")\"")
+		}
+		return e
+	}
+	return qp.parseTerm()
+}
+
+// parseTerm parses: field operator value
+func (qp *queryParser) parseTerm() func(*GameRecord) bool {
+	never := func(rec *GameRecord) bool { return false }
+	ft := qp.next()
+	if ft.kind != qWord {
+		qp.errorAt(ft, "expected a field name")
+		return never
+	}
+	name := strings.ToUpper(ft.text)
+	fld, ok := queryFields[name]
+	if !ok {
+		qp.errorAt(ft, "unknown field \""+ft.text+"\"")
+		return never
+	}
+	ot := qp.next()
+	if ot.kind != qOper {
+		qp.errorAt(ot, "expected an operator after "+name)
+		return never
+	}
+	op := ot.text
+	vt := qp.next()
+	if vt.kind != qWord && vt.kind != qString {
+		qp.errorAt(vt, "expected a value after "+name+op)
+		return never
+	}
+	val := vt.text
+
+	if fld.num != nil {
+		if op == "~" {
+			qp.errorAt(ot, "\"~\" can not be used with "+name)
+			return never
+		}
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			qp.errorAt(vt, "expected a number after "+name+op)
+			return never
+		}
+		return func(rec *GameRecord) bool {
+			f, has := fld.num(rec)
+			if !has {
+				return false
+			}
+			c := 0
+			if f < v {
+				c = -1
+			} else if f > v {
+				c = 1
+			}
+			return compareResult(op, c)
+		}
+	}
+	// Players are compared by their canonical names, and "~" also matches the name as recorded
+	str := fld.str
+	if (name == "PB" || name == "PW") && qp.aliases != nil {
+		pa := qp.aliases
+		str = func(rec *GameRecord) string { return pa.Resolve(fld.str(rec)) }
+		if op == "~" {
+			lv := strings.ToLower(val)
+			return func(rec *GameRecord) bool {
+				return strings.Contains(strings.ToLower(fld.str(rec)), lv) ||
+					strings.Contains(strings.ToLower(str(rec)), lv)
+			}
+		}
+		val = pa.Resolve(val)
+	}
+	if op == "~" {
+		lv := strings.ToLower(val)
+		return func(rec *GameRecord) bool {
+			return strings.Contains(strings.ToLower(str(rec)), lv)
+		}
+	}
+	if name == "DT" {
+		return qp.dateTerm(vt, op, val)
+	}
+	return func(rec *GameRecord) bool {
+		s := str(rec)
+		c := 0
+		if s < val {
+			c = -1
+		} else if s > val {
+			c = 1
+		}
+		return compareResult(op, c)
+	}
+}
+
+// dateTerm returns the test of DT op val. Dates are compared by their DateKey,
+// to the precision of val, so DT=1950 matches 1950-03-01.
+// A game whose DT cannot be parsed does not match.
+func (qp *queryParser) dateTerm(vt queryToken, op string, val string) func(*GameRecord) bool {
+	key := DateKey(val)
+	if key == "" {
+		qp.errorAt(vt, "expected a date after DT"+op)
+		return func(rec *GameRecord) bool { return false }
+	}
+	key = strings.TrimSuffix(strings.TrimSuffix(key, "-00"), "-00")
+	return func(rec *GameRecord) bool {
+		s := DateKey(rec.DT)
+		if s == "" {
+			return false
+		}
+		if len(s) > len(key) {
+			s = s[:len(key)]
+		}
+		c := 0
+		if s < key {
+			c = -1
+		} else if s > key {
+			c = 1
+		}
+		return compareResult(op, c)
+	}
+}
+
+// compareResult returns the result of the comparison op, given c,
+// which is -1, 0, or 1 as the field is less than, equal to, or greater than the value.
+func compareResult(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// ParseGameQuery parses a query of the game-info of a GameInfoIndex.
+// A query is made of terms, combined with AND, OR, NOT, and parentheses.
+// AND binds more tightly than OR. A term is: field operator value
+//	field is one of PB, BR, PW, WR, DT, EV, RO, RE, RU, KM, HA, MOVES, or FILE
+//	operator is one of = != < <= > >= or ~ (contains, ignoring case)
+//	value is a word, or a string in double quotes.
+// KM, HA, and MOVES are compared as numbers. Other fields are compared as strings.
+// DT is compared as a date, to the precision of the value, so DT>=1950 includes 1950-01-01.
+//
+// For example: PB="Go Seigen" AND DT>=1950 AND RE~"B+"
+func ParseGameQuery(q string) (gq *GameQuery, err ah.ErrorList) {
+	return ParseGameQueryAliases(q, nil)
+}
+
+// ParseGameQueryAliases is like ParseGameQuery, but PB and PW are compared by
+// their canonical names, as resolved by pa. "~" matches either the canonical name,
+// or the name as recorded.
+func ParseGameQueryAliases(q string, pa *PlayerAliases) (gq *GameQuery, err ah.ErrorList) {
+	toks, err := scanQuery(q)
+	if len(err) != 0 {
+		return nil, err
+	}
+	qp := &queryParser{toks: toks, aliases: pa}
+	m := qp.parseOr()
+	if len(qp.err) == 0 && qp.peek().kind != qEOF {
+		qp.errorAt(qp.peek(), "unexpected \""+qp.peek().text+"\"")
+	}
+	if len(qp.err) != 0 {
+		return nil, qp.err
+	}
+	return &GameQuery{src: q, match: m}, err
+}
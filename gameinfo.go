@@ -0,0 +1,400 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/gameinfo.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/26/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements GameInfo: the game-info properties of a node,
+ *	read from the properties of the node, and written back to them,
+ *	keeping the fields of GameTree that cache the game-info up to date.
+ *	Game-info may be in any node, so it is resolved for each path:
+ *	each node is governed by the nearest game-info node above it.
+ */
+
+package sgf
+
+import (
+	"strconv"
+
+	"github.com/Ken1JF/ah"
+)
+
+// A GameInfo is the game-info properties of one node, as SGF values.
+// An empty string is a property that is not set.
+//	Node is the node that holds the properties.
+type GameInfo struct {
+	Node TreeNodeIdx
+	AN   string // Annotation
+	BR   string // Black rank
+	BT   string // Black team
+	CP   string // Copyright
+	DT   string // Date
+	EV   string // Event
+	GC   string // Game comment
+	GN   string // Game name
+	HA   string // Handicap
+	KM   string // Komi
+	OH   string // Old handicap (GoGoD)
+	ON   string // Opening
+	OT   string // Overtime
+	PB   string // Player Black
+	PC   string // Place
+	PW   string // Player White
+	RE   string // Result
+	RO   string // Round
+	RU   string // Rules
+	SO   string // Source
+	TM   string // Timelimit
+	US   string // User
+	WR   string // White rank
+	WT   string // White team
+}
+
+// gameInfoFields are the game-info properties, and their fields in a GameInfo.
+var gameInfoFields = []struct {
+	prop  PropertyDefIdx
+	field func(gi *GameInfo) *string
+}{
+	{AN_idx, func(gi *GameInfo) *string { return &gi.AN }},
+	{BR_idx, func(gi *GameInfo) *string { return &gi.BR }},
+	{BT_idx, func(gi *GameInfo) *string { return &gi.BT }},
+	{CP_idx, func(gi *GameInfo) *string { return &gi.CP }},
+	{DT_idx, func(gi *GameInfo) *string { return &gi.DT }},
+	{EV_idx, func(gi *GameInfo) *string { return &gi.EV }},
+	{GC_idx, func(gi *GameInfo) *string { return &gi.GC }},
+	{GN_idx, func(gi *GameInfo) *string { return &gi.GN }},
+	{HA_idx, func(gi *GameInfo) *string { return &gi.HA }},
+	{KM_idx, func(gi *GameInfo) *string { return &gi.KM }},
+	{OH_idx, func(gi *GameInfo) *string { return &gi.OH }},
+	{ON_idx, func(gi *GameInfo) *string { return &gi.ON }},
+	{OT_idx, func(gi *GameInfo) *string { return &gi.OT }},
+	{PB_idx, func(gi *GameInfo) *string { return &gi.PB }},
+	{PC_idx, func(gi *GameInfo) *string { return &gi.PC }},
+	{PW_idx, func(gi *GameInfo) *string { return &gi.PW }},
+	{RE_idx, func(gi *GameInfo) *string { return &gi.RE }},
+	{RO_idx, func(gi *GameInfo) *string { return &gi.RO }},
+	{RU_idx, func(gi *GameInfo) *string { return &gi.RU }},
+	{SO_idx, func(gi *GameInfo) *string { return &gi.SO }},
+	{TM_idx, func(gi *GameInfo) *string { return &gi.TM }},
+	{US_idx, func(gi *GameInfo) *string { return &gi.US }},
+	{WR_idx, func(gi *GameInfo) *string { return &gi.WR }},
+	{WT_idx, func(gi *GameInfo) *string { return &gi.WT }},
+}
+
+// isGameInfoProp returns true for the game-info properties of a GameInfo.
+func isGameInfoProp(pt PropertyDefIdx) bool {
+	for _, f := range gameInfoFields {
+		if f.prop == pt {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGameInfo returns true if node n has a game-info property.
+func (gamT *GameTree) hasGameInfo(n TreeNodeIdx) bool {
+	for _, f := range gameInfoFields {
+		if gamT.findProp(n, f.prop) != nilPropIdx {
+			return true
+		}
+	}
+	return false
+}
+
+// GameInfoNodes returns the nodes of gamT that hold game-info properties, in node order.
+// The GameInfoNode of a game is included even if it has none, so each game has one.
+func (gamT *GameTree) GameInfoNodes() (nodes []TreeNodeIdx) {
+	for i := range gamT.treeNodes {
+		n := TreeNodeIdx(i)
+		if gamT.treeNodes[n].TNodType == GameInfoNode || gamT.hasGameInfo(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// GetGameInfo returns the game-info properties of node n.
+func (gamT *GameTree) GetGameInfo(n TreeNodeIdx) (gi GameInfo) {
+	gi.Node = n
+	for _, f := range gameInfoFields {
+		*f.field(&gi) = gamT.propString(n, f.prop)
+	}
+	return gi
+}
+
+// gameInfoAbove returns the nearest ancestor of node n, up to the GameInfoNode of its game,
+// that holds game-info properties, or nilTreeNodeIdx if there is none.
+func (gamT *GameTree) gameInfoAbove(n TreeNodeIdx) TreeNodeIdx {
+	if gamT.treeNodes[n].TNodType == GameInfoNode {
+		return nilTreeNodeIdx
+	}
+	for nod := gamT.treeNodes[n].Parent; nod != nilTreeNodeIdx; nod = gamT.treeNodes[nod].Parent {
+		if gamT.hasGameInfo(nod) {
+			return nod
+		}
+		if gamT.treeNodes[nod].TNodType == GameInfoNode {
+			break
+		}
+	}
+	return nilTreeNodeIdx
+}
+
+// GoverningGameInfo returns the game-info node that governs node n:
+// the nearest of n and its ancestors that holds game-info properties,
+// or else the GameInfoNode of its game.
+// FF[4] allows game-info properties in any node, but only one such node on each path.
+// nilTreeNodeIdx is returned for nodes that are not in a game.
+func (gamT *GameTree) GoverningGameInfo(n TreeNodeIdx) TreeNodeIdx {
+	if int(n) >= len(gamT.treeNodes) {
+		return nilTreeNodeIdx
+	}
+	for nod := n; nod != nilTreeNodeIdx; nod = gamT.treeNodes[nod].Parent {
+		if gamT.treeNodes[nod].TNodType == GameInfoNode || gamT.hasGameInfo(nod) {
+			return nod
+		}
+	}
+	return nilTreeNodeIdx
+}
+
+// GameInfoAt returns the game-info that applies at node n: the properties of n
+// and of its ancestors, up to the GameInfoNode of its game, the nearest first.
+// Node is the governing game-info node of n.
+func (gamT *GameTree) GameInfoAt(n TreeNodeIdx) (gi GameInfo) {
+	gi.Node = gamT.GoverningGameInfo(n)
+	if gi.Node == nilTreeNodeIdx {
+		return gi
+	}
+	for nod := n; nod != nilTreeNodeIdx; nod = gamT.treeNodes[nod].Parent {
+		for _, f := range gameInfoFields {
+			if fld := f.field(&gi); *fld == "" {
+				*fld = gamT.propString(nod, f.prop)
+			}
+		}
+		if gamT.treeNodes[nod].TNodType == GameInfoNode {
+			break
+		}
+	}
+	return gi
+}
+
+// A LeafGameInfo pairs a leaf of a GameTree with its governing game-info node.
+type LeafGameInfo struct {
+	Leaf     TreeNodeIdx
+	GameInfo TreeNodeIdx
+}
+
+// LeafGameInfos returns the leaves of the games of gamT, in node order,
+// each with its governing game-info node.
+func (gamT *GameTree) LeafGameInfos() (leaves []LeafGameInfo) {
+	for i := range gamT.treeNodes {
+		n := TreeNodeIdx(i)
+		if gamT.treeNodes[n].Children != nilTreeNodeIdx {
+			continue
+		}
+		if g := gamT.GoverningGameInfo(n); g != nilTreeNodeIdx {
+			leaves = append(leaves, LeafGameInfo{n, g})
+		}
+	}
+	return leaves
+}
+
+// mainLineEnd returns the last node of the main line from node n.
+func (gamT *GameTree) mainLineEnd(n TreeNodeIdx) TreeNodeIdx {
+	for ch := gamT.firstChild(n); ch != nilTreeNodeIdx; ch = gamT.firstChild(n) {
+		n = ch
+	}
+	return n
+}
+
+// cacheMainLineGameInfo sets the fields of gamT that cache the game-info
+// to the game-info of the main line of the game at GameInfoNode g.
+// It is needed when the game has game-info nodes below g, since the Parser
+// caches the game-info of each node as it is parsed.
+func (gamT *GameTree) cacheMainLineGameInfo(g TreeNodeIdx) (errs ah.ErrorList) {
+	gi := gamT.GameInfoAt(gamT.mainLineEnd(g))
+	for _, f := range gameInfoFields {
+		errs = append(errs, gamT.cacheGameInfo(f.prop, []byte(*f.field(&gi)))...)
+	}
+	return errs
+}
+
+// CheckGameInfo returns a message for each problem with the game-info nodes of gamT:
+// a game-info node below another on the same path, and an RE that cannot be parsed.
+// The game-info of the main line of the selected game is also checked by CheckProperties.
+func (gamT *GameTree) CheckGameInfo() (errstr string) {
+	for _, n := range gamT.GameInfoNodes() {
+		if above := gamT.gameInfoAbove(n); above != nilTreeNodeIdx {
+			errstr = errstr + "game-info at node " + strconv.Itoa(int(n)) +
+				" below game-info at node " + strconv.Itoa(int(above)) + " "
+		}
+		if re := gamT.propString(n, RE_idx); re != "" {
+			if _, _, err := ParseResult(re); err != nil {
+				errstr = errstr + "RE not valid at node " + strconv.Itoa(int(n)) + " "
+			}
+		}
+	}
+	return errstr
+}
+
+// SetGameInfo writes the game-info properties of gi to the node gi.Node:
+// each property that differs is replaced, and an empty value removes it.
+// The fields of the Game of gi.Node that cache the game-info (see GetPB, GetResult,
+// GetHandicap, ...) are updated, from the game-info of the main line of the game.
+// Errors are returned, and the node is not changed, if a changed value cannot be parsed.
+func (gamT *GameTree) SetGameInfo(gi GameInfo) (errs ah.ErrorList) {
+	n := gi.Node
+	if int(n) >= len(gamT.treeNodes) {
+		errs.Add(ah.NoPos, "SetGameInfo: no node "+strconv.Itoa(int(n)))
+		return errs
+	}
+	// check the changed values, on any node, before changing the tree
+	changed := make([]bool, len(gameInfoFields))
+	for i, f := range gameInfoFields {
+		val := *f.field(&gi)
+		if val != gamT.propString(n, f.prop) {
+			changed[i] = true
+			errs = append(errs, checkGameInfoValue(f.prop, val)...)
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	for i, f := range gameInfoFields {
+		if !changed[i] {
+			continue
+		}
+		val := *f.field(&gi)
+		gamT.removeProps(n, f.prop)
+		if val != "" {
+			var pv PropertyValue
+			pv.PropType = f.prop
+			pv.StrValue = []byte(val)
+			pv.ValType = GetProperty(f.prop).Value
+			pv.NextProp = nilPropIdx
+			errs = append(errs, gamT.AddAProp(n, pv)...)
+		}
+	}
+	if gam := gamT.GetGame(gamT.gameIndex(n)); gam != nil {
+		mainInfo := gamT.GameInfoAt(gamT.mainLineEnd(gam.InfoNode))
+		for i, f := range gameInfoFields {
+			if changed[i] {
+				errs = append(errs, gam.cacheGameInfo(f.prop, []byte(*f.field(&mainInfo)))...)
+			}
+		}
+	}
+	return errs
+}
+
+// checkGameInfoValue returns an error if val, the value of the game-info property pt,
+// cannot be parsed, as it is by cacheGameInfo. An empty val is valid.
+func checkGameInfoValue(pt PropertyDefIdx, val string) (errs ah.ErrorList) {
+	if val == "" {
+		return nil
+	}
+	switch pt {
+	case HA_idx:
+		if _, err := strconv.Atoi(val); err != nil {
+			errs.Add(ah.NoPos, "SetGameInfo: HA "+err.Error())
+		}
+	case KM_idx:
+		if _, err := strconv.ParseFloat(val, 64); err != nil && val != "?" {
+			errs.Add(ah.NoPos, "SetGameInfo: KM "+err.Error())
+		}
+	case TM_idx:
+		if _, err := ParseTimeControl(val, ""); err != nil {
+			errs.Add(ah.NoPos, "SetGameInfo: TM "+err.Error())
+		}
+	case RE_idx:
+		if _, _, err := ParseResult(val); err != nil {
+			errs.Add(ah.NoPos, "SetGameInfo: RE "+err.Error())
+		}
+	case DT_idx:
+		if _, err := ParseDate(val); err != nil {
+			errs.Add(ah.NoPos, "SetGameInfo: DT "+err.Error())
+		}
+	case BR_idx, WR_idx:
+		if _, err := ParseRank(val); err != nil {
+			errs.Add(ah.NoPos, "SetGameInfo: "+string(GetProperty(pt).ID)+" "+err.Error())
+		}
+	}
+	return errs
+}
+
+// cacheGameInfo sets the field of gam that caches the game-info property pt,
+// in the same way as the Parser. An empty val clears it.
+func (gam *Game) cacheGameInfo(pt PropertyDefIdx, val []byte) (errs ah.ErrorList) {
+	if len(val) == 0 {
+		val = nil
+	}
+	switch pt {
+	case AN_idx:
+		gam.SetAN(val)
+	case BR_idx:
+		gam.SetBR(val)
+	case BT_idx:
+		gam.SetBT(val)
+	case CP_idx:
+		gam.SetCP(val)
+	case DT_idx:
+		gam.SetDT(val)
+	case EV_idx:
+		gam.SetEV(val)
+	case GC_idx:
+		gam.SetGC(val)
+	case HA_idx:
+		i := 0
+		if val != nil {
+			var err error
+			if i, err = strconv.Atoi(string(val)); err != nil {
+				errs.Add(ah.NoPos, "SetGameInfo: HA "+err.Error())
+			}
+		}
+		gam.SetHA(i)
+	case KM_idx:
+		if val == nil {
+			gam.kM = Komi{}
+		} else if f, err := strconv.ParseFloat(string(val), 64); err == nil {
+			gam.SetKM(float32(f), true)
+		} else if string(val) == "?" {
+			gam.SetKM(0.0, false)
+		} else {
+			errs.Add(ah.NoPos, "SetGameInfo: KM "+err.Error())
+		}
+	case OH_idx:
+		gam.SetOH(val)
+	case PB_idx:
+		gam.SetPB(val)
+	case PC_idx:
+		gam.SetPC(val)
+	case PW_idx:
+		gam.SetPW(val)
+	case RE_idx:
+		if val == nil {
+			gam.rE = Result{}
+		} else {
+			RE_val, RE_com := SplitRE(val)
+			RE_bas, num, ch, both := TakeOutNum(RE_com)
+			gam.SetRE(RE_val, RE_bas, num, ch, both)
+		}
+	case RO_idx:
+		gam.SetRO(val)
+	case RU_idx:
+		gam.SetRU(val)
+	case SO_idx:
+		gam.SetSO(val)
+	case TM_idx:
+		tc, err := ParseTimeControl(string(val), "")
+		if val != nil && err != nil {
+			errs.Add(ah.NoPos, "SetGameInfo: TM "+err.Error())
+		}
+		gam.SetTM(float32(tc.Main))
+	case US_idx:
+		gam.SetUS(val)
+	case WR_idx:
+		gam.SetWR(val)
+	case WT_idx:
+		gam.SetWT(val)
+	}
+	return errs
+}
@@ -0,0 +1,180 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/handicap.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/23/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements handicap placement: the fixed points of handicaps
+ *	on square and rectangular boards, finding the handicap stones of a game,
+ *	placed with AB, or as Black moves answered by White passes,
+ *	and checking that the placement agrees with the rules of the game.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+)
+
+// HandicapPoints returns the fixed points of a handicap of n stones,
+// on a board of nCol by nRow points, in the order they are placed:
+//	the upper right, lower left, lower right, and upper left corners,
+//	the left and right sides for 6 to 9 stones,
+//	the top and bottom sides for 8 and 9 stones,
+//	and last, the center for 5, 7 and 9 stones.
+// The corners are on the fourth line, or the third line of a side less than 13,
+// counted separately for the columns and rows of a rectangular board.
+// nil is returned for n outside 2 to 9, or a board too small to have the corners.
+// Handicaps above 9 have no fixed points: they are placed freely.
+func HandicapPoints(n int, nCol int, nRow int) (pts []ah.NodeLoc) {
+	if n < 2 || n > 9 {
+		return nil
+	}
+	line := func(siz int) int {
+		if siz < 13 {
+			return 2
+		}
+		return 3
+	}
+	lc, lr := line(nCol), line(nRow)
+	if nCol < 2*lc+2 || nRow < 2*lr+2 {
+		return nil
+	}
+	left, right, midC := lc, nCol-(lc+1), (nCol-1)/2
+	top, bottom, midR := lr, nRow-(lr+1), (nRow-1)/2
+	place := func(c int, r int) {
+		pts = append(pts, ah.MakeNodeLoc(ah.ColValue(c), ah.RowValue(r)))
+	}
+	place(right, top)   // UpperRight
+	place(left, bottom) // LowerLeft
+	if n >= 3 {
+		place(right, bottom) // LowerRight
+	}
+	if n >= 4 {
+		place(left, top) // UpperLeft
+	}
+	if n >= 6 {
+		place(left, midR)  // mid point Left side
+		place(right, midR) // mid point Right side
+	}
+	if n >= 8 {
+		place(midC, top)    // mid point Top side
+		place(midC, bottom) // mid point Bottom side
+	}
+	if n%2 == 1 && n >= 5 {
+		place(midC, midR) // mid point
+	}
+	return pts
+}
+
+// A HandicapSetup is the placement of the handicap stones of a game.
+//	Stones are the points of the stones, in the order they were placed.
+//	FromMoves is true if the stones were played as Black moves, each answered
+//	by a White pass, and false if they were added with AB.
+//	Moves is the number of moves used to place the stones (and White's passes).
+//	PassFirst is true if Black passed at the first move, after AB,
+//	to give White the first move.
+//	Fixed is true if the stones are on the fixed points of HandicapPoints,
+//	in any symmetry of the board.
+type HandicapSetup struct {
+	Stones    []ah.NodeLoc
+	FromMoves bool
+	Moves     int
+	PassFirst bool
+	Fixed     bool
+}
+
+// GetHandicapSetup finds the handicap stones of the game.
+// Stones added with AB are the handicap, if no white stones are added.
+// Otherwise, with no stones added, the opening Black moves, each answered
+// by a White pass, and the Black move after them, are the handicap.
+func (gam *GameTree) GetHandicapSetup() (hs HandicapSetup) {
+	nCol, nRow := gam.boardSize()
+	brd := newReplayBoard(nCol, nRow)
+	isPass := func(nl ah.NodeLoc) bool {
+		return nl == ah.PassNodeLoc || nl == ah.NilNodeLoc || isOldPass(nl, brd)
+	}
+	movs := gam.mainLineMoves()
+	switch {
+	case len(gam.aB) > 0 && len(gam.aW) == 0:
+		hs.Stones = append(hs.Stones, gam.aB...)
+		hs.PassFirst = len(movs) > 0 && movs[0].colr == ah.Black && isPass(movs[0].loc)
+	case len(gam.aB) == 0 && len(gam.aW) == 0:
+		k := 0
+		for k+1 < len(movs) && movs[k].colr == ah.Black && !isPass(movs[k].loc) &&
+			movs[k+1].colr == ah.White && isPass(movs[k+1].loc) {
+			k += 2
+		}
+		if k == 0 || k >= len(movs) || movs[k].colr != ah.Black || isPass(movs[k].loc) {
+			return hs
+		}
+		for i := 0; i <= k; i += 2 {
+			hs.Stones = append(hs.Stones, movs[i].loc)
+		}
+		hs.FromMoves = true
+		hs.Moves = k + 1
+	default:
+		return hs
+	}
+	hs.Fixed = onFixedPoints(hs.Stones, nCol, nRow)
+	return hs
+}
+
+// onFixedPoints returns true if the stones are on the fixed points of their handicap,
+// in some symmetry of the board. The order of the stones does not matter.
+func onFixedPoints(stones []ah.NodeLoc, nCol int, nRow int) bool {
+	fixed := HandicapPoints(len(stones), nCol, nRow)
+	if fixed == nil || len(fixed) != len(stones) {
+		return false
+	}
+	have := make(map[ah.NodeLoc]bool, len(stones))
+	for _, nl := range stones {
+		have[nl] = true
+	}
+	for _, t := range boardSymmetries(nCol, nRow) {
+		all := true
+		for _, nl := range fixed {
+			c, r := ah.GetColRow(nl)
+			x, y := transLocal(t, int(c), int(r), nCol, nRow)
+			if !have[ah.MakeNodeLoc(ah.ColValue(x), ah.RowValue(y))] {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHandicap checks the handicap of the game with the rules rs, and returns
+// a message for each problem found:
+//	handicap moves not agreeing with HA,
+//	handicap moves without HA,
+//	stones not on the fixed points, when the rules require them, and the
+//	handicap has fixed points (HandicapPoints is not nil).
+// With RulesFromRU, the rules are given by RU, and the placement
+// is not checked if RU is missing, or not known.
+func (gam *GameTree) CheckHandicap(rs RuleSet) (errstr string) {
+	known := true
+	if rs == RulesFromRU {
+		rs, known = RuleSetFor(string(gam.rU))
+	}
+	handi := gam.GetHandicap()
+	hs := gam.GetHandicapSetup()
+	if hs.FromMoves {
+		if handi == 0 {
+			errstr = errstr + "handicap moves, but no HA "
+		} else if handi != len(hs.Stones) {
+			errstr = errstr + "HA not equal handicap moves "
+		}
+	}
+	nCol, nRow := gam.boardSize()
+	if known && handi >= 2 && len(hs.Stones) == handi && !rs.FreeHandicap() && !hs.Fixed &&
+		HandicapPoints(handi, nCol, nRow) != nil {
+		errstr = errstr + "handicap not on fixed points for " + RuleSetNames[rs] + " rules "
+	}
+	return errstr
+}
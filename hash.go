@@ -0,0 +1,189 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/hash.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/22/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements Zobrist hashing of positions: a 64 bit hash,
+ *	kept up to date as stones are added and removed, in each symmetry
+ *	of the board, and the hash of the position at each node of a GameTree.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+)
+
+// pointKey returns the random key of a stone of colr at point i, for hashing positions.
+// The keys are made by the splitmix64 generator, so they are the same in every run.
+func pointKey(i int, colr ah.PointStatus) uint64 {
+	k := 2*i + 1
+	if colr == ah.White {
+		k += 1
+	}
+	z := uint64(k) * 0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// whiteToMoveKey is added to the hash of a position with White to move.
+const whiteToMoveKey uint64 = 0x6A09E667F3BCC909
+
+// boardSymmetries returns the symmetries of a board of nCol by nRow points:
+// all eight if it is square, and four if it is not.
+func boardSymmetries(nCol int, nRow int) []ah.BoardTrans {
+	if nCol == nRow {
+		return []ah.BoardTrans{ah.T_IDENTITY, ah.T_ROTA_090, ah.T_ROTA_180, ah.T_ROTA_270,
+			ah.T_FLP_SLAS, ah.T_FLP_VERT, ah.T_FLP_BACK, ah.T_FLP_HORI}
+	}
+	return []ah.BoardTrans{ah.T_IDENTITY, ah.T_ROTA_180, ah.T_FLP_VERT, ah.T_FLP_HORI}
+}
+
+// A positionHash is the Zobrist hash of the stones on a board, in each of its symmetries.
+//	h[k] is the hash of the board transformed by syms[k]; h[0] is the board itself.
+type positionHash struct {
+	nCol, nRow int
+	syms       []ah.BoardTrans
+	h          [8]uint64
+}
+
+// newPositionHash returns the hash of an empty board of nCol by nRow points.
+func newPositionHash(nCol int, nRow int) *positionHash {
+	return &positionHash{nCol: nCol, nRow: nRow, syms: boardSymmetries(nCol, nRow)}
+}
+
+// toggle adds, or removes, a stone of colr at point i (indexed by r*nCol + c).
+func (ph *positionHash) toggle(i int, colr ah.PointStatus) {
+	c, r := i%ph.nCol, i/ph.nCol
+	for k, t := range ph.syms {
+		x, y := transLocal(t, c, r, ph.nCol, ph.nRow)
+		ph.h[k] ^= pointKey(y*ph.nCol+x, colr)
+	}
+}
+
+// exact returns the hash of the board, as it is.
+func (ph *positionHash) exact() uint64 {
+	return ph.h[0]
+}
+
+// canonical returns the least hash of the symmetries of the board,
+// with the player to move, toMov, so it is the same for all the symmetries of a position.
+// The empty board, with Black to move, has the hash 0.
+func (ph *positionHash) canonical(toMov ah.PointStatus) uint64 {
+	m := ph.h[0]
+	for k := 1; k < len(ph.syms); k++ {
+		if ph.h[k] < m {
+			m = ph.h[k]
+		}
+	}
+	if toMov == ah.White {
+		m ^= whiteToMoveKey
+	}
+	return m
+}
+
+// setNodeHash records the hash of the position at node n, while parsing with ParserPlay.
+func (p *Parser) setNodeHash(n TreeNodeIdx) {
+	if !p.play {
+		return
+	}
+	for len(p.nodeHashes) <= int(n) {
+		p.nodeHashes = append(p.nodeHashes, 0)
+	}
+	if p.checker != nil {
+		p.nodeHashes[n] = p.checker.canonical()
+	} else {
+		p.nodeHashes[n] = 0 // the empty board, with Black to move
+	}
+}
+
+// hashNodes replays the tree, and computes the hash of the position at each node.
+// The tree of each game is walked by first child and next sibling, with a stack
+// of the nodes on the path, and the steps of a node are undone when the walk returns
+// from it, so the order in which the nodes were added to the tree does not matter.
+func (gamT *GameTree) hashNodes() {
+	type pathNode struct {
+		nod  TreeNodeIdx // a node on the path
+		next TreeNodeIdx // its next child to walk, nilTreeNodeIdx if none
+		mark int         // the steps of the checker before the node
+	}
+	gamT.nodeHashes = make([]uint64, len(gamT.treeNodes))
+	for g := 0; g < gamT.NumGames(); g++ {
+		gam := gamT.GetGame(g)
+		nCol, nRow := gam.boardSize()
+		c := newMoveChecker(RulesJapanese, nCol, nRow)
+		gamT.replayNode(c, gam.InfoNode)
+		path := []pathNode{{nod: gam.InfoNode, next: gamT.firstChild(gam.InfoNode)}}
+		for len(path) > 0 {
+			top := &path[len(path)-1]
+			if top.next == nilTreeNodeIdx { // all the children are done: return
+				c.undoTo(top.mark)
+				path = path[0 : len(path)-1]
+				continue
+			}
+			ch := top.next
+			if ch == gamT.treeNodes[top.nod].Children { // the last child
+				top.next = nilTreeNodeIdx
+			} else {
+				top.next = gamT.treeNodes[ch].NextSib
+			}
+			mark := c.mark()
+			gamT.replayNode(c, ch)
+			path = append(path, pathNode{nod: ch, next: gamT.firstChild(ch), mark: mark})
+		}
+	}
+}
+
+// replayNode plays the setup, PL, and move of node nod with c, and records its hash.
+func (gamT *GameTree) replayNode(c *moveChecker, nod TreeNodeIdx) {
+	for _, nl := range gamT.propPoints(nod, AE_idx) {
+		c.setup(nod, nl, ah.Unocc)
+	}
+	for _, nl := range gamT.propPoints(nod, AB_idx) {
+		c.setup(nod, nl, ah.Black)
+	}
+	for _, nl := range gamT.propPoints(nod, AW_idx) {
+		c.setup(nod, nl, ah.White)
+	}
+	if pl := gamT.propString(nod, PL_idx); pl != "" {
+		c.player(nod, plColor(pl))
+	}
+	if mov, ok := gamT.nodeMove(nod); ok {
+		c.play(nod, mov.loc, mov.colr)
+	}
+	gamT.nodeHashes[nod] = c.canonical()
+}
+
+// plColor returns the color of a PL value: "W", or "2", is White, and others are Black.
+func plColor(pl string) ah.PointStatus {
+	if pl == "W" || pl == "w" || pl == "2" {
+		return ah.White
+	}
+	return ah.Black
+}
+
+// dropHashes discards the position hashes, when a property of type pt, that changes
+// the positions of the game (setup, move, PL, or SZ), is added or removed.
+// PositionHash computes them again, when next needed.
+func (gamT *GameTree) dropHashes(pt PropertyDefIdx) {
+	switch pt {
+	case AB_idx, AW_idx, AE_idx, B_idx, W_idx, PL_idx, SZ_idx:
+		gamT.nodeHashes = nil
+	}
+}
+
+// PositionHash returns the hash of the position at node n: the stones on the board,
+// after the setup and move of n, and the player to move.
+// The hash is the same for each of the symmetries of a position, so it may be used
+// to find transpositions, and the same position in other games.
+// The hashes are kept while parsing with ParserPlay, and are computed
+// when first needed otherwise, or after the setup, moves, PL, or SZ are changed.
+func (gamT *GameTree) PositionHash(n TreeNodeIdx) uint64 {
+	if len(gamT.nodeHashes) != len(gamT.treeNodes) {
+		gamT.hashNodes()
+	}
+	return gamT.nodeHashes[n]
+}
@@ -83,7 +83,30 @@ func readSource(filename string, src interface{}) ([]byte, ah.ErrorList) {
 // errors were found, the result is a partial tree (with TreeNode.BadX Nodes
 // representing the fragments of erroneous SGF file). Multiple errors
 // are returned via a Scanner.ErrorList which is sorted by file position.
+//
+// With the ParserDbStat mode, the statistics of this file are kept in the
+// DBStats of the Parser. Use ParseFileStats to collect them for many files,
+// in a DBStatistics owned by the caller.
 func ParseFile(filename string, src interface{}, mode ParserMode, moveLimit int) (*Parser, ah.ErrorList) {
+	return ParseFileStats(filename, src, mode, moveLimit, nil)
+}
+
+// ParseFileStats is like ParseFile, but the data base statistics are added to stats.
+// If stats != nil, the ParserDbStat mode is set. stats may be shared by parsers
+// running in different goroutines.
+func ParseFileStats(filename string, src interface{}, mode ParserMode, moveLimit int, stats *DBStatistics) (*Parser, ah.ErrorList) {
+	return parseSource(filename, src, mode, moveLimit, stats, RulesFromRU)
+}
+
+// ParseFileRules is like ParseFile, but with ParserPlay, the moves are checked
+// with rules, instead of the rules of the RU property of each game.
+// The illegal moves are returned by the MoveDiagnostics of the Parser.
+func ParseFileRules(filename string, src interface{}, mode ParserMode, moveLimit int, rules RuleSet) (*Parser, ah.ErrorList) {
+	return parseSource(filename, src, mode, moveLimit, nil, rules)
+}
+
+// parseSource parses a file for ParseFileStats, and ParseFileRules.
+func parseSource(filename string, src interface{}, mode ParserMode, moveLimit int, stats *DBStatistics, rules RuleSet) (*Parser, ah.ErrorList) {
 	var p Parser
 	var errL ah.ErrorList
 
@@ -92,7 +115,12 @@ func ParseFile(filename string, src interface{}, mode ParserMode, moveLimit int)
 		return nil, errL
 	}
 
+	if stats != nil {
+		mode |= ParserDbStat
+		p.DBStats = stats
+	}
 	p.initParser(filename, data, mode, moveLimit)
+	p.SetRules(rules)
 	p.parseFile()
 	return &p, p.errors
 }
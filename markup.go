@@ -0,0 +1,522 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/markup.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/24/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the markup of nodes: the marks CR, SQ, TR, MA and SL,
+ *	the arrows AR, lines LN, and labels LB, the dimmed points DD and the view VW.
+ *	The markup of a node is read into a Markup, which may be changed,
+ *	is checked against the restrictions of FF[4], and written back in canonical form.
+ */
+
+package sgf
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Ken1JF/ah"
+)
+
+// MarkType is the type of a mark on a point.
+type MarkType uint8
+
+const (
+	MarkCircle   MarkType = iota // CR
+	MarkSquare                   // SQ
+	MarkTriangle                 // TR
+	MarkCross                    // MA
+	MarkSelected                 // SL
+)
+
+var MarkTypeNames = []string{"circle", "square", "triangle", "cross", "selected"}
+
+// markProps are the properties of each MarkType.
+var markProps = []PropertyDefIdx{CR_idx, SQ_idx, TR_idx, MA_idx, SL_idx}
+
+// A Line is an arrow (AR), from the tail From to the head To, or a line (LN).
+type Line struct {
+	From ah.NodeLoc
+	To   ah.NodeLoc
+}
+
+// same returns true if two lines join the same points.
+// Arrows must also have the same direction.
+func (l Line) same(o Line, directed bool) bool {
+	if l == o {
+		return true
+	}
+	return !directed && l.From == o.To && l.To == o.From
+}
+
+// String formats a line as a composed SGF value: "aa:cc".
+func (l Line) String() string {
+	return string(SGFCoords(l.From, true)) + ":" + string(SGFCoords(l.To, true))
+}
+
+// A Markup is the markup of one node.
+//	Marks is the mark on each marked point, so a point has at most one mark.
+//	Labels is the text of the label on each labeled point.
+//	Arrows and Lines are in the order they were added.
+//	Dimmed and View are the points of DD and VW, set at this node.
+//	DimSet and ViewSet are true if DD or VW is set at the node:
+//	an empty DD[] undims all points, and an empty VW[] shows the whole board.
+//	Otherwise, they are inherited from the parent (see DimmedAt, and ViewAt).
+type Markup struct {
+	Marks   map[ah.NodeLoc]MarkType
+	Labels  map[ah.NodeLoc]string
+	Arrows  []Line
+	Lines   []Line
+	Dimmed  []ah.NodeLoc
+	DimSet  bool
+	View    []ah.NodeLoc
+	ViewSet bool
+}
+
+// NewMarkup returns an empty Markup.
+func NewMarkup() Markup {
+	return Markup{Marks: make(map[ah.NodeLoc]MarkType), Labels: make(map[ah.NodeLoc]string)}
+}
+
+// Mark puts a mark on a point.
+// An error is returned, and the mark is not changed, if the point has another mark.
+func (mk *Markup) Mark(nl ah.NodeLoc, typ MarkType) (err ah.ErrorList) {
+	if old, ok := mk.Marks[nl]; ok && old != typ {
+		err.Add(ah.NoPos, "Mark: "+string(SGFCoords(nl, true))+" already has a "+MarkTypeNames[old])
+		return err
+	}
+	mk.Marks[nl] = typ
+	return err
+}
+
+// Unmark removes the mark from a point.
+func (mk *Markup) Unmark(nl ah.NodeLoc) {
+	delete(mk.Marks, nl)
+}
+
+// Label puts a label on a point, replacing any label it had.
+func (mk *Markup) Label(nl ah.NodeLoc, text string) {
+	mk.Labels[nl] = text
+}
+
+// Unlabel removes the label from a point.
+func (mk *Markup) Unlabel(nl ah.NodeLoc) {
+	delete(mk.Labels, nl)
+}
+
+// addLine adds a line to a list, and checks it is not a point, or already in the list.
+func addLine(list []Line, l Line, directed bool, what string) ([]Line, ah.ErrorList) {
+	var err ah.ErrorList
+	if l.From == l.To {
+		err.Add(ah.NoPos, what+": "+l.String()+" has length zero")
+		return list, err
+	}
+	for _, o := range list {
+		if l.same(o, directed) {
+			err.Add(ah.NoPos, what+": "+l.String()+" is already there")
+			return list, err
+		}
+	}
+	return append(list, l), err
+}
+
+// removeLine removes a line from a list.
+func removeLine(list []Line, l Line, directed bool) []Line {
+	for i, o := range list {
+		if l.same(o, directed) {
+			return append(list[0:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+// AddArrow adds an arrow, from the tail from to the head to.
+// An error is returned for an arrow of length zero, or one that is already there.
+func (mk *Markup) AddArrow(from ah.NodeLoc, to ah.NodeLoc) (err ah.ErrorList) {
+	mk.Arrows, err = addLine(mk.Arrows, Line{from, to}, true, "AddArrow")
+	return err
+}
+
+// RemoveArrow removes the arrow from the tail from to the head to.
+func (mk *Markup) RemoveArrow(from ah.NodeLoc, to ah.NodeLoc) {
+	mk.Arrows = removeLine(mk.Arrows, Line{from, to}, true)
+}
+
+// AddLine adds a line between two points.
+// An error is returned for a line of length zero, or one that is already there, in either direction.
+func (mk *Markup) AddLine(from ah.NodeLoc, to ah.NodeLoc) (err ah.ErrorList) {
+	mk.Lines, err = addLine(mk.Lines, Line{from, to}, false, "AddLine")
+	return err
+}
+
+// RemoveLine removes the line between two points.
+func (mk *Markup) RemoveLine(from ah.NodeLoc, to ah.NodeLoc) {
+	mk.Lines = removeLine(mk.Lines, Line{from, to}, false)
+}
+
+// SetDimmed sets DD at the node, to dim pts. With no pts, all points are undimmed.
+func (mk *Markup) SetDimmed(pts []ah.NodeLoc) {
+	mk.Dimmed, mk.DimSet = pts, true
+}
+
+// SetView sets VW at the node, to show only pts. With no pts, the whole board is shown.
+func (mk *Markup) SetView(pts []ah.NodeLoc) {
+	mk.View, mk.ViewSet = pts, true
+}
+
+// Empty returns true if the Markup has nothing to write.
+func (mk *Markup) Empty() bool {
+	return len(mk.Marks) == 0 && len(mk.Labels) == 0 && len(mk.Arrows) == 0 &&
+		len(mk.Lines) == 0 && !mk.DimSet && !mk.ViewSet
+}
+
+// parsePoints parses a list of points, as stored for a property: two letters for each point,
+// or five letters, "aa:cc", for a compressed rectangle of points.
+func parsePoints(str []byte) (pts []ah.NodeLoc, err ah.ErrorList) {
+	for len(str) >= 2 {
+		if len(str) >= 5 && str[2] == ':' {
+			ul, errU := SGFPoint(str[0:2])
+			lr, errL := SGFPoint(str[3:5])
+			if len(errU) != 0 || len(errL) != 0 {
+				err.Add(ah.NoPos, "parsePoints: bad rectangle "+string(str[0:5]))
+				str = str[5:]
+				continue
+			}
+			str = str[5:]
+			c1, r1 := ah.GetColRow(ul)
+			c2, r2 := ah.GetColRow(lr)
+			for r := r1; r <= r2; r++ {
+				for c := c1; c <= c2; c++ {
+					pts = append(pts, ah.MakeNodeLoc(c, r))
+				}
+			}
+			continue
+		}
+		if nl, errP := SGFPoint(str[0:2]); len(errP) == 0 {
+			pts = append(pts, nl)
+		} else {
+			err = append(err, errP...)
+		}
+		str = str[2:]
+	}
+	if len(str) != 0 {
+		err.Add(ah.NoPos, "parsePoints: extra characters "+string(str))
+	}
+	return pts, err
+}
+
+// parseLines parses the value of AR or LN: "aa:bbcc:dd", with "][" allowed between the lines.
+func parseLines(str []byte) (lines []Line, err ah.ErrorList) {
+	s := strings.Replace(string(str), "][", "", -1)
+	for len(s) >= 5 {
+		from, errF := SGFPoint([]byte(s[0:2]))
+		to, errT := SGFPoint([]byte(s[3:5]))
+		if s[2] != ':' || len(errF) != 0 || len(errT) != 0 {
+			err.Add(ah.NoPos, "parseLines: bad line "+s[0:5])
+		} else {
+			lines = append(lines, Line{from, to})
+		}
+		s = s[5:]
+	}
+	if len(s) != 0 {
+		err.Add(ah.NoPos, "parseLines: extra characters "+s)
+	}
+	return lines, err
+}
+
+// splitLabels splits the stored value of LB into its items, at each "][" between them,
+// but not at an escaped "\]" in the text of a label.
+func splitLabels(s string) (items []string) {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i += 1 // skip the escaped character
+		case s[i] == ']' && i+1 < len(s) && s[i+1] == '[':
+			items = append(items, s[start:i])
+			start = i + 2
+			i += 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// GetMarkup returns the markup of node n.
+// Errors are reported for values that cannot be parsed, and for markup
+// that breaks the restrictions of FF[4]: a point with more than one mark,
+// or label, and arrows or lines of length zero, or given twice.
+func (gamT *GameTree) GetMarkup(n TreeNodeIdx) (mk Markup, errs ah.ErrorList) {
+	mk = NewMarkup()
+	for typ, pt := range markProps {
+		pts, err := parsePoints([]byte(gamT.propString(n, pt)))
+		errs = append(errs, err...)
+		for _, nl := range pts {
+			errs = append(errs, mk.Mark(nl, MarkType(typ))...)
+		}
+	}
+	if lb := gamT.propString(n, LB_idx); lb != "" {
+		for _, item := range splitLabels(lb) {
+			idx := strings.Index(item, ":")
+			if idx != 2 {
+				errs.Add(ah.NoPos, "GetMarkup: bad label "+item)
+				continue
+			}
+			nl, err := SGFPoint([]byte(item[0:2]))
+			if len(err) != 0 {
+				errs = append(errs, err...)
+				continue
+			}
+			if _, ok := mk.Labels[nl]; ok {
+				errs.Add(ah.NoPos, "GetMarkup: "+item[0:2]+" has more than one label")
+			}
+			mk.Label(nl, unescapeSimpleText([]byte(item[3:])))
+		}
+	}
+	arrows, err := parseLines([]byte(gamT.propString(n, AR_idx)))
+	errs = append(errs, err...)
+	for _, l := range arrows {
+		errs = append(errs, mk.AddArrow(l.From, l.To)...)
+	}
+	lines, err := parseLines([]byte(gamT.propString(n, LN_idx)))
+	errs = append(errs, err...)
+	for _, l := range lines {
+		errs = append(errs, mk.AddLine(l.From, l.To)...)
+	}
+	if pIdx := gamT.findProp(n, DD_idx); pIdx != nilPropIdx {
+		mk.Dimmed, err = parsePoints(gamT.propertyValues[pIdx].StrValue)
+		mk.DimSet = true
+		errs = append(errs, err...)
+	}
+	if pIdx := gamT.findProp(n, VW_idx); pIdx != nilPropIdx {
+		mk.View, err = parsePoints(gamT.propertyValues[pIdx].StrValue)
+		mk.ViewSet = true
+		errs = append(errs, err...)
+	}
+	return mk, errs
+}
+
+// markupProps are the properties of markup, in the order SetMarkup writes them.
+var markupProps = []PropertyDefIdx{AR_idx, CR_idx, DD_idx, LB_idx, LN_idx, MA_idx, SL_idx, SQ_idx, TR_idx, VW_idx}
+
+// byPoint sorts points by column, then row, the order of their SGF coordinates.
+type byPoint []ah.NodeLoc
+
+func (bp byPoint) Len() int      { return len(bp) }
+func (bp byPoint) Swap(i, j int) { bp[i], bp[j] = bp[j], bp[i] }
+func (bp byPoint) Less(i, j int) bool {
+	ci, ri := ah.GetColRow(bp[i])
+	cj, rj := ah.GetColRow(bp[j])
+	return ci < cj || (ci == cj && ri < rj)
+}
+
+// pointsValue returns the stored value of a list of points, sorted, without duplicates.
+func pointsValue(pts []ah.NodeLoc) (str []byte) {
+	sorted := append(byPoint(nil), pts...)
+	sort.Sort(sorted)
+	for i, nl := range sorted {
+		if i == 0 || nl != sorted[i-1] {
+			str = append(str, SGFCoords(nl, true)...)
+		}
+	}
+	return str
+}
+
+// SetMarkup replaces the markup of node n with mk, written in canonical form:
+// the properties in alphabetical order, and the points of each sorted by column, then row.
+// Arrows and lines keep their order. Errors are returned, and the node is not changed,
+// if mk has arrows or lines that break the restrictions of FF[4], or points off the board.
+func (gamT *GameTree) SetMarkup(n TreeNodeIdx, mk Markup) (errs ah.ErrorList) {
+	var chk Markup
+	for _, l := range mk.Arrows {
+		chk.Arrows, errs = addLine(chk.Arrows, l, true, "SetMarkup")
+		if len(errs) != 0 {
+			return errs
+		}
+	}
+	for _, l := range mk.Lines {
+		chk.Lines, errs = addLine(chk.Lines, l, false, "SetMarkup")
+		if len(errs) != 0 {
+			return errs
+		}
+	}
+	nCol, nRow := gamT.boardSize()
+	onBoard := func(nl ah.NodeLoc) {
+		c, r := ah.GetColRow(nl)
+		if int(c) >= nCol || int(r) >= nRow {
+			errs.Add(ah.NoPos, "SetMarkup: "+string(SGFCoords(nl, true))+" not on the board")
+		}
+	}
+	var marks [5][]ah.NodeLoc
+	for nl, typ := range mk.Marks {
+		onBoard(nl)
+		marks[typ] = append(marks[typ], nl)
+	}
+	var labels []ah.NodeLoc
+	for nl := range mk.Labels {
+		onBoard(nl)
+		labels = append(labels, nl)
+	}
+	for _, l := range append(append([]Line(nil), mk.Arrows...), mk.Lines...) {
+		onBoard(l.From)
+		onBoard(l.To)
+	}
+	for _, nl := range append(append([]ah.NodeLoc(nil), mk.Dimmed...), mk.View...) {
+		onBoard(nl)
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+
+	for _, pt := range markupProps {
+		gamT.removeProps(n, pt)
+	}
+	add := func(pt PropertyDefIdx, str []byte, valType PropValueType) {
+		if len(str) == 0 && valType != None {
+			return
+		}
+		var pv PropertyValue
+		pv.PropType = pt
+		pv.StrValue = str
+		pv.ValType = valType
+		pv.NextProp = nilPropIdx
+		errs = append(errs, gamT.AddAProp(n, pv)...)
+	}
+	lineValue := func(lines []Line) (str []byte) {
+		for _, l := range lines {
+			str = append(str, l.String()...)
+		}
+		return str
+	}
+	listType := func(str []byte) PropValueType {
+		switch {
+		case len(str) == 0:
+			return None
+		case len(str) == 2:
+			return Point
+		}
+		return ListOfPoint
+	}
+	sort.Sort(byPoint(labels))
+	var lb []string
+	for _, nl := range labels {
+		lb = append(lb, string(SGFCoords(nl, true))+":"+escapeSimpleText(mk.Labels[nl]))
+	}
+	for _, pt := range markupProps {
+		switch pt {
+		case AR_idx:
+			add(pt, lineValue(mk.Arrows), ListOfCompPoint_Point)
+		case LN_idx:
+			add(pt, lineValue(mk.Lines), ListOfCompPoint_Point)
+		case LB_idx:
+			add(pt, []byte(strings.Join(lb, "][")), ListOfCompPoint_simpTest)
+		case DD_idx:
+			if mk.DimSet {
+				str := pointsValue(mk.Dimmed)
+				add(pt, str, listType(str))
+			}
+		case VW_idx:
+			if mk.ViewSet {
+				str := pointsValue(mk.View)
+				add(pt, str, listType(str))
+			}
+		default:
+			for typ, mp := range markProps {
+				if mp == pt {
+					str := pointsValue(marks[typ])
+					if len(str) != 0 {
+						add(pt, str, listType(str))
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// inheritedPoints returns the points of an inherited property, DD or VW,
+// from node n, or its nearest ancestor that sets it.
+func (gamT *GameTree) inheritedPoints(n TreeNodeIdx, typ PropertyDefIdx) (pts []ah.NodeLoc, set bool) {
+	for nod := n; nod != nilTreeNodeIdx; nod = gamT.treeNodes[nod].Parent {
+		if pIdx := gamT.findProp(nod, typ); pIdx != nilPropIdx {
+			pts, _ = parsePoints(gamT.propertyValues[pIdx].StrValue)
+			return pts, true
+		}
+	}
+	return nil, false
+}
+
+// DimmedAt returns the points dimmed at node n: DD is inherited,
+// until a node sets it again, and an empty DD[] undims all points.
+func (gamT *GameTree) DimmedAt(n TreeNodeIdx) []ah.NodeLoc {
+	pts, _ := gamT.inheritedPoints(n, DD_idx)
+	return pts
+}
+
+// ViewAt returns the points shown at node n, and true if only part of the board is shown.
+// VW is inherited, until a node sets it again, and an empty VW[] shows the whole board.
+func (gamT *GameTree) ViewAt(n TreeNodeIdx) ([]ah.NodeLoc, bool) {
+	pts, _ := gamT.inheritedPoints(n, VW_idx)
+	return pts, len(pts) != 0
+}
+
+// String formats the markup of a node, in the order SetMarkup writes it.
+func (mk Markup) String() string {
+	var s []string
+	for _, pt := range markupProps {
+		var str []byte
+		switch pt {
+		case AR_idx:
+			for _, l := range mk.Arrows {
+				str = append(str, "["+l.String()+"]"...)
+			}
+		case LN_idx:
+			for _, l := range mk.Lines {
+				str = append(str, "["+l.String()+"]"...)
+			}
+		case LB_idx:
+			var labels []ah.NodeLoc
+			for nl := range mk.Labels {
+				labels = append(labels, nl)
+			}
+			sort.Sort(byPoint(labels))
+			for _, nl := range labels {
+				str = append(str, "["+string(SGFCoords(nl, true))+":"+escapeSimpleText(mk.Labels[nl])+"]"...)
+			}
+		case DD_idx, VW_idx:
+			pts, set := mk.Dimmed, mk.DimSet
+			if pt == VW_idx {
+				pts, set = mk.View, mk.ViewSet
+			}
+			if set {
+				str = pointsString(pts)
+				if len(str) == 0 {
+					str = []byte("[]")
+				}
+			}
+		default:
+			var pts []ah.NodeLoc
+			for nl, typ := range mk.Marks {
+				if markProps[typ] == pt {
+					pts = append(pts, nl)
+				}
+			}
+			str = pointsString(pts)
+		}
+		if len(str) != 0 {
+			s = append(s, string(GetProperty(pt).ID)+string(str))
+		}
+	}
+	return strings.Join(s, "")
+}
+
+// pointsString formats a list of points, sorted, each in brackets.
+func pointsString(pts []ah.NodeLoc) (str []byte) {
+	val := pointsValue(pts)
+	for len(val) >= 2 {
+		str = append(str, "["+string(val[0:2])+"]"...)
+		val = val[2:]
+	}
+	return str
+}
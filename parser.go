@@ -72,6 +72,14 @@ type Parser struct {
 	moveLimit    int
 	limitReached bool
 
+	// checking the legality of moves, with ParserPlay
+	rules     RuleSet      // RulesFromRU => the rules of the RU property
+	checker   *moveChecker // the board of the game being parsed
+	moveDiags []MoveDiagnostic
+
+	// game-info properties below the GameInfoNode of the game being parsed
+	gameInfoBelow bool
+
 	// Next token
 	pos ah.Position // token ah.Position
 	tok Token       // one token look-ahead
@@ -86,6 +94,11 @@ func (p *Parser) Error(pos ah.Position, msg string) {
 	p.errors.Add(pos, msg)
 }
 
+// Warnings returns the warnings found while parsing.
+func (p *Parser) Warnings() ah.ErrorList {
+	return p.warnings
+}
+
 // ReportException prints values of Properties that cannot be understood
 func (p *Parser) ReportException(idx PropertyDefIdx, str []byte, err string) {
 	if idx != TM_idx {
@@ -96,7 +109,7 @@ func (p *Parser) ReportException(idx PropertyDefIdx, str []byte, err string) {
 // addProp maintains a variable sized array of properties.
 // properties are maintained as a circular linked list.
 func (p *Parser) addProp(n TreeNodeIdx, pv PropertyValue) {
-	err := p.AddAProp(n, pv)
+	err := p.addAProp(n, pv)
 	if len(err) != 0 {
 		p.errors.Add(p.pos, err[0].Msg)
 	}
@@ -108,6 +121,7 @@ func (p *Parser) addNode(par TreeNodeIdx, ty TreeNodeType) TreeNodeIdx {
 		p.errors.Add(p.pos, "adding node "+err[0].Msg)
 		// TODO: need to exit, cannot continue without updating p.treeNodes etc.
 	}
+	p.setNodeHash(newIdx)
 	return newIdx
 }
 
@@ -152,18 +166,15 @@ func (p *Parser) initParser(filename string, src []byte, mode ParserMode, fileLi
 	eh := func(pos ah.Position, msg string) { p.errors.Add(pos, msg) }
 
 	p.scanner.InitScanner(filename, src, eh, scannerMode(mode))
+	p.srcName = GameName(filename)
 	p.mode = mode
 	p.moveLimit = fileLimit
 	// for convenience (used frequently)
 	p.trace = (mode&TraceParser != 0) || ah.GetAHTrace()
 	p.play = (mode&ParserPlay != 0)
 	p.dbstat = (mode&ParserDbStat != 0)
-	if p.dbstat { // is this parser supposed to keep statistics?
-		if theDBStatistics == nil { // is this the first? allocate and initialize
-			theDBStatistics = new(DBStatistics)
-			theDBStatistics.initStats()
-		}
-		p.DBStats = theDBStatistics // all parsers share the globals
+	if p.dbstat && p.DBStats == nil { // keep statistics, but none were given?
+		p.DBStats = NewDBStatistics() // keep them for this parser only
 	}
 
 	p.next()
@@ -247,6 +258,19 @@ func un(p *Parser) {
 // ----------------------------------------------------------------------------
 // Source files
 
+// unescapeSimpleText returns the text of an SGF SimpleText value, with its escapes removed.
+// See escapeSimpleText.
+func unescapeSimpleText(b []byte) string {
+	var ret []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\\' && i+1 < len(b) {
+			i += 1
+		}
+		ret = append(ret, b[i])
+	}
+	return string(ret)
+}
+
 func (p *Parser) parsePropValue(val PropValueType) (pv PropertyValue) {
 	if p.trace {
 		defer un(trace(p, "parsePropValue"))
@@ -286,6 +310,10 @@ func (p *Parser) parsePropValue(val PropValueType) (pv PropertyValue) {
 		if p.tok == RBRACK {
 			pv.ValType = None
 			p.next()
+		} else if p.tok == STRING && len(p.lit) == 0 { // the scanner returns an empty string for []
+			pv.ValType = None
+			p.next()
+			p.expect(RBRACK)
 		} else {
 			p.errorExpected(p.pos, ValueNames[val])
 			p.next()
@@ -444,14 +472,28 @@ func (p *Parser) parsePropValue(val PropValueType) (pv PropertyValue) {
 		}
 
 	case Double:
-		p.errors.Add(p.pos, "Not Implemented: "+ValueNames[val])
-		p.next()
-		p.expect(RBRACK)
+		if p.tok == STRING && (string(p.lit) == "1" || string(p.lit) == "2") {
+			p.next()
+			p.expect(RBRACK)
+		} else {
+			p.errorExpected(p.pos, ValueNames[val])
+			if p.tok != RBRACK {
+				p.next()
+			}
+			p.expect(RBRACK)
+		}
 
 	case Color:
-		p.errors.Add(p.pos, "Not Implemented: "+ValueNames[val])
-		p.next()
-		p.expect(RBRACK)
+		if p.tok == STRING && (string(p.lit) == "B" || string(p.lit) == "W") {
+			p.next()
+			p.expect(RBRACK)
+		} else {
+			p.errorExpected(p.pos, ValueNames[val])
+			if p.tok != RBRACK {
+				p.next()
+			}
+			p.expect(RBRACK)
+		}
 
 		// not possible?		default:
 	}
@@ -503,242 +545,6 @@ func TakeOutNum(RE_com []byte) (bas []byte, n int, sep byte, both bool) {
 	return bas, n, sep, both
 }
 
-func check_Rank(strVal []byte) (err string) {
-	s := string(strVal)
-	if s == "9" {
-		err = "check rank:" + s
-	}
-	if s == "9di" {
-		err = "check rank:" + s
-	}
-	if s == "9f" {
-		err = "check rank:" + s
-	}
-	if s == "9g" {
-		err = "check rank:" + s
-	}
-	if s == "5e" {
-		err = "check rank:" + s
-	}
-	if s == "98d" {
-		err = "check rank:" + s
-	}
-	if s == "2D" {
-		err = "check rank:" + s
-	}
-	if s == "7d ams" {
-		err = "check rank:" + s
-	}
-	if s == "0d" {
-		err = "check rank:" + s
-	}
-	if s == "2.5" {
-		err = "check rank:" + s
-	}
-	if s == "NR" {
-		err = "check rank:" + s
-	}
-	if s == "a5" {
-		err = "check rank:" + s
-	}
-	if s == "a6" {
-		err = "check rank:" + s
-	}
-	if s == "Holder" {
-		err = "check rank:" + s
-	}
-	if s == " 7d" {
-		err = "check rank:" + s
-	}
-	if s == "Wangwi" {
-		err = "check rank:" + s
-	}
-	if s == "7d Ama" {
-		err = "check rank:" + s
-	}
-	return err
-}
-
-func check_Name(strVal []byte) (err string) {
-	s := string(strVal)
-	if s == "artu" {
-		err = "check name:" + s
-	}
-	if s == "jy23" {
-		err = "check name:" + s
-	}
-	if s == "thug" {
-		err = "check name:" + s
-	}
-	if s == "Yoshida" {
-		err = "check name:" + s
-	}
-	if s == "Yi" {
-		err = "check name:" + s
-	}
-	if s == "World" {
-		err = "check name:" + s
-	}
-	if s == "Turtles" {
-		err = "check name:" + s
-	}
-	if s == "Two shodans" {
-		err = "check name:" + s
-	}
-	if s == "Storks" {
-		err = "check name:" + s
-	}
-	if s == "Seo" {
-		err = "check name:" + s
-	}
-	if s == "Old Lady of Black-horse Mountain" {
-		err = "check name:" + s
-	}
-	if s == "NHK viewers, by internet poll" {
-		err = "check name:" + s
-	}
-	if s == "NO1NO1" {
-		err = "check name:" + s
-	}
-	if s == "MoGo Titan" {
-		err = "check name:" + s
-	}
-	if s == "Miss Y." {
-		err = "check name:" + s
-	}
-	if s == "Maeda" {
-		err = "check name:" + s
-	}
-	if s == "Li Ang" {
-		err = "check name:" + s
-	}
-	if s == "Kuwata" {
-		err = "check name:" + s
-	}
-	if s == "Kuboniwa" {
-		err = "check name:" + s
-	}
-	if s == "KCC Igo program" {
-		err = "check name:" + s
-	}
-	if s == "Harada" {
-		err = "check name:" + s
-	}
-	if s == "Goemate" {
-		err = "check name:" + s
-	}
-	if s == "Go Professional III" {
-		err = "check name:" + s
-	}
-	if s == "GO4++ program" {
-		err = "check name:" + s
-	}
-	if s == "Fukuhara" {
-		err = "check name:" + s
-	}
-	if s == "Fuji Hiroshi" {
-		err = "check name:" + s
-	}
-	if s == "Fairy" {
-		err = "check name:" + s
-	}
-	if s == "Anon." {
-		err = "check name:" + s
-	}
-	if s == "An Immortal" {
-		err = "check name:" + s
-	}
-	if s == "A Go Review subscriber" {
-		err = "check name:" + s
-	}
-	if s == "99P" {
-		err = "check name:" + s
-	}
-	if s == "Another Immortal" {
-		err = "check name:" + s
-	}
-	return err
-}
-
-func check_RE(strVal []byte) (err string) {
-	s := string(strVal)
-	/* First round of fixes:
-	if s == "B+2,5" {
-		err = "check value: B+2,5 (must be B+2.5)"
-	}
-	if s == "W+0,5" {
-		err = "check value: W+0,5 (must be W+0.5)"
-	}
-	if s == "W+40 zi" {
-		err = "check value: W+40 zi (must be BW+40)"
-	}
-	if s == "W+34 zi" {
-		err = "check value: W+34 zi (must be W+34)"
-	}
-	if s == "B+05." {
-		err = "check value: B+05. (must be B+0.5)"
-	}
-	if s == "W4.5" {
-		err = "check value: W4.5 (must be W+4.5)"
-	}
-	if s == "w+R" {
-		err = "check value: w+R (must be W+R)"
-	}
-	if s == "B8.5" {
-		err = "check value: B8.5 (must be B+8.5)"
-	}
-	if s == "B++" {
-		err = "check value: B++ (must be B+)"
-	}
-	*/
-	if s == "UF" {
-		err = "check value: UF"
-	}
-	if s == "W+jigo" {
-		err = "check value: W+jigo"
-	}
-	if s == "B+).5" {
-		err = "check value: B+).5"
-	}
-	if s == "B+8.8" {
-		err = "check value: B+8.8"
-	}
-	// This is O.K. (in 1988/1988-08-23c.sgf:)
-	// There is a seki and rules are Ing Goe
-	//
-	//	if s == "W+6.6" {
-	//		err = "check value: W+6.6"
-	//	}
-	if s == "W+3.35" {
-		err = "check value: W+3.35"
-	}
-	// This is O.K. (in 1988/1988-08-23b.sgf:)
-	// There is a seki and rules are Ing Goe
-	//
-	//	if s == "B+1.83" {
-	//		err = "check value: B+1.83"
-	//	}
-	if s == "B+11.4" {
-		err = "check value: B+11.4"
-	}
-	if s == "B+2.4" {
-		err = "check value: B+2.4"
-	}
-	if s == "B+1.55" {
-		err = "check value: B+1.55"
-	}
-	if s == "B+35." {
-		err = "check value: B+35."
-	}
-	if s == "B+.75" {
-		err = "check value: B+.75"
-	}
-	if s == "B+.5" {
-		err = "check value: B+.5"
-	}
-	return err
-}
-
 func SplitRE(str []byte) (val []byte, com []byte) {
 	iLP := bytes.IndexByte(str, '(')
 	iLB := bytes.IndexByte(str, '{')
@@ -799,11 +605,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 	//	fmt.Println("Node", nodd, "idx", idx, "Description", GetProperty(idx).Description);
 	//	os.Exit(998)
 	if p.dbstat {
-		if idx >= 0 {
-			p.DBStats.ID_Counts[idx] += 1
-		} else {
-			p.DBStats.Unkn_Count += 1
-		}
+		p.DBStats.countID(idx)
 	}
 	switch idx {
 
@@ -815,6 +617,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 				p.errors.Add(p.pos, "Bad Point for AB: "+err.Error()+" B["+string(pv.StrValue)+"]")
 			} else {
 				err = p.DoAB(mov, p.play)
+				p.checkSetup(ret, mov, ah.Black)
 				if len(err) != 0 {
 					p.errors.Add(p.pos, "Error from DoAB: "+err.Error()+" B["+string(pv.StrValue)+"]")
 				}
@@ -833,6 +636,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 					p.errors.Add(p.pos, "Bad Point List element for AB: "+err.Error()+" B["+string(pv.StrValue)+"]")
 				} else {
 					err = p.DoAB(mov, p.play)
+					p.checkSetup(ret, mov, ah.Black)
 					if len(err) != 0 {
 						p.errors.Add(p.pos, "Error from DoAB:"+err.Error()+"in List element, B["+string(pv.StrValue)+"]")
 					}
@@ -851,6 +655,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 				p.errors.Add(p.pos, "Bad Point for AE: "+err.Error()+": from "+string(pv.StrValue))
 			} else {
 				err = p.DoAE(mov, p.play)
+				p.checkSetup(ret, mov, ah.Unocc)
 				if len(err) != 0 {
 					p.errors.Add(p.pos, "Error from DoAE: "+err.Error()+": caused by "+string(pv.StrValue))
 				}
@@ -869,6 +674,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 					p.errors.Add(p.pos, "Bad Point List element for AE: "+err.Error()+": from "+string(npv.StrValue))
 				} else {
 					err = p.DoAE(mov, p.play)
+					p.checkSetup(ret, mov, ah.Unocc)
 					if len(err) != 0 {
 						p.errors.Add(p.pos, "Error from DoAE:"+err.Error()+" in List element: "+string(npv.StrValue))
 					}
@@ -892,8 +698,10 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case AR_idx:
-		// set the board AR:
-		p.DoAR(pv.StrValue)
+		// record the arrows:
+		if err := p.DoAR(pv.StrValue); len(err) != 0 {
+			p.ReportException(AR_idx, pv.StrValue, err.Error())
+		}
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -909,6 +717,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 				p.errors.Add(p.pos, "Bad Point for AW: "+err.Error()+": from "+string(pv.StrValue))
 			} else {
 				err = p.DoAW(mov, p.play)
+				p.checkSetup(ret, mov, ah.White)
 				if len(err) != 0 {
 					p.errors.Add(p.pos, "Error from DoAW: "+err.Error()+": caused by "+string(pv.StrValue))
 				}
@@ -927,6 +736,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 					p.errors.Add(p.pos, "Bad Point List element for AW: "+err.Error()+": from "+string(npv.StrValue))
 				} else {
 					err = p.DoAW(mov, p.play)
+					p.checkSetup(ret, mov, ah.White)
 					if len(err) != 0 {
 						p.errors.Add(p.pos, "Error from DoAW: "+err.Error()+" in List element: "+string(npv.StrValue))
 					}
@@ -944,10 +754,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 			p.errors.Add(p.pos, err.Error()+" in SGFPoint, B["+string(pv.StrValue)+"]")
 		} else {
 			p.treeNodes[ret].propListOrNodeLoc = PropIdx(mov)
-			movN, err := p.DoB(mov, p.play)
-			if movN == 1 && p.dbstat {
-				p.SetPlayerRank()
-			}
+			movN, err := p.doMove(ret, mov, ah.Black)
 			if len(err) != 0 {
 				p.warnings.Add(p.pos, err.Error()+" B["+string(pv.StrValue)+"]")
 			}
@@ -974,12 +781,10 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		if p.dbstat {
 			// count the BR values:
 			idx := string(pv.StrValue)
-			n, _ := p.DBStats.BWRank_map[idx]
-			p.DBStats.BWRank_map[idx] = n + 1
+			p.DBStats.count(p.DBStats.BWRank_map, idx)
 			// check the rank
-			errStr := check_Rank(pv.StrValue)
-			if errStr != "" {
-				p.ReportException(BR_idx, pv.StrValue, errStr)
+			if _, err := ParseRank(string(pv.StrValue)); err != nil {
+				p.ReportException(BR_idx, pv.StrValue, "check rank: "+err.Error())
 			}
 		}
 		// record the property:
@@ -1026,6 +831,14 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 	case DT_idx:
 		// set the board DT:
 		p.SetDT(pv.StrValue)
+		if p.dbstat {
+			// check the date
+			if dt, err := NormalizeDate(string(pv.StrValue)); err != nil {
+				p.ReportException(DT_idx, pv.StrValue, "check value: "+err.Error())
+			} else if dt != string(pv.StrValue) {
+				p.ReportException(DT_idx, pv.StrValue, "check value: "+string(pv.StrValue)+" (must be "+dt+")")
+			}
+		}
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -1076,8 +889,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		// count the HA values:
 		idx := string(pv.StrValue)
 		if p.dbstat {
-			n, _ := p.DBStats.HA_map[idx]
-			p.DBStats.HA_map[idx] = n + 1
+			p.DBStats.count(p.DBStats.HA_map, idx)
 		}
 		// set the board HA:
 		i, err := strconv.Atoi(idx)
@@ -1129,8 +941,10 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case LN_idx:
-		// set the board LN:
-		p.DoLN(pv.StrValue)
+		// record the lines:
+		if err := p.DoLN(pv.StrValue); len(err) != 0 {
+			p.ReportException(LN_idx, pv.StrValue, err.Error())
+		}
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -1161,8 +975,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		}
 		idx := string(strVal)
 		if p.dbstat {
-			n, _ := p.DBStats.OH_map[idx]
-			p.DBStats.OH_map[idx] = n + 1
+			p.DBStats.count(p.DBStats.OH_map, idx)
 		}
 		// set the board OH:
 		p.SetOH(strVal)
@@ -1174,6 +987,12 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case OT_idx:
+		if p.dbstat {
+			// check the overtime
+			if _, err := ParseTimeControl("", string(pv.StrValue)); err != nil {
+				p.ReportException(OT_idx, pv.StrValue, "check value: "+err.Error())
+			}
+		}
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -1185,20 +1004,9 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		// set the board PB:
 		p.SetPB(pv.StrValue)
 		if p.dbstat {
-			// count the Player values:
-			idx := string(pv.StrValue)
-			n, _ := p.DBStats.BWPlayer_map[idx]
-			n.NGames += 1
-			if n.FirstGame == "" {
-				n.FirstGame = GameName(p.pos.Filename)
-				p.GameTree.setFirstBRank = true
-			}
-			n.LastGame = GameName(p.pos.Filename)
-			p.DBStats.BWPlayer_map[idx] = n
 			// check the name
-			errStr := check_Name(pv.StrValue)
-			if errStr != "" {
-				p.ReportException(PB_idx, []byte(""), errStr)
+			if p.DBStats.Aliases.IsSuspect(string(pv.StrValue)) {
+				p.ReportException(PB_idx, []byte(""), "check name:"+string(pv.StrValue))
 			}
 		}
 		// record the property:
@@ -1211,6 +1019,8 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case PL_idx:
+		// set the player to move:
+		p.checkPlayer(ret, plColor(string(pv.StrValue)))
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -1222,20 +1032,9 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		// set the board PW:
 		p.SetPW(pv.StrValue)
 		if p.dbstat {
-			// count the Player values:
-			idx := string(pv.StrValue)
-			n, _ := p.DBStats.BWPlayer_map[idx]
-			n.NGames += 1
-			if n.FirstGame == "" {
-				n.FirstGame = GameName(p.pos.Filename)
-				p.GameTree.setFirstWRank = true
-			}
-			n.LastGame = GameName(p.pos.Filename)
-			p.DBStats.BWPlayer_map[idx] = n
 			// check the name
-			errStr := check_Name(pv.StrValue)
-			if errStr != "" {
-				p.ReportException(PW_idx, []byte(""), errStr)
+			if p.DBStats.Aliases.IsSuspect(string(pv.StrValue)) {
+				p.ReportException(PW_idx, []byte(""), "check name:"+string(pv.StrValue))
 			}
 		}
 		// record the property:
@@ -1247,9 +1046,8 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		if p.dbstat {
 			// count the RE values:
 			idx := string(RE_val)
-			n, _ := p.DBStats.RE_map[idx]
-			p.DBStats.RE_map[idx] = n + 1
-			errStr := check_RE(RE_val)
+			p.DBStats.count(p.DBStats.RE_map, idx)
+			errStr := checkResult(string(RE_val))
 			if errStr != "" {
 				p.ReportException(RE_idx, RE_val, errStr)
 			}
@@ -1259,8 +1057,7 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		if p.dbstat {
 			if len(RE_bas) > 0 {
 				idx2 := string(RE_bas)
-				n, _ := p.DBStats.RC_map[idx2]
-				p.DBStats.RC_map[idx2] = n + 1
+				p.DBStats.count(p.DBStats.RC_map, idx2)
 			}
 		}
 		// set the board RE:
@@ -1278,11 +1075,11 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		// count the RU values:
 		idx := string(pv.StrValue)
 		if p.dbstat {
-			n, _ := p.DBStats.RU_map[idx]
-			p.DBStats.RU_map[idx] = n + 1
+			p.DBStats.count(p.DBStats.RU_map, idx)
 		}
-		// set the board RU:
+		// set the board RU, and the rules of the moves checked:
 		p.SetRU(pv.StrValue)
+		p.checkRules()
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -1337,18 +1134,14 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case SZ_idx:
-		// set the board size:
-		var col int
-		var row int
-		idx_colon := strings.Index(string(pv.StrValue), ":")
-		if idx_colon > 0 {
-			col, _ = strconv.Atoi(string(pv.StrValue[0:idx_colon]))
-			row, _ = strconv.Atoi(string(pv.StrValue[idx_colon+1:]))
+		// set the board size, up to 52 by 52, which may be rectangular:
+		col, row, errS := ParseSize(pv.StrValue)
+		if len(errS) != 0 {
+			p.ReportException(SZ_idx, pv.StrValue, errS.Error())
 		} else {
-			col, _ = strconv.Atoi(string(pv.StrValue))
-			row = col
+			p.InitAbstHier(ah.ColSize(col), ah.RowSize(row), ah.StringLevel, p.play) // TODO: vary this?
 		}
-		p.InitAbstHier(ah.ColSize(col), ah.RowSize(row), ah.StringLevel, p.play) // TODO: vary this?
+		p.checker = nil
 		// record the property:
 		p.addProp(ret, pv)
 
@@ -1361,98 +1154,12 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case TM_idx:
-		// set the board TM:
-		// see if it is a real (float32) value (in seconds)
-		f, err := strconv.ParseFloat(string(pv.StrValue), 32)
+		// set the board TM, in seconds:
+		tc, err := ParseTimeControl(string(pv.StrValue), "")
 		if err != nil {
-			str := pv.StrValue
-			// see if it is hours:
-			idx := bytes.IndexByte(str, 'h')
-			if idx > 0 {
-				h, err2 := strconv.ParseFloat(string(str[0:idx]), 32)
-				if err2 == nil {
-					f = 60 * 60 * h
-					if idx < len(str)-1 {
-						str = str[idx+1:]
-						for (len(str) > 0) && (str[0] == ' ') {
-							str = str[1:]
-						}
-						if len(str) > 0 {
-							idx = bytes.IndexByte(str, 'm')
-							if idx > 0 {
-								m, err3 := strconv.ParseFloat(string(str[0:idx]), 32)
-								if err3 == nil {
-									f += 60 * m
-									if idx < len(str)-1 {
-										p.ReportException(TM_idx, pv.StrValue, "Extra h+m value: "+string(str[idx+1:]))
-										//											p.errors.Add(p.pos, "Extra h+m value: " + str[idx+1 :] + ": from " + string(pv.StrValue))
-									}
-								} else {
-									p.ReportException(TM_idx, pv.StrValue, err3.Error())
-									//										p.errors.Add(p.pos, "Bad minute value: " + err3.Error() + ": from " + string(pv.StrValue))
-								}
-							} else {
-								idx = bytes.Index(str, []byte("sudden death"))
-								if idx < 0 {
-									idx = bytes.Index(str, []byte("each"))
-									if idx < 0 {
-										p.ReportException(TM_idx, pv.StrValue, "Extra h value: "+string(str))
-										//											p.errors.Add(p.pos, "Extra h value: " + str +  ": from " + string(pv.StrValue))
-									}
-								}
-							}
-						}
-					}
-				} else {
-					p.ReportException(TM_idx, pv.StrValue, err2.Error())
-					//						p.errors.Add(p.pos, "Bad hour value: " + err2.Error() + ": from " + string(pv.StrValue[0:idx]))
-				}
-			} else {
-				idx := bytes.IndexByte(str, 'm')
-				if idx > 0 {
-					m, err3 := strconv.ParseFloat(string(str[0:idx]), 32)
-					if err3 == nil {
-						f = 60 * m
-						if idx < len(str)-1 {
-							str = str[idx+1:]
-							for (len(str) > 0) && (str[0] == ' ') {
-								str = str[1:]
-							}
-							if len(str) > 0 {
-								idx = bytes.IndexByte(str, 's')
-								if idx > 0 {
-									s, err4 := strconv.ParseFloat(string(str[0:idx]), 32)
-									if err4 == nil {
-										f += s
-										if idx < len(str)-1 {
-											p.ReportException(TM_idx, pv.StrValue, "Extra m+s value: "+string(str[idx+1:]))
-											//												p.errors.Add(p.pos, "Extra m+s value: " + str[idx+1 :] + ": from " + string(pv.StrValue))
-										}
-									} else {
-										p.ReportException(TM_idx, pv.StrValue, "Extra m+s value: "+string(str[idx+1:]))
-										//											p.errors.Add(p.pos, "Bad second value: " + err4.String() + ": from " + string(pv.StrValue))
-									}
-								} else {
-									idx = bytes.Index(str, []byte("sudden death"))
-									if idx < 0 {
-										p.ReportException(TM_idx, pv.StrValue, "Extra m value: "+string(str))
-										//											p.errors.Add(p.pos, "Extra m value: " + str + ": from " + string(pv.StrValue))
-									}
-								}
-							}
-
-						}
-					} else {
-						p.ReportException(TM_idx, pv.StrValue, err3.Error()+"Bad minute value: "+string(str))
-						//							p.errors.Add(p.pos, "Bad minute value: " + err3.String() + ": from " + string(pv.StrValue))
-					}
-				} else {
-					p.ReportException(TM_idx, pv.StrValue, err.Error())
-					//						p.errors.Add(p.pos, "Bad Timelimit value: " + err.Error() + ": from " + str)
-				}
-			}
+			p.ReportException(TM_idx, pv.StrValue, err.Error())
 		}
-		p.SetTM(float32(f))
+		p.SetTM(float32(tc.Main))
 		// record the property
 		p.addProp(ret, pv)
 
@@ -1489,13 +1196,10 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 			p.errors.Add(p.pos, err.Error()+" in SGFPoint, W["+string(pv.StrValue)+"]")
 		} else {
 			p.treeNodes[ret].propListOrNodeLoc = PropIdx(mov)
-			movN, err := p.DoW(mov, p.play)
+			movN, err := p.doMove(ret, mov, ah.White)
 			if len(err) != 0 {
 				p.warnings.Add(p.pos, err.Error()+" W["+string(pv.StrValue)+"]")
 			}
-			if movN == 1 && p.dbstat {
-				p.SetPlayerRank()
-			}
 			if (p.moveLimit > 0) && (movN >= p.moveLimit) {
 				p.limitReached = true
 				if p.trace {
@@ -1506,20 +1210,49 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		}
 
 	case WB_idx:
-		// record the property:
-		p.addProp(ret, pv)
+		// set the win count of the pattern node:
+		n, err := strconv.Atoi(string(pv.StrValue))
+		if err != nil {
+			p.errors.Add(p.pos, "WB["+string(pv.StrValue)+"] is not a number")
+		} else {
+			p.setPatternWins(ret, ah.Black, n)
+		}
 
 	case WC_idx:
-		// record the property:
-		p.addProp(ret, pv)
+		// record the continuation game, name:move
+		var err error
+		idx_colon := strings.LastIndex(string(pv.StrValue), ":")
+		n := -1
+		if idx_colon > 0 {
+			n, err = strconv.Atoi(string(pv.StrValue[idx_colon+1:]))
+		}
+		if n < 0 || err != nil {
+			p.errors.Add(p.pos, "WC["+string(pv.StrValue)+"] is not name:move")
+		} else {
+			src := p.addPatternSource(unescapeSimpleText(pv.StrValue[0:idx_colon]))
+			p.addPatternCont(ret, src, n)
+		}
+
+	case WG_idx:
+		// record the source game:
+		if len(pv.StrValue) == 0 {
+			p.errors.Add(p.pos, "WG[] has no game name")
+		} else {
+			p.addPatternGame(ret, p.addPatternSource(unescapeSimpleText(pv.StrValue)))
+		}
 
 	case WL_idx:
 		// record the property:
 		p.addProp(ret, pv)
 
 	case WO_idx:
-		// record the property:
-		p.addProp(ret, pv)
+		// set the win count of the pattern node:
+		n, err := strconv.Atoi(string(pv.StrValue))
+		if err != nil {
+			p.errors.Add(p.pos, "WO["+string(pv.StrValue)+"] is not a number")
+		} else {
+			p.setPatternWins(ret, ah.Unocc, n)
+		}
 
 	case WR_idx:
 		// set the board WR:
@@ -1527,12 +1260,10 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		if p.dbstat {
 			// count the WR values:
 			idx := string(pv.StrValue)
-			n, _ := p.DBStats.BWRank_map[idx]
-			p.DBStats.BWRank_map[idx] = n + 1
+			p.DBStats.count(p.DBStats.BWRank_map, idx)
 			// check the rank
-			errStr := check_Rank(pv.StrValue)
-			if errStr != "" {
-				p.ReportException(WR_idx, pv.StrValue, errStr)
+			if _, err := ParseRank(string(pv.StrValue)); err != nil {
+				p.ReportException(WR_idx, pv.StrValue, "check rank: "+err.Error())
 			}
 		}
 		// record the property:
@@ -1545,8 +1276,13 @@ func (p *Parser) processProperty(pv PropertyValue, nodd TreeNodeIdx) (ret TreeNo
 		p.addProp(ret, pv)
 
 	case WW_idx:
-		// record the property:
-		p.addProp(ret, pv)
+		// set the win count of the pattern node:
+		n, err := strconv.Atoi(string(pv.StrValue))
+		if err != nil {
+			p.errors.Add(p.pos, "WW["+string(pv.StrValue)+"] is not a number")
+		} else {
+			p.setPatternWins(ret, ah.White, n)
+		}
 
 	case UnknownPropIdx:
 		// for UnknownProperty, add composed two strings: first is name, second is value
@@ -1590,6 +1326,12 @@ func (p *Parser) parseProperties(inRoot bool, parentNode TreeNodeIdx) (returnNod
 			if err != nil {
 				p.warningPropertyType(p.pos, err)
 			}
+			if !inRoot && prop.FF4Type == GameInfoProp {
+				p.gameInfoBelow = true
+				if above := p.gameInfoAbove(parentNode); above != nilTreeNodeIdx {
+					p.warningPropertyType(p.pos, fmt.Errorf("GameInfoProp below game-info at node %d", above))
+				}
+			}
 		}
 		p.next()
 		// TODO: does this need to be a for loop? for more than one value?
@@ -1599,9 +1341,44 @@ func (p *Parser) parseProperties(inRoot bool, parentNode TreeNodeIdx) (returnNod
 		propVal.PropType = IDidx
 		returnNode = p.processProperty(propVal, returnNode)
 	}
+	if !inRoot && p.dbstat && p.hasGameInfo(parentNode) {
+		// record only the players named at this node: the others were recorded above it
+		gi := p.GameInfoAt(parentNode)
+		if p.propString(parentNode, PB_idx) == "" {
+			gi.PB = ""
+		}
+		if p.propString(parentNode, PW_idx) == "" {
+			gi.PW = ""
+		}
+		p.recordPlayers(gi)
+	}
 	return returnNode
 }
 
+// parseVariation parses a variation from node, and then backs up to node.
+// The game-info of a variation applies only to it: if the variation has game-info,
+// the game-info that applies at node is cached again, with its rules, KM, and HA.
+func (p *Parser) parseVariation(node TreeNodeIdx) {
+	p.next()
+	mark := p.checkMark()
+	below := p.gameInfoBelow
+	p.gameInfoBelow = false
+	newLeaf := p.parseNodeSequence(node)
+	if p.limitReached != true {
+		// Backup the Board State to node
+		p.backUp(node, newLeaf, mark)
+		if p.gameInfoBelow {
+			gi := p.GameInfoAt(node)
+			for _, f := range gameInfoFields {
+				p.cacheGameInfo(f.prop, []byte(*f.field(&gi)))
+			}
+			p.checkRules()
+		}
+		p.expect(RPAREN)
+	}
+	p.gameInfoBelow = p.gameInfoBelow || below
+}
+
 func (p *Parser) parseNodeSequence(parentNode TreeNodeIdx) (returnNode TreeNodeIdx) {
 	if p.trace {
 		defer un(trace(p, "parseNodeSequence"))
@@ -1624,20 +1401,7 @@ func (p *Parser) parseNodeSequence(parentNode TreeNodeIdx) (returnNode TreeNodeI
 			p.next()
 			returnNode = p.parseProperties(false, returnNode)
 		case LPAREN:
-			p.next()
-			newLeaf := p.parseNodeSequence(TreeNodeIdx(returnNode))
-			if p.limitReached != true {
-				// Backup the Board State to returnNode
-				currentNode := newLeaf
-				for currentNode != returnNode {
-					// for loop allows for more than one move at a node, i.e. S[]
-					for p.GetMovDepth() > p.treeNodes[currentNode].movDepth {
-						p.UndoBoardMove(p.play)
-					}
-					currentNode = p.treeNodes[currentNode].Parent
-				}
-				p.expect(RPAREN)
-			}
+			p.parseVariation(returnNode)
 		default:
 			p.expect2(RPAREN, SEMICOLON)
 		}
@@ -1654,10 +1418,19 @@ func (p *Parser) parseGame(parentNode TreeNodeIdx) (returnNode TreeNodeIdx) {
 	p.expect(SEMICOLON)
 
 	// add GameInfo node
+	p.checker = nil
+	p.gameInfoBelow = false
 	newGame := p.addNode(parentNode, GameInfoNode)
+	// each game has its own board, and cached properties,
+	// so the node starts at the move depth of the new board
+	p.addGame(newGame, p.play)
+	p.treeNodes[newGame].movDepth = p.Board.GetMovDepth()
 
 	// parse GameInfo properties
 	returnNode = p.parseProperties(true, newGame)
+	if p.dbstat {
+		p.SetPlayerRank()
+	}
 
 	// parse interior move Nodes
 	for (p.tok != RPAREN) && (p.tok != EOF) && (p.limitReached != true) {
@@ -1667,20 +1440,7 @@ func (p *Parser) parseGame(parentNode TreeNodeIdx) (returnNode TreeNodeIdx) {
 			p.next()
 			returnNode = p.parseProperties(false, returnNode)
 		case LPAREN:
-			p.next()
-			newLeaf := p.parseNodeSequence(TreeNodeIdx(returnNode))
-			if p.limitReached != true {
-				// Backup the Board State to returnNode
-				currentNode := newLeaf
-				for currentNode != returnNode {
-					// for loop allows for more than one move at a node, i.e. S[]
-					for p.GetMovDepth() > p.treeNodes[currentNode].movDepth {
-						p.UndoBoardMove(p.play)
-					}
-					currentNode = p.treeNodes[currentNode].Parent
-				}
-				p.expect(RPAREN)
-			}
+			p.parseVariation(returnNode)
 		default:
 			p.expect2(RPAREN, SEMICOLON)
 		}
@@ -1690,6 +1450,13 @@ func (p *Parser) parseGame(parentNode TreeNodeIdx) (returnNode TreeNodeIdx) {
 		p.next()
 	}
 
+	// the game-info cached while parsing is from the last game-info node parsed:
+	// cache the game-info of the main line instead.
+	// (bad values were reported when they were parsed.)
+	if p.gameInfoBelow {
+		p.cacheMainLineGameInfo(newGame)
+	}
+
 	return returnNode
 }
 
@@ -1708,6 +1475,8 @@ func (p *Parser) parseFile() {
 
 	if p.treeNodes[fileCollection].Children == nilTreeNodeIdx {
 		p.errors.Add(p.pos, "file contains no games")
+	} else {
+		p.SelectGame(0)
 	}
 
 	if p.errors.ErrorCount() > 0 {
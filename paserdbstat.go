@@ -12,8 +12,13 @@ package sgf
 
 import (
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
+	"strconv"
+	"sync"
 	//    "github.com/Ken1JF/ah"
 	//    "os"
 	//    "strconv"
@@ -23,15 +28,52 @@ import (
 
 // Tyoe PlayerInfo is used to record statistics about players in a data base
 // of games in .sgf format. It records the number of games, the name of the
-// first game encountered, the rank of the player in the first game, the name
-// of the last game, and rank at the last game. Note: this assumes the games
-// are retrieved in chronological order, from oldest to newest.
+// first game, the rank of the player in the first game, the name
+// of the last game, and rank at the last game. Games are placed in order by
+// their DT (FirstDate and LastDate are the keys of DateKey), and games without
+// a DT in the order they are found. A game with a DT is preferred, as the first
+// or last game, to games without one.
+// LowRank and HighRank are the lowest and highest ranks that could be parsed.
 type PlayerInfo struct {
 	NGames    int
 	FirstGame string
 	FirstRank string
+	FirstDate string
 	LastGame  string
 	LastRank  string
+	LastDate  string
+	LowRank   Rank
+	HighRank  Rank
+}
+
+// setFirst makes game the first game, if it is earlier than the first game.
+// returns true if it is now the first game.
+func (pi *PlayerInfo) setFirst(game string, rank string, date string) bool {
+	if pi.FirstGame == "" || (date != "" && (pi.FirstDate == "" || date < pi.FirstDate)) {
+		pi.FirstGame, pi.FirstRank, pi.FirstDate = game, rank, date
+		return true
+	}
+	return false
+}
+
+// setLast makes game the last game, if it is not earlier than the last game.
+func (pi *PlayerInfo) setLast(game string, rank string, date string) {
+	if pi.LastGame == "" || (date == "" && pi.LastDate == "") || (date != "" && (pi.LastDate == "" || date >= pi.LastDate)) {
+		pi.LastGame, pi.LastRank, pi.LastDate = game, rank, date
+	}
+}
+
+// addRank records a parsed rank in LowRank and HighRank.
+func (pi *PlayerInfo) addRank(r Rank) {
+	if !r.Known() {
+		return
+	}
+	if !pi.LowRank.Known() || r.Compare(pi.LowRank) < 0 {
+		pi.LowRank = r
+	}
+	if !pi.HighRank.Known() || r.Compare(pi.HighRank) > 0 {
+		pi.HighRank = r
+	}
 }
 
 // The type indexedCount is used to hold the contents of
@@ -61,13 +103,16 @@ func (bc ByCount) Less(i, j int) bool {
 }
 
 // The struct DBStatistics is used to record statistics about a data base
-// of games in .sgf format.
+// of games in .sgf format. A DBStatistics is safe to use from many goroutines,
+// and the statistics of several workers can be combined with Merge.
 type DBStatistics struct {
+	mu sync.Mutex // protects all of the following
+
 	ID_Counts  ID_CountArray // count of occurances of SGF IDs
 	Unkn_Count int           // count of unknown SGF IDs
 
-	FirstBRankNotSet int // count of times setFirstBRank was not set
-	FirstWRankNotSet int // count of times setFirstWRank was not set
+	FirstBRankNotSet int // count of ranks of black players, not recorded as a first rank
+	FirstWRankNotSet int // count of ranks of white players, not recorded as a first rank
 
 	HA_map mapStringInt // count the occurances of handicap values
 	OH_map mapStringInt // count the occurances of old handicap values
@@ -79,12 +124,20 @@ type DBStatistics struct {
 	RU_map     mapStringInt // count the occurances of rules values
 	BWRank_map mapStringInt // count the occurances of rank values
 
-	BWPlayer_map map[string]PlayerInfo // accumulate player information
+	BWPlayer_map map[string]PlayerInfo // accumulate player information, by canonical name
+
+	// Aliases, if not nil, resolves the names of players, and lists the suspect names.
+	// If nil, the !suspect names of sgf_player_aliases.txt are reported.
+	// It is not changed by the statistics, and may be shared.
+	Aliases *PlayerAliases
 }
 
-// The variable theDBStatistics is used to share statistics among many
-// parsers.
-var theDBStatistics *DBStatistics
+// NewDBStatistics returns an empty DBStatistics, ready for use.
+func NewDBStatistics() *DBStatistics {
+	dbStat := new(DBStatistics)
+	dbStat.initStats()
+	return dbStat
+}
 
 // The function initStats must be called before using a DBStatistics struct.
 func (dbStat *DBStatistics) initStats() {
@@ -101,6 +154,109 @@ func (dbStat *DBStatistics) initStats() {
 	dbStat.BWPlayer_map = make(map[string]PlayerInfo, 100)
 }
 
+// countID counts an occurance of the SGF ID idx, or of an unknown ID if idx < 0.
+func (dbStat *DBStatistics) countID(idx PropertyDefIdx) {
+	dbStat.mu.Lock()
+	if idx >= 0 {
+		dbStat.ID_Counts[idx] += 1
+	} else {
+		dbStat.Unkn_Count += 1
+	}
+	dbStat.mu.Unlock()
+}
+
+// count counts an occurance of the value idx in aMap, one of the maps of dbStat.
+func (dbStat *DBStatistics) count(aMap mapStringInt, idx string) {
+	dbStat.mu.Lock()
+	aMap[idx] += 1
+	dbStat.mu.Unlock()
+}
+
+// addPlayerGame records a game played by name, in the game gameName,
+// with the rank of the player, and the date key of the game (see DateKey).
+// If the game is not the first game of the player, and has a rank,
+// the FirstBRankNotSet (or FirstWRankNotSet) count is incremented.
+func (dbStat *DBStatistics) addPlayerGame(name string, gameName string, rank string, date string, black bool) {
+	dbStat.mu.Lock()
+	pi, _ := dbStat.BWPlayer_map[name]
+	pi.NGames += 1
+	if r, err := ParseRank(rank); err == nil {
+		pi.addRank(r)
+	}
+	if !pi.setFirst(gameName, rank, date) && rank != "" {
+		if black {
+			dbStat.FirstBRankNotSet += 1
+		} else {
+			dbStat.FirstWRankNotSet += 1
+		}
+	}
+	pi.setLast(gameName, rank, date)
+	dbStat.BWPlayer_map[name] = pi
+	dbStat.mu.Unlock()
+}
+
+// copyStats returns a copy of dbStat, made while holding its lock.
+func (dbStat *DBStatistics) copyStats() (cp *DBStatistics) {
+	cp = NewDBStatistics()
+	dbStat.mu.Lock()
+	defer dbStat.mu.Unlock()
+	cp.ID_Counts = dbStat.ID_Counts
+	cp.Unkn_Count = dbStat.Unkn_Count
+	cp.FirstBRankNotSet = dbStat.FirstBRankNotSet
+	cp.FirstWRankNotSet = dbStat.FirstWRankNotSet
+	for _, m := range [][2]mapStringInt{{cp.HA_map, dbStat.HA_map}, {cp.OH_map, dbStat.OH_map},
+		{cp.RE_map, dbStat.RE_map}, {cp.RC_map, dbStat.RC_map},
+		{cp.RU_map, dbStat.RU_map}, {cp.BWRank_map, dbStat.BWRank_map}} {
+		for s, n := range m[1] {
+			m[0][s] = n
+		}
+	}
+	for s, pi := range dbStat.BWPlayer_map {
+		cp.BWPlayer_map[s] = pi
+	}
+	return cp
+}
+
+// Merge adds the statistics of other to dbStat.
+// The counts are added. For players found in both, the first game and rank
+// of dbStat are kept, and the last game and rank are taken from other.
+// So workers that each read a part of a chronological data base should
+// be merged in order. Both should use the same Aliases.
+func (dbStat *DBStatistics) Merge(other *DBStatistics) {
+	if other == dbStat {
+		return
+	}
+	o := other.copyStats()
+	dbStat.mu.Lock()
+	defer dbStat.mu.Unlock()
+	for i, n := range o.ID_Counts {
+		dbStat.ID_Counts[i] += n
+	}
+	dbStat.Unkn_Count += o.Unkn_Count
+	dbStat.FirstBRankNotSet += o.FirstBRankNotSet
+	dbStat.FirstWRankNotSet += o.FirstWRankNotSet
+	for _, m := range [][2]mapStringInt{{dbStat.HA_map, o.HA_map}, {dbStat.OH_map, o.OH_map},
+		{dbStat.RE_map, o.RE_map}, {dbStat.RC_map, o.RC_map},
+		{dbStat.RU_map, o.RU_map}, {dbStat.BWRank_map, o.BWRank_map}} {
+		for s, n := range m[1] {
+			m[0][s] += n
+		}
+	}
+	for s, opi := range o.BWPlayer_map {
+		pi, _ := dbStat.BWPlayer_map[s]
+		if opi.FirstGame != "" {
+			pi.setFirst(opi.FirstGame, opi.FirstRank, opi.FirstDate)
+		}
+		if opi.LastGame != "" {
+			pi.setLast(opi.LastGame, opi.LastRank, opi.LastDate)
+		}
+		pi.NGames += opi.NGames
+		pi.addRank(opi.LowRank)
+		pi.addRank(opi.HighRank)
+		dbStat.BWPlayer_map[s] = pi
+	}
+}
+
 // The function GameName returns the last portion of a filename for
 // use as the game name.
 func GameName(fileName string) string {
@@ -117,46 +273,34 @@ func GameName(fileName string) string {
 	return string(name)
 }
 
-// The function SetPlayerRank is called after parsing the first move.
+// The function SetPlayerRank is called after parsing the game-info properties
+// of a game. It records the game, the ranks, and the date, for each player.
 func (p *Parser) SetPlayerRank() {
-	// set the rank for the black name
-	bn := p.GameTree.GetPB()
-	if bn != nil {
-		br := p.GameTree.GetBR()
-		if br != nil {
-			ix := string(bn)
-			np, _ := p.DBStats.BWPlayer_map[ix]
-			np.LastRank = string(br)
-			if p.GameTree.setFirstBRank {
-				np.FirstRank = string(br)
-			} else {
-				p.DBStats.FirstBRankNotSet += 1
-			}
-			p.DBStats.BWPlayer_map[ix] = np
-		} else {
-			// fmt.Println("Error, Black Player:",bn,"has nil reank.")
-		}
+	var gi GameInfo
+	gi.PB, gi.BR = string(p.GameTree.GetPB()), string(p.GameTree.GetBR())
+	gi.PW, gi.WR = string(p.GameTree.GetPW()), string(p.GameTree.GetWR())
+	gi.DT = string(p.GameTree.dT)
+	p.recordPlayers(gi)
+}
+
+// recordPlayers records the game, the ranks, and the date, for each player of gi.
+// It is called for each game-info node, since a game may have several below its root.
+func (p *Parser) recordPlayers(gi GameInfo) {
+	game := GameName(p.pos.Filename)
+	date := ""
+	if gi.DT != "" {
+		date = DateKey(gi.DT)
+	}
+	// record the black player
+	if gi.PB != "" {
+		p.DBStats.addPlayerGame(p.DBStats.Aliases.Resolve(gi.PB), game, gi.BR, date, true)
 	} else {
 		// TODO: this rare. do we need an option to report this?
 		// fmt.Println("Error: PB name is nil.")
 	}
-	// set the rank for the white name
-	wn := p.GameTree.GetPW()
-	if wn != nil {
-		wr := p.GameTree.GetWR()
-		if wr != nil {
-			ix := string(wn)
-			np, _ := p.DBStats.BWPlayer_map[ix]
-			np.LastRank = string(wr)
-			if p.GameTree.setFirstWRank {
-				np.FirstRank = string(wr)
-			} else {
-				p.DBStats.FirstWRankNotSet += 1
-			}
-			p.DBStats.BWPlayer_map[ix] = np
-		} else {
-			// fmt.Println("Error, white Player:",wn,"has nil reank.")
-		}
+	// record the white player
+	if gi.PW != "" {
+		p.DBStats.addPlayerGame(p.DBStats.Aliases.Resolve(gi.PW), game, gi.WR, date, false)
 	} else {
 		// TODO: this rare. do we need an option to report this?
 		// fmt.Println("Error: PW name is nil.")
@@ -223,37 +367,22 @@ func (dbstat *DBStatistics) reportIDCounts() {
 	}
 }
 
-// TODO: sort by second field (last name) if present
-func gtr(a []byte, b []byte) bool {
-	idx := 0
-	for (idx < len(a)) && (idx < len(b)) {
-		if a[idx] > b[idx] {
-			return true
-		} else if a[idx] < b[idx] {
-			return false
-		}
-		idx += 1
-	}
-	if len(a) > len(b) {
-		return true
-	}
-	return false
-}
-
-func (aMap mapStringInt) reportSortedCounts(what string) {
-	var bc ByCount
-	bc = make([]indexedCount, len(aMap))
+// sortedCounts returns the contents of aMap, sorted by decreasing count,
+// and the sum of the counts.
+func (aMap mapStringInt) sortedCounts() (bc ByCount, sum int) {
+	bc = make([]indexedCount, 0, len(aMap))
 	// move map to array
-	sum := 0
-	i := 0
 	for s, n := range aMap {
-		bc[i].idx = s
-		bc[i].cnt = n
-		i += 1
+		bc = append(bc, indexedCount{s, n})
 		sum += n
 	}
 	// sort array
 	sort.Sort(bc)
+	return bc, sum
+}
+
+func (aMap mapStringInt) reportSortedCounts(what string) {
+	bc, sum := aMap.sortedCounts()
 	// report
 	for _, n := range bc {
 		fmt.Println(what, n.idx, "occurred", n.cnt, "times.")
@@ -285,79 +414,155 @@ func (dbstat *DBStatistics) reportRankCounts() {
 	dbstat.BWRank_map.reportSortedCounts("Rank")
 }
 
-func (dbstat *DBStatistics) reportPlayers() {
-	// report the BWPlayer map
-	//	sum = 0
-	//	for s, n := range BWPlayer_map {
-	//		fmt.Printf("Player %s occurred %d times, first %s, %s last %s, %s.\n", s, n.NGames, n.FirstGame, n.FirstRank, n.LastGame, n.LastRank)
-	//		sum += n.NGames
-	//	}
-	//	fmt.Printf("Total players %d with %d different names\n", sum, len(BWPlayer_map))
+// printPlayer prints the statistics of one player, after the prefix.
+func printPlayer(prefix string, n PlayerInfo) {
+	fmt.Printf("%s, first: %s, %s, last: %s,", prefix, n.FirstGame, n.FirstRank, n.LastGame)
+	if n.LastRank != "" {
+		fmt.Printf(" %s\n", n.LastRank)
+	} else {
+		fmt.Printf("\n")
+	}
+}
 
+func (dbstat *DBStatistics) reportPlayers() {
 	// sort the Player names, with counts:
-	nPlayers := len(dbstat.BWPlayer_map)
-	var playerNames [][]byte
-	var playerCount []int
-	playerNames = make([][]byte, nPlayers)
-	playerCount = make([]int, nPlayers)
-	idx := 0
+	bc := make(ByCount, 0, len(dbstat.BWPlayer_map))
 	for s, n := range dbstat.BWPlayer_map {
-		playerNames[idx] = make([]byte, len(s))
-		_ = copy(playerNames[idx], s)
-		playerCount[idx] = n.NGames
-		idx += 1
+		bc = append(bc, indexedCount{s, n.NGames})
 	}
 	// Sort them alphabetically:
-	for ix := 0; ix < nPlayers; ix++ {
-		for iy := ix; iy < nPlayers; iy++ {
-			if gtr(playerNames[ix], playerNames[iy]) {
-				playerNames[ix], playerNames[iy] = playerNames[iy], playerNames[ix]
-				playerCount[ix], playerCount[iy] = playerCount[iy], playerCount[ix]
-			}
-		}
+	sort.Sort(byIdx(bc))
+	for _, c := range bc {
+		printPlayer(fmt.Sprintf("Player %s: %d", c.idx, c.cnt), dbstat.BWPlayer_map[c.idx])
 	}
-	for i, s := range playerNames {
-		n, _ := dbstat.BWPlayer_map[string(s)]
-		fmt.Printf("Player %s: %d, first: %s, %s, last: %s,", s, playerCount[i], n.FirstGame, n.FirstRank, n.LastGame)
-		if n.LastRank != "" {
-			fmt.Printf(" %s\n", n.LastRank)
-		} else {
-			fmt.Printf("\n")
-		}
+	// Sort them numerically:
+	sort.Sort(bc)
+	for _, c := range bc {
+		printPlayer(fmt.Sprintf(" %d : %s", c.cnt, c.idx), dbstat.BWPlayer_map[c.idx])
 	}
+}
 
-	// Sort them numerically:
-	for ix := 0; ix < nPlayers; ix++ {
-		for iy := ix; iy < nPlayers; iy++ {
-			if playerCount[ix] < playerCount[iy] {
-				playerNames[ix], playerNames[iy] = playerNames[iy], playerNames[ix]
-				playerCount[ix], playerCount[iy] = playerCount[iy], playerCount[ix]
-			}
-		}
+// Type byIdx implements the sort.Interface based on the index string.
+type byIdx []indexedCount
+
+func (bi byIdx) Len() int           { return len(bi) }
+func (bi byIdx) Swap(i, j int)      { bi[i], bi[j] = bi[j], bi[i] }
+func (bi byIdx) Less(i, j int) bool { return bi[i].idx < bi[j].idx }
+
+// UnresolvedAliases returns the players that are likely to be another name
+// of a known player, or of a player with more games. See PlayerAliases.UnresolvedAliases.
+func (dbstat *DBStatistics) UnresolvedAliases() []AliasSuggestion {
+	dbstat.mu.Lock()
+	defer dbstat.mu.Unlock()
+	return dbstat.unresolvedAliases()
+}
+
+func (dbstat *DBStatistics) unresolvedAliases() []AliasSuggestion {
+	games := make(map[string]int, len(dbstat.BWPlayer_map))
+	for s, n := range dbstat.BWPlayer_map {
+		games[s] = n.NGames
 	}
-	for i, s := range playerCount {
-		n, _ := dbstat.BWPlayer_map[string(playerNames[i])]
-		fmt.Printf(" %d : %s, first: %s, %s, last: %s,", s, playerNames[i], n.FirstGame, n.FirstRank, n.LastGame)
-		if n.LastRank != "" {
-			fmt.Printf(" %s\n", n.LastRank)
-		} else {
-			fmt.Printf("\n")
-		}
+	return dbstat.Aliases.UnresolvedAliases(games)
+}
+
+func (dbstat *DBStatistics) reportUnresolvedAliases() {
+	for _, a := range dbstat.unresolvedAliases() {
+		fmt.Printf("Unresolved alias %s: %d, maybe %s (%s)\n", a.Name, a.Games, a.Suggest, a.Reason)
 	}
 }
 
-func ReportSGFCounts() {
-	if theDBStatistics != nil {
-		theDBStatistics.reportIDCounts()
+// Report prints the statistics.
+func (dbstat *DBStatistics) Report() {
+	dbstat.mu.Lock()
+	defer dbstat.mu.Unlock()
+	dbstat.reportIDCounts()
+
+	dbstat.reportHACounts()
+	dbstat.reportOHCounts()
+	dbstat.reportRECounts()
+	dbstat.reportRCCounts()
+	dbstat.reportRUCounts()
+	dbstat.reportRankCounts()
+
+	dbstat.reportPlayers()
+	if dbstat.Aliases != nil {
+		dbstat.reportUnresolvedAliases()
+	}
+}
 
-		theDBStatistics.reportHACounts()
-		theDBStatistics.reportOHCounts()
-		theDBStatistics.reportRECounts()
-		theDBStatistics.reportRCCounts()
-		theDBStatistics.reportRUCounts()
-		theDBStatistics.reportRankCounts()
+// dbStatsTables returns the names of the count maps, as used by WriteJSON and WriteCSV.
+func (dbstat *DBStatistics) dbStatsTables() (names []string, maps []mapStringInt) {
+	return []string{"Handicap", "OldHandicap", "Result", "ResultComment", "Rules", "Rank"},
+		[]mapStringInt{dbstat.HA_map, dbstat.OH_map, dbstat.RE_map, dbstat.RC_map, dbstat.RU_map, dbstat.BWRank_map}
+}
 
-		theDBStatistics.reportPlayers()
-		theDBStatistics = nil
+// WriteJSON writes the statistics to w, as a JSON object with the fields:
+//	Properties: the count of each SGF ID used, "?Unkn?" for unknown IDs
+//	FirstBRankNotSet, FirstWRankNotSet
+//	Handicap, OldHandicap, Result, ResultComment, Rules, Rank: the count of each value
+//	Players: the PlayerInfo of each player
+func (dbstat *DBStatistics) WriteJSON(w io.Writer) error {
+	dbstat.mu.Lock()
+	defer dbstat.mu.Unlock()
+	obj := make(map[string]interface{})
+	props := make(map[string]int)
+	for i, c := range dbstat.ID_Counts {
+		if c > 0 {
+			props[string(GetProperty(PropertyDefIdx(i)).ID)] = c
+		}
+	}
+	if dbstat.Unkn_Count > 0 {
+		props["?Unkn?"] = dbstat.Unkn_Count
+	}
+	obj["Properties"] = props
+	obj["FirstBRankNotSet"] = dbstat.FirstBRankNotSet
+	obj["FirstWRankNotSet"] = dbstat.FirstWRankNotSet
+	names, maps := dbstat.dbStatsTables()
+	for i, name := range names {
+		obj[name] = maps[i]
+	}
+	obj["Players"] = dbstat.BWPlayer_map
+	enc := json.NewEncoder(w)
+	return enc.Encode(obj)
+}
+
+// WriteCSV writes the statistics to w, as CSV records with the fields:
+//	table, value, count, first game, first rank, last game, last rank
+// The table is "Property", "Handicap", "OldHandicap", "Result", "ResultComment",
+// "Rules", "Rank", or "Player". The last four fields are only used for players.
+// The records of each table are in order of decreasing count.
+func (dbstat *DBStatistics) WriteCSV(w io.Writer) error {
+	dbstat.mu.Lock()
+	defer dbstat.mu.Unlock()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"table", "value", "count", "first game", "first rank", "last game", "last rank"})
+	props := make(mapStringInt)
+	for i, c := range dbstat.ID_Counts {
+		if c > 0 {
+			props[string(GetProperty(PropertyDefIdx(i)).ID)] = c
+		}
+	}
+	if dbstat.Unkn_Count > 0 {
+		props["?Unkn?"] = dbstat.Unkn_Count
+	}
+	names, maps := dbstat.dbStatsTables()
+	names = append([]string{"Property"}, names...)
+	maps = append([]mapStringInt{props}, maps...)
+	for i, name := range names {
+		bc, _ := maps[i].sortedCounts()
+		for _, c := range bc {
+			cw.Write([]string{name, c.idx, strconv.Itoa(c.cnt), "", "", "", ""})
+		}
+	}
+	bc := make(ByCount, 0, len(dbstat.BWPlayer_map))
+	for s, n := range dbstat.BWPlayer_map {
+		bc = append(bc, indexedCount{s, n.NGames})
+	}
+	sort.Sort(bc)
+	for _, c := range bc {
+		pi := dbstat.BWPlayer_map[c.idx]
+		cw.Write([]string{"Player", c.idx, strconv.Itoa(c.cnt), pi.FirstGame, pi.FirstRank, pi.LastGame, pi.LastRank})
 	}
+	cw.Flush()
+	return cw.Error()
 }
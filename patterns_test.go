@@ -0,0 +1,305 @@
+package sgf_test
+
+import (
+	"fmt"
+	"github.com/Ken1JF/ah"
+	"github.com/Ken1JF/sgf"
+	"io/ioutil"
+	"os"
+)
+
+// A short game with play in all four corners, and a tenuki in the upper right.
+const josekiTestGame = "(;FF[4]GM[1]SZ[19]RE[B+R];B[pd];W[dd];B[pq];W[qf];B[nc];W[dp];B[rd])"
+
+func ExampleGameTree_AddJosekiPatterns() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	prsr, errL := sgf.ParseFile("josekiTestGame", []byte(josekiTestGame), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	var josTree *sgf.GameTree
+	// add the game twice, to show the counts
+	for i := 0; i < 2; i++ {
+		errL, josTree = prsr.GameTree.AddJosekiPatterns(josTree, 0, 0)
+		if len(errL) != 0 {
+			fmt.Println("Error while adding joseki:", errL.Error())
+			return
+		}
+	}
+	inf, _ := josTree.GetPatternInfo(2)
+	fmt.Println("Sequences:", inf.Count, "from", josTree.NumPatternSources(), "game:", josTree.GetPatternSource(inf.Games[0]))
+	os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+	outFile := OutDir + "/joseki.sgf"
+	errW := josTree.WriteFile(outFile, sgf.DefaultNumPerLine)
+	if errW != nil {
+		fmt.Println("Error while writing:", errW)
+		return
+	}
+	b, errR := ioutil.ReadFile(outFile)
+	if errR != nil {
+		fmt.Println("Error while reading:", errR)
+		return
+	}
+	fmt.Print(string(b))
+	// Output:
+	// Sequences: 8 from 1 game: josekiTestGame
+	// (;FF[4]GM[1]
+	// CA[UTF-8]
+	// AP[test-ahgo:0.8]
+	// ST[1]
+	// SZ[19]
+	// HA[0]
+	// (;B[dd]WB[2]WW[4]WG[josekiTestGame];W[fc]WB[2]WG[josekiTestGame];B[cf]WB[2]WG[josekiTestGame];W[]WB[2]WG[josekiTestGame];B[db]WB[2]WG[josekiTestGame])(;B[dc]WB[2]WG[josekiTestGame])
+	// )
+}
+
+// A game with play on the sides and in the center.
+const regionTestGame = "(;FF[4]GM[1]SZ[19];B[jc];W[lc];B[cj];W[ch];B[lj];W[hk])"
+
+func ExampleGameTree_AddRegionPatterns() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	prsr, errL := sgf.ParseFile("regionTestGame", []byte(regionTestGame), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	for _, regTyp := range []sgf.RegionType{sgf.SideRegion, sgf.CenterRegion} {
+		errL, pattTree := prsr.GameTree.AddRegionPatterns(nil, regTyp, "regionTestGame", 0, 0)
+		if len(errL) != 0 {
+			fmt.Println("Error while adding patterns:", errL.Error())
+			return
+		}
+		inf, _ := pattTree.GetPatternInfo(2)
+		fmt.Println(sgf.RegionTypeNames[regTyp], "sequences:", inf.Count)
+		outFile := OutDir + "/" + sgf.RegionTypeNames[regTyp] + ".sgf"
+		os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+		errW := pattTree.WriteFile(outFile, sgf.DefaultNumPerLine)
+		if errW != nil {
+			fmt.Println("Error while writing:", errW)
+			return
+		}
+		b, _ := ioutil.ReadFile(outFile)
+		fmt.Print(string(b))
+	}
+	// Output:
+	// SideRegion sequences: 2
+	// (;FF[4]GM[1]
+	// CA[UTF-8]
+	// AP[test-ahgo:0.8]
+	// ST[1]
+	// SZ[19]
+	// HA[0]
+	// ;B[jc]WO[2]WG[regionTestGame];W[hc]WO[2]WG[regionTestGame]
+	// )
+	// CenterRegion sequences: 1
+	// (;FF[4]GM[1]
+	// CA[UTF-8]
+	// AP[test-ahgo:0.8]
+	// ST[1]
+	// SZ[19]
+	// HA[0]
+	// ;B[jh]WO[1]WG[regionTestGame];W[il]WO[1]WG[regionTestGame]
+	// )
+}
+
+// Pattern trees are written with their win counts and continuations,
+// and can be read back, and added to.
+func ExampleGameTree_WriteFile_patterns() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// the name of the game is escaped in WC and WG
+	prsr, errL := sgf.ParseFile("josekiTestGame[b]", []byte(josekiTestGame), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	// limit the sequences to 2 moves, so continuations are recorded
+	errL, josTree := prsr.GameTree.AddJosekiPatterns(nil, 0, 2)
+	if len(errL) != 0 {
+		fmt.Println("Error while adding joseki:", errL.Error())
+		return
+	}
+	os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+	outFile := OutDir + "/joseki2.sgf"
+	josTree.WriteFile(outFile, sgf.DefaultNumPerLine)
+	b, _ := ioutil.ReadFile(outFile)
+	fmt.Print(string(b))
+	// read it back, and add the game again
+	prsr2, errL := sgf.ParseFile(outFile, b, 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	fmt.Println("Sources read:", prsr2.NumPatternSources(), prsr2.GetPatternSource(0))
+	errL, josTree = prsr.GameTree.AddJosekiPatterns(&prsr2.GameTree, 0, 2)
+	if len(errL) != 0 {
+		fmt.Println("Error while adding joseki:", errL.Error())
+		return
+	}
+	josTree.WriteFile(outFile, sgf.DefaultNumPerLine)
+	b, _ = ioutil.ReadFile(outFile)
+	fmt.Print(string(b))
+	// Output:
+	// (;FF[4]GM[1]
+	// CA[UTF-8]
+	// AP[test-ahgo:0.8]
+	// ST[1]
+	// SZ[19]
+	// HA[0]
+	// (;B[dd]WB[1]WW[2]WG[josekiTestGame[b\]];W[fc]WB[1]WC[josekiTestGame[b\]:4]WG[josekiTestGame[b\]])(;B[dc]WB[1]WG[josekiTestGame[b\]])
+	// )
+	// Sources read: 1 josekiTestGame[b]
+	// (;FF[4]GM[1]
+	// CA[UTF-8]
+	// AP[test-ahgo:0.8]
+	// ST[1]
+	// SZ[19]
+	// HA[0]
+	// (;B[dd]WB[2]WW[4]WG[josekiTestGame[b\]];W[fc]WB[2]WC[josekiTestGame[b\]:4]WG[josekiTestGame[b\]])(;B[dc]WB[2]WG[josekiTestGame[b\]])
+	// )
+}
+
+func ExampleGameTree_QueryPosition() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	prsr, errL := sgf.ParseFile("josekiTestGame", []byte(josekiTestGame), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	errL, josTree := prsr.GameTree.AddJosekiPatterns(nil, 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while adding joseki:", errL.Error())
+		return
+	}
+	printNext := func(next []sgf.NextMove) {
+		for _, m := range next {
+			colr := "W"
+			if m.Color == ah.Black {
+				colr = "B"
+			}
+			fmt.Printf("%s[%s] count %d win %.1f%% games %v\n", colr,
+				sgf.SGFCoords(m.Move, true), m.Count, m.WinPct, m.Games)
+		}
+	}
+	// the empty board: the first moves, in each symmetry of the corners
+	next, _ := josTree.QueryPosition(nil, nil)
+	printNext(next)
+	// a black stone on the 4-4 point in the lower right corner
+	pp, _ := sgf.SGFPoint([]byte("pp"))
+	next, _ = josTree.QueryPosition(ah.NodeLocList{pp}, nil)
+	printNext(next)
+	// the same, with the colors swapped
+	next, _ = josTree.QueryPosition(nil, ah.NodeLocList{pp})
+	printNext(next)
+	// sequences that settle sooner, set in an empty tree before the first game
+	errL, josTree = prsr.GameTree.AddJosekiPatterns(&sgf.GameTree{PatternOpts: sgf.PatternOptions{SettleMoves: 1}}, 0, 0)
+	next, _ = josTree.QueryPosition(ah.NodeLocList{pp}, nil)
+	fmt.Println("settled after 1 move:", len(next), "next moves")
+	// Output:
+	// B[dd] count 3 win 33.3% games [josekiTestGame]
+	// B[pd] count 3 win 33.3% games [josekiTestGame]
+	// B[dp] count 3 win 33.3% games [josekiTestGame]
+	// B[pp] count 3 win 33.3% games [josekiTestGame]
+	// B[dc] count 1 win 100.0% games [josekiTestGame]
+	// B[pc] count 1 win 100.0% games [josekiTestGame]
+	// B[cd] count 1 win 100.0% games [josekiTestGame]
+	// B[qd] count 1 win 100.0% games [josekiTestGame]
+	// B[cp] count 1 win 100.0% games [josekiTestGame]
+	// B[qp] count 1 win 100.0% games [josekiTestGame]
+	// B[dq] count 1 win 100.0% games [josekiTestGame]
+	// B[pq] count 1 win 100.0% games [josekiTestGame]
+	// W[qn] count 1 win 0.0% games [josekiTestGame]
+	// W[nq] count 1 win 0.0% games [josekiTestGame]
+	// B[qn] count 1 win 0.0% games [josekiTestGame]
+	// B[nq] count 1 win 0.0% games [josekiTestGame]
+	// settled after 1 move: 0 next moves
+}
+
+func ExamplePatternIndex_Search() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// Black surrounds, and captures, the White stone at ee,
+	// then a second game, where White captures the Black stone
+	const game = "(;FF[4]GM[1]SZ[19];B[ed];W[ee];B[de];W[pp];B[fe];W[pd];B[ef])" +
+		"(;FF[4]GM[1]SZ[19];W[ed];B[ee];W[de];B[pp];W[fe];B[pd];W[ef])"
+	prsr, errL := sgf.ParseFile("searchGame", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	var idx sgf.PatternIndex
+	for i := 0; i < prsr.NumGames(); i++ {
+		prsr.SelectGame(i)
+		idx.AddGame("searchGame.sgf", &prsr.GameTree)
+	}
+	// save the index, and read it back
+	os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+	errW := idx.WriteIndex(OutDir + "/search.idx")
+	if errW != nil {
+		fmt.Println(errW)
+		return
+	}
+	idx2, errR := sgf.ReadPatternIndex(OutDir + "/search.idx")
+	if errR != nil {
+		fmt.Println(errR)
+		return
+	}
+	for _, rows := range [][]string{{"XO"}, {"?X?", "X.X", "?X?"}} {
+		pat, errL := sgf.NewLocalPattern(rows)
+		if len(errL) != 0 {
+			fmt.Println(errL.Error())
+			return
+		}
+		fmt.Println("Pattern:", rows)
+		for _, swap := range []bool{false, true} {
+			fmt.Println("Swap colors:", swap)
+			for _, m := range idx2.Search(pat, swap) {
+				fmt.Println(m.File, m.Game, "move", m.Move, "at", m.Col, m.Row, ah.TransName[m.Trans], "swapped", m.Swapped)
+			}
+		}
+	}
+	_, errL = sgf.NewLocalPattern([]string{"XO", "O"})
+	fmt.Println(errL.Error())
+	// Output:
+	// Pattern: [XO]
+	// Swap colors: false
+	// searchGame.sgf 0 move 2 at 4 3 T_ROTA_270 swapped false
+	// searchGame.sgf 0 move 3 at 3 4 T_IDENTITY swapped false
+	// searchGame.sgf 0 move 5 at 4 4 T_ROTA_180 swapped false
+	// searchGame.sgf 1 move 2 at 4 3 T_ROTA_090 swapped false
+	// searchGame.sgf 1 move 3 at 3 4 T_ROTA_180 swapped false
+	// searchGame.sgf 1 move 5 at 4 4 T_IDENTITY swapped false
+	// Swap colors: true
+	// searchGame.sgf 0 move 2 at 4 3 T_ROTA_270 swapped false
+	// searchGame.sgf 0 move 3 at 3 4 T_IDENTITY swapped false
+	// searchGame.sgf 0 move 5 at 4 4 T_ROTA_180 swapped false
+	// searchGame.sgf 1 move 2 at 4 3 T_ROTA_090 swapped false
+	// searchGame.sgf 1 move 3 at 3 4 T_ROTA_180 swapped false
+	// searchGame.sgf 1 move 5 at 4 4 T_IDENTITY swapped false
+	// Pattern: [?X? X.X ?X?]
+	// Swap colors: false
+	// searchGame.sgf 0 move 7 at 3 3 T_IDENTITY swapped false
+	// Swap colors: true
+	// searchGame.sgf 0 move 7 at 3 3 T_IDENTITY swapped false
+	// searchGame.sgf 1 move 7 at 3 3 T_IDENTITY swapped true
+	// NewLocalPattern: row 1 has 1 points, not 2
+}
@@ -0,0 +1,314 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/players.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/14/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file resolves the names of players to canonical names,
+ *	using a table of aliases read from a file, such as sgf_player_aliases.txt
+ */
+
+package sgf
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlayerAliases maps the names of players to canonical names.
+// The file read by ReadPlayerAliases has lines of the form:
+//	# a comment
+//	canonical name | alias | alias ...
+//	!strip regexp
+//	!suspect name
+// Aliases include other spellings and transliterations, such as: Go Seigen | Wu Qingyuan
+// The !strip regular expressions are removed from names before they are looked up,
+// and are used for ranks or titles in names, such as: !strip \s+\d+[dp]$
+// The !suspect names are reported by the parser, when keeping data base statistics.
+// Names are compared ignoring case, and extra spaces.
+// A PlayerAliases is not changed after it is read, and may be shared by many goroutines.
+type PlayerAliases struct {
+	canon   map[string]string // key (see aliasKey) to canonical name
+	strip   []*regexp.Regexp
+	suspect map[string]bool
+}
+
+// NewPlayerAliases returns an empty table, which resolves each name to itself,
+// with extra spaces removed.
+func NewPlayerAliases() *PlayerAliases {
+	pa := new(PlayerAliases)
+	pa.canon = make(map[string]string)
+	pa.suspect = make(map[string]bool)
+	return pa
+}
+
+// cleanName removes leading, trailing, and repeated spaces from a name.
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// aliasKey returns the key used to look up a name.
+func aliasKey(name string) string {
+	return strings.ToLower(cleanName(name))
+}
+
+// ReadPlayerAliases reads a table of aliases from a file.
+func ReadPlayerAliases(fileName string) (pa *PlayerAliases, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.New("ReadPlayerAliases: " + fileName + " " + err.Error())
+	}
+	defer f.Close()
+	pa, err = readPlayerAliases(f)
+	if err != nil {
+		return nil, errors.New("ReadPlayerAliases: " + fileName + ":" + err.Error())
+	}
+	return pa, nil
+}
+
+// readPlayerAliases reads a table of aliases from r.
+// An alias that is given for two different canonical names is an error.
+func readPlayerAliases(r io.Reader) (pa *PlayerAliases, err error) {
+	pa = NewPlayerAliases()
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo += 1
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		at := strconv.Itoa(lineNo) + ": "
+		switch {
+		case strings.HasPrefix(line, "!strip "):
+			re, err := regexp.Compile(strings.TrimSpace(line[len("!strip "):]))
+			if err != nil {
+				return nil, errors.New(at + err.Error())
+			}
+			pa.strip = append(pa.strip, re)
+		case strings.HasPrefix(line, "!suspect "):
+			pa.suspect[aliasKey(line[len("!suspect "):])] = true
+		case line[0] == '!':
+			return nil, errors.New(at + "unknown directive " + strings.Fields(line)[0])
+		default:
+			names := strings.Split(line, "|")
+			canon := cleanName(names[0])
+			if canon == "" {
+				return nil, errors.New(at + "missing canonical name")
+			}
+			for _, n := range names {
+				k := aliasKey(n)
+				if k == "" {
+					continue
+				}
+				if c, found := pa.canon[k]; found && c != canon {
+					return nil, errors.New(at + "\"" + cleanName(n) + "\" is already an alias of \"" + c + "\"")
+				}
+				pa.canon[k] = canon
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return pa, nil
+}
+
+// Add adds a canonical name, and its aliases, to the table.
+func (pa *PlayerAliases) Add(canon string, aliases ...string) {
+	canon = cleanName(canon)
+	pa.canon[aliasKey(canon)] = canon
+	for _, a := range aliases {
+		pa.canon[aliasKey(a)] = canon
+	}
+}
+
+// stripName applies the !strip rules to a name.
+func (pa *PlayerAliases) stripName(name string) string {
+	name = cleanName(name)
+	for _, re := range pa.strip {
+		name = cleanName(re.ReplaceAllString(name, ""))
+	}
+	return name
+}
+
+// Resolve returns the canonical name of a player.
+// A name that is not in the table is returned after applying the !strip rules.
+// A nil PlayerAliases returns the name unchanged.
+func (pa *PlayerAliases) Resolve(name string) string {
+	if pa == nil {
+		return name
+	}
+	if c, found := pa.canon[aliasKey(name)]; found {
+		return c
+	}
+	name = pa.stripName(name)
+	if c, found := pa.canon[aliasKey(name)]; found {
+		return c
+	}
+	return name
+}
+
+// Known returns true if the name, or the name after the !strip rules,
+// is a canonical name or an alias.
+func (pa *PlayerAliases) Known(name string) bool {
+	if pa == nil {
+		return false
+	}
+	_, found := pa.canon[aliasKey(name)]
+	if !found {
+		_, found = pa.canon[aliasKey(pa.stripName(name))]
+	}
+	return found
+}
+
+// defaultAliasesText is sgf_player_aliases.txt, whose !suspect names are used without a table.
+//
+//go:embed sgf_player_aliases.txt
+var defaultAliasesText string
+
+// defaultSuspect is the !suspect names of defaultAliasesText, by key (see aliasKey).
+var defaultSuspect = func() map[string]bool {
+	pa, err := readPlayerAliases(strings.NewReader(defaultAliasesText))
+	if err != nil {
+		panic("sgf_player_aliases.txt:" + err.Error())
+	}
+	return pa.suspect
+}()
+
+// IsSuspect returns true if the name is one of the !suspect names.
+// With no table (pa is nil), the !suspect names of sgf_player_aliases.txt are used.
+func (pa *PlayerAliases) IsSuspect(name string) bool {
+	if pa == nil {
+		return defaultSuspect[aliasKey(name)]
+	}
+	return pa.suspect[aliasKey(name)]
+}
+
+// An AliasSuggestion is a player name that is not in the table of aliases,
+// but may be another name of a known player, or of a player with more games.
+type AliasSuggestion struct {
+	Name    string
+	Games   int
+	Suggest string // the likely canonical name
+	Reason  string
+}
+
+// Type bySuggestGames implements the sort.Interface based on the number of games,
+// with ties broken by the name.
+type bySuggestGames []AliasSuggestion
+
+func (bs bySuggestGames) Len() int      { return len(bs) }
+func (bs bySuggestGames) Swap(i, j int) { bs[i], bs[j] = bs[j], bs[i] }
+func (bs bySuggestGames) Less(i, j int) bool {
+	if bs[i].Games != bs[j].Games {
+		return bs[i].Games > bs[j].Games
+	}
+	return bs[i].Name < bs[j].Name
+}
+
+// aliasReason returns why the names a and b (after normName) may be the same player,
+// or "" if they are not likely to be.
+func aliasReason(a string, b string) string {
+	if a == b {
+		return "same words"
+	}
+	if len(a) >= 6 && len(b) >= 6 && editDistance(a, b) <= 2 {
+		return "similar spelling"
+	}
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) > len(wb) {
+		wa, wb = wb, wa
+	}
+	if len(wa) == 0 || len(wa) == len(wb) || len(strings.Join(wa, "")) < 4 {
+		return ""
+	}
+	for _, w := range wa {
+		found := false
+		for _, v := range wb {
+			if w == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ""
+		}
+	}
+	return "part of name"
+}
+
+// UnresolvedAliases looks for names, in the counts of games by player,
+// that are not in the table, but are likely to be another name of a known
+// player, or of a player with more games. The names are compared ignoring
+// case, punctuation, and the order of their words (see normName).
+// Only names that share a word are compared.
+// returns the suggestions, in order of decreasing number of games.
+func (pa *PlayerAliases) UnresolvedAliases(games map[string]int) (sugg []AliasSuggestion) {
+	type cand struct {
+		name  string
+		norm  string
+		games int
+		known bool
+	}
+	var cands []cand
+	for name, n := range games {
+		cands = append(cands, cand{name, normName(name), n, pa.Known(name)})
+	}
+	if pa != nil {
+		seen := make(map[string]bool)
+		for _, c := range pa.canon {
+			if !seen[c] {
+				seen[c] = true
+				if _, inGames := games[c]; !inGames {
+					cands = append(cands, cand{c, normName(c), 0, true})
+				}
+			}
+		}
+	}
+	// index the candidates by their words
+	byWord := make(map[string][]int)
+	for i, c := range cands {
+		for _, w := range strings.Fields(c.norm) {
+			byWord[w] = append(byWord[w], i)
+		}
+	}
+	for i, c := range cands {
+		if c.known || c.games == 0 {
+			continue
+		}
+		// prefer a known name, then the name with the most games
+		best, bestReason := -1, ""
+		for _, w := range strings.Fields(c.norm) {
+			for _, j := range byWord[w] {
+				o := cands[j]
+				if j == i || (!o.known && (o.games < c.games || (o.games == c.games && o.name > c.name))) {
+					continue
+				}
+				r := aliasReason(c.norm, o.norm)
+				if r == "" {
+					continue
+				}
+				if best < 0 || (o.known && !cands[best].known) ||
+					(o.known == cands[best].known && (o.games > cands[best].games ||
+						(o.games == cands[best].games && o.name < cands[best].name))) {
+					best, bestReason = j, r
+				}
+			}
+		}
+		if best >= 0 {
+			sugg = append(sugg, AliasSuggestion{Name: c.name, Games: c.games,
+				Suggest: pa.Resolve(cands[best].name), Reason: bestReason})
+		}
+	}
+	sort.Sort(bySuggestGames(sugg))
+	return sugg
+}
@@ -23,31 +23,36 @@ import (
 // TODO: move this into a Parser or GameTree attribute, out of the sgfdb DBProcessRequest struct
 const DefaultNumPerLine = 12
 
-var indent int = 0
+// An sgfWriter is a buffered writer, with the state of one WriteFile call,
+// so many goroutines may write files at once.
+type sgfWriter struct {
+	*bufio.Writer
+	indent int // indentation used for tracing output
+}
 
-func u(s string) {
+func (w *sgfWriter) u(s string) {
 	if ah.TraceAH {
-		indent -= 1
-		for i := indent; i > 0; i-- {
+		w.indent -= 1
+		for i := w.indent; i > 0; i-- {
 			fmt.Print(". ")
 		}
 		fmt.Println("Leaving", s)
 	}
 }
 
-func tr(s string) string {
+func (w *sgfWriter) tr(s string) string {
 	if ah.TraceAH {
-		for i := indent; i > 0; i-- {
+		for i := w.indent; i > 0; i-- {
 			fmt.Print(". ")
 		}
 		fmt.Println("Entering", s)
-		indent += 1
+		w.indent += 1
 	}
 	return s
 }
 
-func (pv *PropertyValue) writeProperty(w *bufio.Writer, FF4 bool) (err error) {
-	defer u(tr("writeProperty"))
+func (pv *PropertyValue) writeProperty(w *sgfWriter, FF4 bool) (err error) {
+	defer w.u(w.tr("writeProperty"))
 	pt := pv.PropType
 	prop := GetProperty(pt)
 	if prop == nil { // either error or UnknownProperty
@@ -79,11 +84,13 @@ func (pv *PropertyValue) writeProperty(w *bufio.Writer, FF4 bool) (err error) {
 			err = w.WriteByte('[')
 			str := pv.StrValue
 			if err == nil {
-				if (pt == AB_idx) || (pt == AE_idx) || (pt == AW_idx) || (pt == S_idx) || (pt == TB_idx) || (pt == TR_idx) || (pt == TW_idx) { // split into pairs
-					for len(str) > 2 {
-						_, err = w.Write(str[0:2])
+				if (pt == AB_idx) || (pt == AE_idx) || (pt == AW_idx) || (pt == S_idx) || (pt == TB_idx) || (pt == TR_idx) || (pt == TW_idx) ||
+					(pt == CR_idx) || (pt == SQ_idx) || (pt == MA_idx) || (pt == SL_idx) || (pt == DD_idx) || (pt == VW_idx) ||
+					(pt == AR_idx) || (pt == LN_idx) { // split into pairs, or composed points "aa:bb"
+					for n := pointLen(pt, str); n < len(str); n = pointLen(pt, str) {
+						_, err = w.Write(str[0:n])
 						_, err = w.WriteString("][")
-						str = str[2:]
+						str = str[n:]
 					}
 				}
 				if ((len(str) == 2) && (str[0] == 't') && (str[1] == 't')) &&
@@ -101,8 +108,17 @@ func (pv *PropertyValue) writeProperty(w *bufio.Writer, FF4 bool) (err error) {
 	return err
 }
 
-func (p *GameTree) writeProperties(w *bufio.Writer, n TreeNodeIdx, onePer bool) (err error) {
-	defer u(tr("writeProperties"))
+// pointLen returns the length of the first value in a list of points:
+// 5 for a composed value "aa:bb", an arrow, line, or compressed rectangle, and 2 for a point.
+func pointLen(pt PropertyDefIdx, str []byte) int {
+	if pt == AR_idx || pt == LN_idx || (len(str) >= 5 && str[2] == ':') {
+		return 5
+	}
+	return 2
+}
+
+func (p *GameTree) writeProperties(w *sgfWriter, n TreeNodeIdx, onePer bool) (err error) {
+	defer w.u(w.tr("writeProperties"))
 	lastProp := p.treeNodes[n].propListOrNodeLoc
 	if lastProp != nilPropIdx {
 		prop := p.propertyValues[lastProp].NextProp
@@ -122,7 +138,7 @@ func (p *GameTree) writeProperties(w *bufio.Writer, n TreeNodeIdx, onePer bool)
 	return err
 }
 
-func (p *GameTree) writeLabel(w *bufio.Writer, n ah.NodeLoc, LabelIdx int) (err error) {
+func (p *GameTree) writeLabel(w *sgfWriter, n ah.NodeLoc, LabelIdx int) (err error) {
 	Labels := [26]byte{'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z'}
 	err = w.WriteByte('[')
 	if err == nil {
@@ -140,6 +156,42 @@ func (p *GameTree) writeLabel(w *bufio.Writer, n ah.NodeLoc, LabelIdx int) (err
 	return err
 }
 
+// escapeSimpleText returns s, with ']' and '\' escaped, to be written as an SGF SimpleText value.
+func escapeSimpleText(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "]", "\\]").Replace(s)
+}
+
+// writePatternInfo writes the win counts (WB, WW, WO), continuations (WC),
+// and source games (WG) recorded for a node of a pattern tree.
+func (p *GameTree) writePatternInfo(w *sgfWriter, n TreeNodeIdx) (err error) {
+	inf, ok := p.patInfo[n]
+	if !ok {
+		return nil
+	}
+	if inf.WinsB > 0 {
+		_, err = w.WriteString("WB[" + strconv.Itoa(inf.WinsB) + "]")
+	}
+	if (err == nil) && (inf.WinsW > 0) {
+		_, err = w.WriteString("WW[" + strconv.Itoa(inf.WinsW) + "]")
+	}
+	if (err == nil) && (inf.WinsO > 0) {
+		_, err = w.WriteString("WO[" + strconv.Itoa(inf.WinsO) + "]")
+	}
+	for _, cont := range inf.Conts {
+		if err != nil {
+			break
+		}
+		_, err = w.WriteString("WC[" + escapeSimpleText(p.GetPatternSource(cont.Src)) + ":" + strconv.Itoa(cont.Move) + "]")
+	}
+	for _, src := range inf.Games {
+		if err != nil {
+			break
+		}
+		_, err = w.WriteString("WG[" + escapeSimpleText(p.GetPatternSource(src)) + "]")
+	}
+	return err
+}
+
 //	writeTree writes a .sgf tree from the treeNodes array
 //		w is a buffered I/O writer
 //		n is the TreeNodeIdx of the root of this tree
@@ -147,8 +199,8 @@ func (p *GameTree) writeLabel(w *bufio.Writer, n ah.NodeLoc, LabelIdx int) (err
 //		nMov keeps a count of moves per line.
 //	writeTree first writes one node, then recursively calls writeTree
 //	writeTree is only called from writeGame, which has one active call, and one that is never reached (&& false)
-func (p *GameTree) writeTree(w *bufio.Writer, n TreeNodeIdx, needs bool, nMov int, nMovPerLine int) (err error) {
-	defer u(tr("writeTree"))
+func (p *GameTree) writeTree(w *sgfWriter, n TreeNodeIdx, needs bool, nMov int, nMovPerLine int) (err error) {
+	defer w.u(w.tr("writeTree"))
 	if needs == true {
 		if nMov > 0 {
 			err = w.WriteByte('\n')
@@ -186,6 +238,9 @@ func (p *GameTree) writeTree(w *bufio.Writer, n TreeNodeIdx, needs bool, nMov in
 			err = errors.New("writeTree: unsupported TreeNodeType" + strconv.FormatInt(int64(typ), 10))
 			return err
 		}
+		if (err == nil) && (typ != GameInfoNode) {
+			err = p.writePatternInfo(w, n)
+		}
 		if err == nil {
 			// write the children
 			lastCh := p.treeNodes[n].Children
@@ -214,8 +269,8 @@ func (p *GameTree) writeTree(w *bufio.Writer, n TreeNodeIdx, needs bool, nMov in
 //	writeGame writes the initial "(", then calls writeTree.
 //	there is logic, which is forced to fail (&& false) for writing siblings of n (doesn't writeTree do this)
 //	if writeTree does not return an error, writeGame writes the terminating ")" with newlines before and after.
-func (p *GameTree) writeGame(w *bufio.Writer, n TreeNodeIdx, nMovPerLine int) (err error) {
-	defer u(tr("writeGame"))
+func (p *GameTree) writeGame(w *sgfWriter, n TreeNodeIdx, nMovPerLine int) (err error) {
+	defer w.u(w.tr("writeGame"))
 	err = w.WriteByte('(')
 	if err == nil {
 		// TODO: allow more than one game in a file
@@ -246,8 +301,8 @@ func (p *GameTree) writeGame(w *bufio.Writer, n TreeNodeIdx, nMovPerLine int) (e
 //	TODO: does not appear to have logic to stop after first err is returned.
 //	TODO: need a short sgf test for multiple games in a collection?
 //	TODO: this level of logic seems to satisfy TODO statements above, and forced to fail logic.
-func (p *GameTree) writeCollection(w *bufio.Writer, coll TreeNodeIdx, nMovPerLine int) (err error) {
-	defer u(tr("writeCollection"))
+func (p *GameTree) writeCollection(w *sgfWriter, coll TreeNodeIdx, nMovPerLine int) (err error) {
+	defer w.u(w.tr("writeCollection"))
 	typ := p.treeNodes[coll].TNodType
 	if typ == CollectionNode {
 		lastCh := p.treeNodes[coll].Children
@@ -275,8 +330,8 @@ func (p *GameTree) writeCollection(w *bufio.Writer, coll TreeNodeIdx, nMovPerLin
 // TODO: could crash if 0 is out of range? OR does init function in parser.go prevent this?
 // TODO: could crash if RootNode (0) has no Children
 // TODO: does not check if RootNode has more than one child.
-func (p *GameTree) writeParseTree(w *bufio.Writer, nMovPerLine int) (err error) {
-	defer u(tr("writeParseTree"))
+func (p *GameTree) writeParseTree(w *sgfWriter, nMovPerLine int) (err error) {
+	defer w.u(w.tr("writeParseTree"))
 	typ := p.treeNodes[0].TNodType
 	if typ == RootNode {
 		coll := p.treeNodes[0].Children
@@ -299,15 +354,16 @@ const filePERM uint32 = 0644 // owner RW, group R, others R
 //	WriteFile sets up a deferred Close on the
 
 func (tree *GameTree) WriteFile(fileName string, nMovPerLine int) (err error) {
-	defer u(tr("WriteFile"))
+	w := new(sgfWriter)
+	defer w.u(w.tr("WriteFile"))
 	// old parms to Open(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePERM)
 	f, err := os.Create(fileName)
 	if err != nil {
 		return errors.New("OpenFile:" + fileName + " " + err.Error())
 	}
 	defer f.Close() // TODO: should this be conditional on not being closed?
-	w := bufio.NewWriter(f)
-	if w == nil {
+	w.Writer = bufio.NewWriter(f)
+	if w.Writer == nil {
 		return errors.New("nil from NewWriter:" + fileName + " " + err.Error())
 	}
 	err = tree.writeParseTree(w, nMovPerLine)
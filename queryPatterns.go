@@ -0,0 +1,336 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/queryPatterns.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 2/18/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file looks up positions and move sequences in pattern trees,
+ *	and reports the moves played next, with their statistics.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+	"sort"
+)
+
+// A NextMove is a move played after a position in a pattern tree.
+//	Move is in the orientation of the caller's position or sequence.
+//	WinPct is the percentage of the games won by the player of Color.
+type NextMove struct {
+	Move   ah.NodeLoc
+	Color  ah.PointStatus
+	Count  int
+	WinsB  int
+	WinsW  int
+	WinsO  int
+	WinPct float64
+	Games  []string // sample games, at most the MaxSampleGames of the PatternOptions
+}
+
+// Type byNextCount implements the sort.Interface based on
+// the count, with ties broken by the move.
+type byNextCount []NextMove
+
+func (bc byNextCount) Len() int      { return len(bc) }
+func (bc byNextCount) Swap(i, j int) { bc[i], bc[j] = bc[j], bc[i] }
+func (bc byNextCount) Less(i, j int) bool {
+	if bc[i].Count != bc[j].Count {
+		return bc[i].Count > bc[j].Count
+	}
+	return bc[i].Move < bc[j].Move
+}
+
+// A patternMatch is a pattern node at which a position, or sequence, is reached,
+// under the symmetry trans, with the colors swapped if swapColr.
+type patternMatch struct {
+	node     TreeNodeIdx
+	trans    ah.BoardTrans
+	swapColr bool
+}
+
+// nextMoves collects the children of the pattern nodes in matches,
+// translating each move with the inverse of the matching trans,
+// and swapping back the colors, and win counts, of a match with swapColr.
+// Moves reached from more than one node (transpositions) are combined.
+// A child is counted once for each move it gives, when several symmetries match at a node.
+func (pattTree *GameTree) nextMoves(matches []patternMatch,
+	untrans func(t ah.BoardTrans, nl ah.NodeLoc) ah.NodeLoc) (next []NextMove) {
+	type nextKey struct {
+		loc  ah.NodeLoc
+		colr ah.PointStatus
+	}
+	type childKey struct {
+		ch  TreeNodeIdx
+		loc ah.NodeLoc
+	}
+	maxSamples := pattTree.PatternOpts.maxSampleGames()
+	found := make(map[nextKey]int)
+	counted := make(map[childKey]bool)
+	for _, m := range matches {
+		n := m.node
+		for ch := pattTree.firstChild(n); ch != nilTreeNodeIdx; {
+			mov, ok := pattTree.nodeMove(ch)
+			if ok {
+				nl := mov.loc
+				if nl != ah.PassNodeLoc {
+					nl = untrans(m.trans, nl)
+				}
+				colr := mov.colr
+				inf, _ := pattTree.patInfo[ch]
+				if m.swapColr {
+					colr = ah.OppositeColor(colr)
+					inf.WinsB, inf.WinsW = inf.WinsW, inf.WinsB
+				}
+				if !counted[childKey{ch, nl}] {
+					counted[childKey{ch, nl}] = true
+					j, seen := found[nextKey{nl, colr}]
+					if !seen {
+						j = len(next)
+						found[nextKey{nl, colr}] = j
+						next = append(next, NextMove{Move: nl, Color: colr})
+					}
+					next[j].Count += inf.Count
+					next[j].WinsB += inf.WinsB
+					next[j].WinsW += inf.WinsW
+					next[j].WinsO += inf.WinsO
+					for _, g := range inf.Games {
+						if len(next[j].Games) < maxSamples {
+							next[j].Games = append(next[j].Games, pattTree.GetPatternSource(g))
+						}
+					}
+					// patterns read from a file only have their continuations
+					for _, c := range inf.Conts {
+						if len(next[j].Games) < maxSamples {
+							next[j].Games = append(next[j].Games, pattTree.GetPatternSource(c.Src))
+						}
+					}
+				}
+			}
+			if ch == pattTree.treeNodes[n].Children { // last child
+				break
+			}
+			ch = pattTree.treeNodes[ch].NextSib
+		}
+	}
+	for j := range next {
+		won := next[j].WinsB
+		if next[j].Color == ah.White {
+			won = next[j].WinsW
+		}
+		if next[j].Count > 0 {
+			next[j].WinPct = 100.0 * float64(won) / float64(next[j].Count)
+		}
+	}
+	sort.Sort(byNextCount(next))
+	return next
+}
+
+// QuerySequence looks up a sequence of moves in a whole board pattern tree,
+// such as one built by AddTeachingPattern.
+//	movs are the moves played, starting with the first move after any handicap stones.
+//	The first move is put in a canonical location with canonicalRep, using the
+//	handicap symmetry of pattTree, and the same BoardTrans is applied to the other moves.
+//
+// returns the moves played next, in the orientation of movs, in order of decreasing count.
+// next is empty if the sequence is not in pattTree.
+func (pattTree *GameTree) QuerySequence(movs []ah.NodeLoc) (next []NextMove, err ah.ErrorList) {
+	var trans ah.BoardTrans = ah.T_IDENTITY
+	if len(pattTree.treeNodes) <= 2 {
+		err.Add(ah.NoPos, "QuerySequence: pattern tree has no GameInfoNode")
+		return next, err
+	}
+	curPatt := TreeNodeIdx(2) // GameInfoNode is child of CollectionNode
+	for i, nl := range movs {
+		newNL := nl
+		if i == 0 {
+			if nl != ah.PassNodeLoc {
+				newNL, trans = pattTree.canonicalRep(nl, pattTree.GetHandicap())
+			}
+		} else if nl != ah.PassNodeLoc {
+			newNL = pattTree.transNodeLoc(trans, nl)
+		}
+		curPatt = pattTree.FindChild(curPatt, newNL)
+		if curPatt == nilTreeNodeIdx {
+			return next, err
+		}
+	}
+	untrans := func(t ah.BoardTrans, nl ah.NodeLoc) ah.NodeLoc {
+		return pattTree.transNodeLoc(ah.InverseTrans[t], nl)
+	}
+	next = pattTree.nextMoves([]patternMatch{{node: curPatt, trans: trans}}, untrans)
+	return next, err
+}
+
+// canonicalRep returns the canonical location of the first move of a sequence,
+// and the BoardTrans that maps nl to it, among the symmetries of the board
+// that keep the handicap stones of ha in place.
+// Square boards use FindCanonicalRep. Rectangular boards have only four symmetries,
+// and the one that puts nl on the least point (first by row, then by column) is chosen.
+func (gamT *GameTree) canonicalRep(nl ah.NodeLoc, ha int) (ah.NodeLoc, ah.BoardTrans) {
+	szCol, szRow := gamT.GetSize()
+	nCol, nRow := int(szCol), int(szRow)
+	if nCol == nRow {
+		return gamT.FindCanonicalRep(nl, ah.BoardHandicapSymmetry[ha])
+	}
+	hcap := HandicapPoints(ha, nCol, nRow)
+	isHcap := make(map[ah.NodeLoc]bool, len(hcap))
+	for _, h := range hcap {
+		isHcap[h] = true
+	}
+	best, bestT := nl, ah.T_IDENTITY
+	c, r := ah.GetColRow(nl)
+	bx, by := int(c), int(r)
+	for _, t := range boardSymmetries(nCol, nRow) {
+		keeps := true
+		for _, h := range hcap {
+			if !isHcap[gamT.transNodeLoc(t, h)] {
+				keeps = false
+				break
+			}
+		}
+		if !keeps {
+			continue
+		}
+		x, y := transLocal(t, int(c), int(r), nCol, nRow)
+		if y < by || (y == by && x < bx) {
+			best, bestT = ah.MakeNodeLoc(ah.ColValue(x), ah.RowValue(y)), t
+			bx, by = x, y
+		}
+	}
+	return best, bestT
+}
+
+// transNodeLoc applies the symmetry t to nl, on the board of gamT.
+// Square boards use TransNodeLoc, and rectangular boards transLocal.
+// A pass is not changed.
+func (gamT *GameTree) transNodeLoc(t ah.BoardTrans, nl ah.NodeLoc) ah.NodeLoc {
+	if nl == ah.PassNodeLoc {
+		return nl
+	}
+	c, r := ah.GetColRow(nl)
+	szCol, szRow := gamT.GetSize()
+	if int(szCol) == int(szRow) {
+		return gamT.TransNodeLoc(t, c, r)
+	}
+	x, y := transLocal(t, int(c), int(r), int(szCol), int(szRow))
+	return ah.MakeNodeLoc(ah.ColValue(x), ah.RowValue(y))
+}
+
+// patternHandicap returns the handicap stones (the AB property) of a pattern tree.
+func (pattTree *GameTree) patternHandicap() (pts ah.NodeLocList) {
+	pIdx := pattTree.findProp(2, AB_idx)
+	if pIdx == nilPropIdx {
+		return pts
+	}
+	str := pattTree.propertyValues[pIdx].StrValue
+	for len(str) >= 2 {
+		nl, err := SGFPoint(str[0:2])
+		if len(err) == 0 {
+			pts = append(pts, nl)
+		}
+		str = str[2:]
+	}
+	return pts
+}
+
+// QueryPosition looks up a position in a pattern tree.
+//	black and white are the stones on the board, not including handicap stones.
+//	The position must be reached by the moves of a line in pattTree (in any order,
+//	with no captures), under a symmetry of the board that preserves the handicap stones.
+//	For region (joseki, etc.) trees, the position should only include the stones of the region.
+//	Without handicap stones, the position is also looked up with its colors swapped,
+//	since region trees are normalized so Black plays first.
+//
+// returns the moves played next, in the orientation of the position, in order of decreasing count.
+// The moves, and win counts, found with the colors swapped are swapped back.
+// Moves that follow more than one line to the position (transpositions) are combined.
+func (pattTree *GameTree) QueryPosition(black ah.NodeLocList, white ah.NodeLocList) (next []NextMove, err ah.ErrorList) {
+	if len(pattTree.treeNodes) <= 2 {
+		err.Add(ah.NoPos, "QueryPosition: pattern tree has no GameInfoNode")
+		return next, err
+	}
+	szCol, szRow := pattTree.GetSize()
+	trans := pattTree.transNodeLoc
+	untrans := func(t ah.BoardTrans, nl ah.NodeLoc) ah.NodeLoc {
+		return pattTree.transNodeLoc(ah.InverseTrans[t], nl)
+	}
+
+	// find the symmetries of the board that preserve the handicap stones
+	hcap := pattTree.patternHandicap()
+	isHcap := make(map[ah.NodeLoc]bool, len(hcap))
+	for _, nl := range hcap {
+		isHcap[nl] = true
+	}
+	var syms []ah.BoardTrans
+	for _, t := range boardSymmetries(int(szCol), int(szRow)) {
+		ok := true
+		for _, nl := range hcap {
+			if !isHcap[trans(t, nl)] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			syms = append(syms, t)
+		}
+	}
+
+	// the position, in the caller's orientation
+	pos := make(map[ah.NodeLoc]ah.PointStatus, len(black)+len(white))
+	for _, nl := range black {
+		pos[nl] = ah.Black
+	}
+	for _, nl := range white {
+		pos[nl] = ah.White
+	}
+	nStones := len(pos)
+
+	// depth first search for the nodes at which the position is reached,
+	// with each symmetry that reaches it
+	var matches []patternMatch
+	var search func(n TreeNodeIdx, depth int, cands []ah.BoardTrans, swap bool)
+	search = func(n TreeNodeIdx, depth int, cands []ah.BoardTrans, swap bool) {
+		if depth == nStones {
+			for _, t := range cands {
+				matches = append(matches, patternMatch{node: n, trans: t, swapColr: swap})
+			}
+			return
+		}
+		for ch := pattTree.firstChild(n); ch != nilTreeNodeIdx; {
+			mov, ok := pattTree.nodeMove(ch)
+			if ok {
+				if mov.loc == ah.PassNodeLoc {
+					search(ch, depth, cands, swap)
+				} else {
+					want := mov.colr
+					if swap {
+						want = ah.OppositeColor(want)
+					}
+					var keep []ah.BoardTrans
+					for _, t := range cands {
+						if colr, in := pos[untrans(t, mov.loc)]; in && colr == want {
+							keep = append(keep, t)
+						}
+					}
+					if len(keep) > 0 {
+						search(ch, depth+1, keep, swap)
+					}
+				}
+			}
+			if ch == pattTree.treeNodes[n].Children { // last child
+				break
+			}
+			ch = pattTree.treeNodes[ch].NextSib
+		}
+	}
+	search(2, 0, syms, false)
+	if len(hcap) == 0 && nStones > 0 {
+		search(2, 0, syms, true)
+	}
+
+	next = pattTree.nextMoves(matches, untrans)
+	return next, err
+}
@@ -0,0 +1,227 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/rank.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/15/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the ranks of players, as found in BR and WR properties:
+ *	parsing, validation, ordering, and formatting.
+ */
+
+package sgf
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// RankKind is the kind of a Rank.
+type RankKind uint8
+
+const (
+	RankUnknown RankKind = iota // no rank, or "?"
+	RankKyu                     // amateur kyu: 30k to 1k
+	RankDan                     // amateur dan: 1d to 9d
+	RankPro                     // professional dan: 1p to 9p
+)
+
+var RankKindNames = []string{"unknown", "kyu", "dan", "pro"}
+
+// Qualifiers of a Rank, written after the grade.
+const (
+	RankUncertain   = '?' // the rank is not certain, as in 5k?
+	RankEstablished = '*' // the rank is established, as in 3d*
+)
+
+// A Rank is the parsed rank of a player.
+//	Grade is 1 to 30 for kyu, and 1 to 9 for dan and pro.
+//	Qual is 0, RankUncertain, or RankEstablished.
+//	Suffix is text after the rank, such as the name of a server: "KGS", or "(IGS)".
+type Rank struct {
+	Kind   RankKind
+	Grade  int8
+	Qual   byte
+	Suffix string
+}
+
+// rankUnits are the words for each kind of rank, compared ignoring case.
+var rankUnits = []struct {
+	word string
+	kind RankKind
+}{
+	{"kyu", RankKyu}, {"dan", RankDan}, {"pro", RankPro},
+	{"k", RankKyu}, {"d", RankDan}, {"p", RankPro},
+}
+
+// ParseRank parses a rank, in one of the forms:
+//	5k, 5 kyu, 5-kyu	amateur kyu
+//	3d, 3 dan, 3-dan	amateur dan
+//	9p, 9 pro			professional dan
+//	?					unknown rank
+// The units are not case sensitive. The grade may be followed by a qualifier,
+// ? (uncertain) or * (established), and then by a suffix, separated by a space,
+// or in parentheses, as in "5k? (KGS)".
+// The error gives the position, in s, where the rank could not be parsed.
+func ParseRank(s string) (r Rank, err error) {
+	fail := func(i int, msg string) (Rank, error) {
+		return Rank{}, errors.New("rank \"" + s + "\": " + msg + ", at " + strconv.Itoa(i))
+	}
+	i := 0
+	for i < len(s) && s[i] == ' ' {
+		i += 1
+	}
+	if i == len(s) {
+		return fail(i, "empty")
+	}
+	if s[i] == '?' && strings.TrimSpace(s[i+1:]) == "" {
+		r.Qual = RankUncertain
+		return r, nil
+	}
+	// the grade
+	j := i
+	n := 0
+	for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+		n = 10*n + int(s[j]-'0')
+		if n > 99 {
+			return fail(i, "grade too large")
+		}
+		j += 1
+	}
+	if j == i {
+		return fail(i, "missing grade")
+	}
+	gradeAt := i
+	i = j
+	if i < len(s) && (s[i] == ' ' || s[i] == '-') {
+		i += 1
+	}
+	// the unit
+	lower := strings.ToLower(s[i:])
+	for _, u := range rankUnits {
+		if strings.HasPrefix(lower, u.word) {
+			r.Kind = u.kind
+			i += len(u.word)
+			break
+		}
+	}
+	if r.Kind == RankUnknown {
+		if i == len(s) {
+			return fail(i, "missing k, d, or p")
+		}
+		return fail(i, "unexpected \""+s[i:i+1]+"\"")
+	}
+	switch {
+	case n == 0:
+		return fail(gradeAt, "grade must be at least 1")
+	case r.Kind == RankKyu && n > 30:
+		return fail(gradeAt, "kyu grade greater than 30")
+	case r.Kind != RankKyu && n > 9:
+		return fail(gradeAt, RankKindNames[r.Kind]+" grade greater than 9")
+	}
+	r.Grade = int8(n)
+	// the qualifier
+	if i < len(s) && (s[i] == RankUncertain || s[i] == RankEstablished) {
+		r.Qual = s[i]
+		i += 1
+	}
+	// the suffix
+	if i < len(s) {
+		if s[i] != ' ' && s[i] != '(' && s[i] != '@' {
+			return fail(i, "unexpected \""+s[i:i+1]+"\"")
+		}
+		r.Suffix = strings.TrimSpace(s[i:])
+	}
+	return r, nil
+}
+
+// String formats a rank in the recommended SGF form, such as "5k", "3d?", or "9p".
+// An unknown rank is "?", or "" if it has no qualifier.
+func (r Rank) String() string {
+	if r.Kind == RankUnknown {
+		if r.Qual != 0 {
+			return string(r.Qual)
+		}
+		return ""
+	}
+	s := strconv.Itoa(int(r.Grade)) + RankKindNames[r.Kind][0:1]
+	if r.Qual != 0 {
+		s += string(r.Qual)
+	}
+	if r.Suffix != "" {
+		s += " " + r.Suffix
+	}
+	return s
+}
+
+// MarshalText formats a rank with String, so ranks are written as text in JSON.
+func (r Rank) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Known returns true if the rank has a kind and a grade.
+func (r Rank) Known() bool {
+	return r.Kind != RankUnknown
+}
+
+// Level places the kinds of ranks on one scale, in order of strength:
+// 30k is -29, 1k is 0, 1d is 1, 9d is 9, 1p is 10, 9p is 18.
+// An unknown rank has level 0.
+func (r Rank) Level() int {
+	switch r.Kind {
+	case RankKyu:
+		return 1 - int(r.Grade)
+	case RankDan:
+		return int(r.Grade)
+	case RankPro:
+		return 9 + int(r.Grade)
+	}
+	return 0
+}
+
+// Compare returns -1 if r is weaker than o, 0 if they are equal, and 1 if r is stronger.
+// Unknown ranks are weaker than all known ranks. The qualifier and suffix are ignored.
+func (r Rank) Compare(o Rank) int {
+	switch {
+	case r.Known() != o.Known():
+		if r.Known() {
+			return 1
+		}
+		return -1
+	case r.Level() < o.Level():
+		return -1
+	case r.Level() > o.Level():
+		return 1
+	}
+	return 0
+}
+
+// Diff returns the number of grades between r and o, positive if r is stronger.
+// For amateur ranks, this is the number of handicap stones between them.
+// Ranks are counted on the scale of Level, so 1p is one grade above 9d.
+// Diff returns false if either rank is unknown.
+func (r Rank) Diff(o Rank) (int, bool) {
+	if !r.Known() || !o.Known() {
+		return 0, false
+	}
+	return r.Level() - o.Level(), true
+}
+
+// GetBlackRank returns the parsed BR of the game.
+// An error is returned if BR is set, but cannot be parsed.
+func (gam *Game) GetBlackRank() (Rank, error) {
+	if gam.bR == nil {
+		return Rank{}, nil
+	}
+	return ParseRank(string(gam.bR))
+}
+
+// GetWhiteRank returns the parsed WR of the game.
+// An error is returned if WR is set, but cannot be parsed.
+func (gam *Game) GetWhiteRank() (Rank, error) {
+	if gam.wR == nil {
+		return Rank{}, nil
+	}
+	return ParseRank(string(gam.wR))
+}
@@ -0,0 +1,137 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/replay.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 2/24/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements a simple board, used to replay the moves of games
+ *	when searching, scoring, and checking them.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+)
+
+// A replayBoard is a simple Go board, used to replay the moves of a game.
+// Points are indexed by r*nCol + c.
+// play removes captured stones, but does not check that a move is legal.
+//	It is used instead of the AbstHier of the GameTree, which holds the position
+//	where the parser stopped (not the end of the main line, if there are variations),
+//	is played only with ParserPlay, and would be changed by DoBoardMove.
+//	DoBoardMove also does not return the stones captured by a move, which are needed
+//	for scoring, checking ko, and indexing.
+type replayBoard struct {
+	nCol, nRow int
+	pts        []ah.PointStatus
+	// marks used while finding strings and liberties
+	mark    []int
+	markGen int
+}
+
+// newReplayBoard returns an empty board of nCol by nRow points.
+func newReplayBoard(nCol int, nRow int) (b *replayBoard) {
+	b = new(replayBoard)
+	b.nCol = nCol
+	b.nRow = nRow
+	b.pts = make([]ah.PointStatus, nCol*nRow)
+	for i := range b.pts {
+		b.pts[i] = ah.Unocc
+	}
+	b.mark = make([]int, nCol*nRow)
+	return b
+}
+
+// index returns the index of the point at nl, or -1 if nl is not on the board.
+func (b *replayBoard) index(nl ah.NodeLoc) int {
+	if nl == ah.PassNodeLoc || nl == ah.NilNodeLoc || nl == ah.IllegalNodeLoc {
+		return -1
+	}
+	c, r := ah.GetColRow(nl)
+	if int(c) >= b.nCol || int(r) >= b.nRow {
+		return -1
+	}
+	return int(r)*b.nCol + int(c)
+}
+
+// nodeLoc returns the NodeLoc of the point at index i.
+func (b *replayBoard) nodeLoc(i int) ah.NodeLoc {
+	return ah.MakeNodeLoc(ah.ColValue(i%b.nCol), ah.RowValue(i/b.nCol))
+}
+
+// eachAdj calls f for each point adjacent to point i.
+func (b *replayBoard) eachAdj(i int, f func(j int)) {
+	c := i % b.nCol
+	r := i / b.nCol
+	if c > 0 {
+		f(i - 1)
+	}
+	if c < b.nCol-1 {
+		f(i + 1)
+	}
+	if r > 0 {
+		f(i - b.nCol)
+	}
+	if r < b.nRow-1 {
+		f(i + b.nCol)
+	}
+}
+
+// newMarks starts a new search, invalidating all marks.
+func (b *replayBoard) newMarks() {
+	b.markGen += 1
+}
+
+// group returns the points of the string at point i, and its number of liberties.
+func (b *replayBoard) group(i int) (stones []int, libs int) {
+	colr := b.pts[i]
+	b.newMarks()
+	b.mark[i] = b.markGen
+	stones = append(stones, i)
+	for k := 0; k < len(stones); k++ {
+		b.eachAdj(stones[k], func(j int) {
+			if b.mark[j] == b.markGen {
+				return
+			}
+			if b.pts[j] == colr {
+				b.mark[j] = b.markGen
+				stones = append(stones, j)
+			} else if b.pts[j] == ah.Unocc {
+				b.mark[j] = b.markGen
+				libs += 1
+			}
+		})
+	}
+	return stones, libs
+}
+
+// play places a stone of colr at point i, and removes captured strings.
+// Opponent strings without liberties are removed first. Then, if the string
+// of the new stone has no liberties, it is removed (suicide).
+// returns the points of the captured stones, and true for a suicide.
+func (b *replayBoard) play(i int, colr ah.PointStatus) (capt []int, suicide bool) {
+	opp := ah.OppositeColor(colr)
+	b.pts[i] = colr
+	b.eachAdj(i, func(j int) {
+		if b.pts[j] == opp {
+			stones, libs := b.group(j)
+			if libs == 0 {
+				for _, s := range stones {
+					b.pts[s] = ah.Unocc
+				}
+				capt = append(capt, stones...)
+			}
+		}
+	})
+	stones, libs := b.group(i)
+	if libs == 0 {
+		for _, s := range stones {
+			b.pts[s] = ah.Unocc
+		}
+		capt = append(capt, stones...)
+		suicide = true
+	}
+	return capt, suicide
+}
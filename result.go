@@ -0,0 +1,210 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/result.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/16/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the result of a game, as found in RE properties:
+ *	parsing FF[4] and common non-standard values, suggesting fixes, and formatting.
+ */
+
+package sgf
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Winner is the outcome of a game.
+type Winner uint8
+
+const (
+	WinUnknown Winner = iota // no result, or "?"
+	WinBlack
+	WinWhite
+	WinDraw // "0", or "Draw"
+	WinVoid // "Void", no result or suspended play
+)
+
+var WinnerNames = []string{"unknown", "Black", "White", "draw", "void"}
+
+// ResultReason is how a game was won.
+type ResultReason uint8
+
+const (
+	ReasonNone    ResultReason = iota // not known, as in "B+", or not a win
+	ReasonScore                       // by points, as in "W+2.5"
+	ReasonResign                      // "B+R", or "B+Resign"
+	ReasonTime                        // "B+T", or "B+Time"
+	ReasonForfeit                     // "B+F", or "B+Forfeit"
+)
+
+var ResultReasonNames = []string{"", "score", "resign", "time", "forfeit"}
+
+// A GameResult is the parsed result of a game.
+// Margin is the number of points, when Reason is ReasonScore.
+type GameResult struct {
+	Winner Winner
+	Reason ResultReason
+	Margin float32
+}
+
+// resultWords are the non-standard names of results, compared ignoring case.
+var resultWords = map[string]GameResult{
+	"0":               {Winner: WinDraw},
+	"draw":            {Winner: WinDraw},
+	"jigo":            {Winner: WinDraw},
+	"void":            {Winner: WinVoid},
+	"uf":              {Winner: WinVoid},
+	"unfinished":      {Winner: WinVoid},
+	"left unfinished": {Winner: WinVoid},
+	"?":               {Winner: WinUnknown},
+}
+
+// resultReasons are the words for the reasons, compared ignoring case.
+var resultReasons = map[string]ResultReason{
+	"r": ReasonResign, "resign": ReasonResign, "res": ReasonResign, "resignation": ReasonResign,
+	"t": ReasonTime, "time": ReasonTime,
+	"f": ReasonForfeit, "forfeit": ReasonForfeit,
+}
+
+// String formats a result in the FF[4] form, such as "B+R", "W+2.5", "0", or "Void".
+// The margin is written with as few digits as needed.
+func (r GameResult) String() string {
+	switch r.Winner {
+	case WinDraw:
+		return "0"
+	case WinVoid:
+		return "Void"
+	case WinUnknown:
+		return "?"
+	}
+	s := "B+"
+	if r.Winner == WinWhite {
+		s = "W+"
+	}
+	switch r.Reason {
+	case ReasonScore:
+		s += strconv.FormatFloat(float64(r.Margin), 'f', -1, 32)
+	case ReasonResign:
+		s += "R"
+	case ReasonTime:
+		s += "T"
+	case ReasonForfeit:
+		s += "F"
+	}
+	return s
+}
+
+// longString formats a result in the long FF[4] form, such as "B+Resign", or "Draw".
+func (r GameResult) longString() string {
+	switch {
+	case r.Winner == WinDraw:
+		return "Draw"
+	case r.Reason == ReasonResign || r.Reason == ReasonTime || r.Reason == ReasonForfeit:
+		rsn := ResultReasonNames[r.Reason]
+		return r.String()[0:2] + strings.ToUpper(rsn[0:1]) + rsn[1:]
+	}
+	return r.String()
+}
+
+// HalfPoints returns true if the margin is a whole number of half points,
+// as expected for a score with komi. (A few games with Ing rules, and seki,
+// have other margins.)
+func (r GameResult) HalfPoints() bool {
+	return r.Reason != ReasonScore || math.Mod(float64(r.Margin)*2, 1) == 0
+}
+
+// ParseResult parses the value of an RE property.
+// It accepts the FF[4] values, and common non-standard values, such as:
+//	w+r, B+Resign	(case, and long reasons)
+//	B+2,5, B+.5		(decimal comma, missing zero)
+//	W4.5, B++		(missing or extra +)
+//	W+40 zi			(units)
+//	Jigo, UF		(draws, and void games)
+// A comment, as separated by SplitRE, is ignored.
+// fix is "" if s is an FF[4] value, and the FF[4] value otherwise.
+// err is not nil if s cannot be understood.
+func ParseResult(s string) (r GameResult, fix string, err error) {
+	fail := func(msg string) (GameResult, string, error) {
+		return GameResult{}, "", errors.New("result \"" + s + "\": " + msg)
+	}
+	val, _ := SplitRE([]byte(s))
+	t := strings.TrimSpace(string(val))
+	if t == "" {
+		return fail("empty")
+	}
+	if w, found := resultWords[strings.ToLower(t)]; found {
+		r = w
+	} else {
+		switch t[0] {
+		case 'B', 'b':
+			r.Winner = WinBlack
+		case 'W', 'w':
+			r.Winner = WinWhite
+		default:
+			return fail("must start with B or W")
+		}
+		rest := t[1:]
+		// allow "Black+", "White+"
+		if lw := strings.ToLower(t); strings.HasPrefix(lw, "black") || strings.HasPrefix(lw, "white") {
+			rest = t[5:]
+		}
+		rest = strings.TrimLeft(strings.TrimSpace(rest), "+")
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			// B+: a win, the reason is not known
+		} else if rsn, found := resultReasons[strings.ToLower(rest)]; found {
+			r.Reason = rsn
+		} else if strings.EqualFold(rest, "jigo") {
+			return fail("a win by jigo")
+		} else {
+			num := strings.Replace(rest, ",", ".", 1)
+			// a score in stones, as in "W+40 zi"
+			for _, unit := range []string{"zi", "pts", "points", "point"} {
+				if strings.HasSuffix(strings.ToLower(num), unit) {
+					num = strings.TrimSpace(num[0 : len(num)-len(unit)])
+					break
+				}
+			}
+			m, errN := strconv.ParseFloat(num, 32)
+			if errN != nil || m < 0 {
+				return fail("bad margin \"" + rest + "\"")
+			}
+			r.Reason = ReasonScore
+			r.Margin = float32(m)
+		}
+	}
+	if t != r.String() && t != r.longString() {
+		fix = r.String()
+	}
+	return r, fix, nil
+}
+
+// checkResult returns a message about an RE value that is malformed,
+// or non-standard, or "". The margin is checked by CheckProperties,
+// when the rules are known: RU may follow RE.
+func checkResult(s string) string {
+	_, fix, err := ParseResult(s)
+	switch {
+	case err != nil:
+		return "check value: " + err.Error()
+	case fix != "":
+		return "check value: " + s + " (must be " + fix + ")"
+	}
+	return ""
+}
+
+// GetResult returns the parsed RE of the game.
+// An error is returned if RE is set, but cannot be parsed.
+// The result of a game without RE is WinUnknown.
+func (gam *Game) GetResult() (GameResult, error) {
+	if gam.rE.val == nil {
+		return GameResult{}, nil
+	}
+	r, _, err := ParseResult(string(gam.rE.val))
+	return r, err
+}
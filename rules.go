@@ -0,0 +1,460 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/rules.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/21/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the rules of Go, as named by RU properties,
+ *	and the checking of the legality of moves while parsing with ParserPlay:
+ *	occupied points, suicide, simple ko, and positional or situational superko.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+	"strconv"
+	"strings"
+)
+
+// RuleSet is a set of rules of Go.
+type RuleSet uint8
+
+const (
+	RulesFromRU      RuleSet = iota // the rules named by the RU property of the game
+	RulesJapanese                   // also Korean rules
+	RulesChinese                    //
+	RulesAGA                        // American Go Association, also French and British rules
+	RulesNewZealand                 //
+	RulesIng                        // Ing, or Goe, rules
+	RulesTrompTaylor                //
+)
+
+var RuleSetNames = []string{"RU", "Japanese", "Chinese", "AGA", "NZ", "Ing", "Tromp-Taylor"}
+
+// KoRule is the rule that forbids repeating a position.
+type KoRule uint8
+
+const (
+	KoSimple      KoRule = iota // only the immediate recapture of a ko
+	KoPositional                // no position may be repeated
+	KoSituational               // no position may be repeated with the same player to move
+)
+
+var KoRuleNames = []string{"simple ko", "positional superko", "situational superko"}
+
+// handicapComp is the compensation given to White, in a handicap game scored by area.
+type handicapComp uint8
+
+const (
+	compNone       handicapComp = iota // no compensation
+	compStones                         // one point for each handicap stone
+	compStonesLess                     // one point for each handicap stone after the first
+)
+
+// ruleSets are the details of each RuleSet.
+//	free is true if the handicap stones may be placed anywhere,
+//	and false if they must be on the fixed points of HandicapPoints.
+var ruleSets = []struct {
+	suicide bool
+	ko      KoRule
+	scoring ScoringMethod
+	free    bool
+	comp    handicapComp
+}{
+	RulesFromRU:      {false, KoSimple, ScoreTerritory, false, compNone},
+	RulesJapanese:    {false, KoSimple, ScoreTerritory, false, compNone},
+	RulesChinese:     {false, KoPositional, ScoreArea, true, compStones},
+	RulesAGA:         {false, KoSituational, ScoreArea, false, compStonesLess},
+	RulesNewZealand:  {true, KoSituational, ScoreArea, true, compNone},
+	RulesIng:         {true, KoSituational, ScoreArea, true, compStones},
+	RulesTrompTaylor: {true, KoPositional, ScoreArea, true, compNone},
+}
+
+// AllowsSuicide returns true if the rules allow a move that removes the player's own stones.
+func (rs RuleSet) AllowsSuicide() bool {
+	return ruleSets[rs].suicide
+}
+
+// KoRule returns the ko rule of the rules.
+func (rs RuleSet) KoRule() KoRule {
+	return ruleSets[rs].ko
+}
+
+// ScoringMethod returns the scoring method of the rules.
+func (rs RuleSet) ScoringMethod() ScoringMethod {
+	return ruleSets[rs].scoring
+}
+
+// FreeHandicap returns true if the rules allow handicap stones to be placed anywhere.
+func (rs RuleSet) FreeHandicap() bool {
+	return ruleSets[rs].free
+}
+
+// HandicapCompensation returns the points given to White for a handicap of n stones.
+// Chinese rules give one point for each stone, and AGA rules one point for each stone after the first.
+func (rs RuleSet) HandicapCompensation(n int) int {
+	if n < 2 {
+		return 0
+	}
+	switch ruleSets[rs].comp {
+	case compStones:
+		return n
+	case compStonesLess:
+		return n - 1
+	}
+	return 0
+}
+
+// ruleWords are the beginnings of RU values, compared ignoring case.
+var ruleWords = []struct {
+	word string
+	rs   RuleSet
+}{
+	{"japan", RulesJapanese}, {"jp", RulesJapanese}, {"korea", RulesJapanese},
+	{"chinese", RulesChinese}, {"cn", RulesChinese},
+	{"aga", RulesAGA}, {"french", RulesAGA}, {"bgs", RulesAGA}, {"british", RulesAGA},
+	{"nz", RulesNewZealand}, {"new zealand", RulesNewZealand},
+	{"ing", RulesIng}, {"goe", RulesIng},
+	{"tromp", RulesTrompTaylor},
+}
+
+// RuleSetFor returns the rules named by an RU value, and true if they are known.
+// Rules that are not known, or missing, are RulesJapanese.
+func RuleSetFor(ru string) (RuleSet, bool) {
+	lower := strings.ToLower(strings.TrimSpace(ru))
+	for _, w := range ruleWords {
+		if strings.HasPrefix(lower, w.word) {
+			return w.rs, true
+		}
+	}
+	return RulesJapanese, false
+}
+
+// MoveViolation is the kind of an illegal move.
+type MoveViolation uint8
+
+const (
+	ViolationOccupied MoveViolation = iota // the point already has a stone
+	ViolationSuicide                       // the move removes the player's own stones
+	ViolationKo                            // the move retakes a ko at once
+	ViolationSuperko                       // the move repeats an earlier position
+)
+
+var MoveViolationNames = []string{"occupied point", "suicide", "ko", "superko"}
+
+// A MoveDiagnostic is an illegal move, found while parsing with ParserPlay.
+//	Move is the number of the move, from 1, in the line of play of the node.
+//	Node is the node of the move in the GameTree.
+type MoveDiagnostic struct {
+	Kind  MoveViolation
+	Rules RuleSet
+	Move  int
+	Node  TreeNodeIdx
+	Loc   ah.NodeLoc
+	Color ah.PointStatus
+	Pos   ah.Position
+}
+
+// String formats a diagnostic, as in "illegal move 12 B[dd] at node 14: ko (Japanese rules)".
+func (d MoveDiagnostic) String() string {
+	mov := "B["
+	if d.Color == ah.White {
+		mov = "W["
+	}
+	mov += string(SGFCoords(d.Loc, true)) + "]"
+	return "illegal move " + strconv.Itoa(d.Move) + " " + mov + " at node " + strconv.Itoa(int(d.Node)) +
+		": " + MoveViolationNames[d.Kind] + " (" + RuleSetNames[d.Rules] + " rules)"
+}
+
+// A ruleChange is the change of one point, while checking moves.
+type ruleChange struct {
+	pt   int
+	prev ah.PointStatus
+}
+
+// A ruleStep is a move, or setup, at a node, as recorded by a moveChecker.
+//	start is the index of its first change, ko and prevToMov are the ko point,
+//	and the player to move, before it, and hash is the exact hash of the position
+//	after it, with toMov to play next.
+//	played is false for passes, moves to occupied points, and PL, which do not make a new position.
+type ruleStep struct {
+	node      TreeNodeIdx
+	movN      int
+	start     int
+	ko        int
+	prevToMov ah.PointStatus
+	hash      uint64
+	toMov     ah.PointStatus
+	setup     bool
+	played    bool
+}
+
+// A moveChecker replays the moves of a game, as they are parsed,
+// and checks them with a set of rules.
+// The steps of a variation are undone when the parser backs up to the start of it.
+// Each move is played by doMove, on the board of the game and on the checker, which
+// also knows the rules, and the earlier positions, for ko and superko.
+type moveChecker struct {
+	rules   RuleSet
+	brd     *replayBoard
+	ko      int            // the point that may not be played, because of a simple ko, or -1
+	toMov   ah.PointStatus // the player to move
+	zh      *positionHash
+	changes []ruleChange
+	steps   []ruleStep
+}
+
+// newMoveChecker returns a checker for an empty board.
+func newMoveChecker(rules RuleSet, nCol int, nRow int) *moveChecker {
+	return &moveChecker{rules: rules, brd: newReplayBoard(nCol, nRow), ko: -1, toMov: ah.Black,
+		zh: newPositionHash(nCol, nRow)}
+}
+
+// put changes point i to st, and updates the hash.
+func (c *moveChecker) put(i int, st ah.PointStatus) {
+	if prev := c.brd.pts[i]; prev != ah.Unocc {
+		c.zh.toggle(i, prev)
+	}
+	if st != ah.Unocc {
+		c.zh.toggle(i, st)
+	}
+	c.brd.pts[i] = st
+}
+
+// set changes point i to st, and records the change.
+func (c *moveChecker) set(i int, st ah.PointStatus) {
+	if c.brd.pts[i] != st {
+		c.changes = append(c.changes, ruleChange{i, c.brd.pts[i]})
+		c.put(i, st)
+	}
+}
+
+// begin starts a step at node, and returns its index.
+func (c *moveChecker) begin(node TreeNodeIdx) int {
+	movN := 0
+	if len(c.steps) > 0 {
+		movN = c.steps[len(c.steps)-1].movN
+	}
+	c.steps = append(c.steps, ruleStep{node: node, movN: movN, start: len(c.changes), ko: c.ko, prevToMov: c.toMov})
+	return len(c.steps) - 1
+}
+
+// canonical returns the hash of the position, with the player to move, the same in each symmetry.
+func (c *moveChecker) canonical() uint64 {
+	return c.zh.canonical(c.toMov)
+}
+
+// setup places a stone of colr, or removes a stone if colr is ah.Unocc, at node.
+func (c *moveChecker) setup(node TreeNodeIdx, nl ah.NodeLoc, colr ah.PointStatus) {
+	i := c.brd.index(nl)
+	if i < 0 {
+		return
+	}
+	s := c.begin(node)
+	c.set(i, colr)
+	c.ko = -1
+	c.steps[s].hash = c.zh.exact()
+	c.steps[s].toMov = c.toMov
+	c.steps[s].setup = true
+	c.steps[s].played = true
+}
+
+// player sets the player to move at node, as given by PL.
+func (c *moveChecker) player(node TreeNodeIdx, colr ah.PointStatus) {
+	s := c.begin(node)
+	c.toMov = colr
+	c.steps[s].hash = c.zh.exact()
+	c.steps[s].toMov = colr
+}
+
+// play checks and plays a move of colr at node, and returns the violations of the rules.
+// An occupied point is not played. Other illegal moves are played, so the
+// rest of the game can be checked.
+func (c *moveChecker) play(node TreeNodeIdx, nl ah.NodeLoc, colr ah.PointStatus) (viols []MoveViolation) {
+	s := c.begin(node)
+	c.steps[s].movN += 1
+	c.toMov = ah.OppositeColor(colr)
+	c.steps[s].toMov = c.toMov
+	i := c.brd.index(nl)
+	if i < 0 { // a pass
+		c.ko = -1
+		c.steps[s].hash = c.zh.exact()
+		return nil
+	}
+	if c.brd.pts[i] != ah.Unocc {
+		c.steps[s].hash = c.zh.exact()
+		return []MoveViolation{ViolationOccupied}
+	}
+	if i == c.ko {
+		viols = append(viols, ViolationKo)
+	}
+	c.set(i, colr)
+	opp := ah.OppositeColor(colr)
+	var capt []int
+	c.brd.eachAdj(i, func(j int) {
+		if c.brd.pts[j] == opp {
+			stones, libs := c.brd.group(j)
+			if libs == 0 {
+				for _, st := range stones {
+					c.set(st, ah.Unocc)
+				}
+				capt = append(capt, stones...)
+			}
+		}
+	})
+	stones, libs := c.brd.group(i)
+	if libs == 0 {
+		if !c.rules.AllowsSuicide() {
+			viols = append(viols, ViolationSuicide)
+		}
+		for _, st := range stones {
+			c.set(st, ah.Unocc)
+		}
+	}
+	// a new ko: one stone captured by one stone, which has one liberty
+	c.ko = -1
+	if len(capt) == 1 && len(stones) == 1 && libs == 1 {
+		c.ko = capt[0]
+	}
+	c.steps[s].hash = c.zh.exact()
+	c.steps[s].played = true
+	if c.rules.KoRule() != KoSimple && len(viols) == 0 && c.repeats(s) {
+		viols = append(viols, ViolationSuperko)
+	}
+	return viols
+}
+
+// repeats returns true if the position after step s is the same as after an earlier step,
+// and, for situational superko, has the same player to move.
+// The player to move after a setup is not known, so it matches either player.
+func (c *moveChecker) repeats(s int) bool {
+	h := c.steps[s].hash
+	situational := c.rules.KoRule() == KoSituational
+	for k := s - 1; k >= 0; k-- {
+		st := &c.steps[k]
+		if st.played && st.hash == h && (!situational || st.setup || st.toMov == c.steps[s].toMov) {
+			return true
+		}
+	}
+	return false
+}
+
+// mark returns the number of steps taken, to return to with undoTo.
+func (c *moveChecker) mark() int {
+	return len(c.steps)
+}
+
+// undoTo undoes the steps taken after mark, the last first.
+func (c *moveChecker) undoTo(mark int) {
+	for len(c.steps) > mark {
+		st := c.steps[len(c.steps)-1]
+		for k := len(c.changes) - 1; k >= st.start; k-- {
+			c.put(c.changes[k].pt, c.changes[k].prev)
+		}
+		c.changes = c.changes[0:st.start]
+		c.ko = st.ko
+		c.toMov = st.prevToMov
+		c.steps = c.steps[0 : len(c.steps)-1]
+	}
+}
+
+// SetRules sets the rules used to check moves while parsing with ParserPlay.
+// RulesFromRU, the default, uses the rules of the RU property of each game.
+func (p *Parser) SetRules(rs RuleSet) {
+	p.rules = rs
+}
+
+// MoveDiagnostics returns the illegal moves found while parsing with ParserPlay.
+func (p *Parser) MoveDiagnostics() []MoveDiagnostic {
+	return p.moveDiags
+}
+
+// gameRules returns the rules of the parser, or of the RU of the game being parsed.
+func (p *Parser) gameRules() RuleSet {
+	if p.rules == RulesFromRU {
+		rs, _ := RuleSetFor(string(p.rU))
+		return rs
+	}
+	return p.rules
+}
+
+// gameChecker returns the checker of the game being parsed, and creates it, if needed,
+// with the size of the board, and the rules of the parser, or of RU.
+func (p *Parser) gameChecker() *moveChecker {
+	if p.checker == nil {
+		nCol, nRow := p.boardSize()
+		p.checker = newMoveChecker(p.gameRules(), nCol, nRow)
+	}
+	return p.checker
+}
+
+// checkRules updates the rules of the checker, when RU is parsed after
+// the first setup stone, or move, of the game, as in (;AB[dd]RU[Chinese]...).
+func (p *Parser) checkRules() {
+	if p.checker != nil {
+		p.checker.rules = p.gameRules()
+	}
+}
+
+// checkSetup records a setup stone, or an empty point if colr is ah.Unocc, at node.
+func (p *Parser) checkSetup(node TreeNodeIdx, nl ah.NodeLoc, colr ah.PointStatus) {
+	if p.play {
+		p.gameChecker().setup(node, nl, colr)
+		p.setNodeHash(node)
+	}
+}
+
+// checkPlayer records the player to move at node, as given by PL.
+func (p *Parser) checkPlayer(node TreeNodeIdx, colr ah.PointStatus) {
+	if p.play {
+		p.gameChecker().player(node, colr)
+		p.setNodeHash(node)
+	}
+}
+
+// doMove plays a move of colr at node, on the board of the game, with DoB or DoW,
+// and returns their errors. With ParserPlay, the move is also checked with the rules,
+// and the violations are recorded as MoveDiagnostics.
+func (p *Parser) doMove(node TreeNodeIdx, nl ah.NodeLoc, colr ah.PointStatus) (movN int, err ah.ErrorList) {
+	if colr == ah.Black {
+		movN, err = p.DoB(nl, p.play)
+	} else {
+		movN, err = p.DoW(nl, p.play)
+	}
+	if !p.play {
+		return movN, err
+	}
+	c := p.gameChecker()
+	for _, v := range c.play(node, nl, colr) {
+		d := MoveDiagnostic{Kind: v, Rules: c.rules, Move: c.steps[len(c.steps)-1].movN,
+			Node: node, Loc: nl, Color: colr, Pos: p.pos}
+		p.moveDiags = append(p.moveDiags, d)
+	}
+	p.setNodeHash(node)
+	return movN, err
+}
+
+// checkMark returns the steps of the checker, before the parser starts a variation.
+func (p *Parser) checkMark() int {
+	if p.checker == nil {
+		return 0
+	}
+	return p.checker.mark()
+}
+
+// backUp restores the position of node, after the parser has parsed a variation
+// from node to leaf: the moves of the board, and, with ParserPlay, the steps
+// of the checker after mark, and so the position hash.
+func (p *Parser) backUp(node TreeNodeIdx, leaf TreeNodeIdx, mark int) {
+	for currentNode := leaf; currentNode != node; currentNode = p.treeNodes[currentNode].Parent {
+		// for loop allows for more than one move at a node, i.e. S[]
+		for p.GetMovDepth() > p.treeNodes[currentNode].movDepth {
+			p.UndoBoardMove(p.play)
+		}
+	}
+	if p.checker != nil {
+		p.checker.undoTo(mark)
+	}
+}
@@ -0,0 +1,377 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/scoring.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/19/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the scoring of the final positions of games:
+ *	replaying the main line, removing the dead stones marked by TB and TW,
+ *	or given by the caller, and counting area and territory, with komi.
+ *	It also checks the scores against RE, for a game, or a data base.
+ */
+
+package sgf
+
+import (
+	"github.com/Ken1JF/ah"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ScoringMethod is how the points of a final position are counted.
+type ScoringMethod uint8
+
+const (
+	ScoreTerritory ScoringMethod = iota // territory and prisoners: Japanese, Korean
+	ScoreArea                           // territory and stones: Chinese, AGA, New Zealand, Ing
+)
+
+var ScoringMethodNames = []string{"territory", "area"}
+
+// ScoringMethodFor returns the scoring method of the rules named by an RU value.
+// Rules that are not known, or missing, are scored by territory.
+func ScoringMethodFor(ru string) ScoringMethod {
+	rs, _ := RuleSetFor(ru)
+	return rs.ScoringMethod()
+}
+
+// A Score is the count of a final position.
+//	Stones are the stones left on the board, after removing the dead stones.
+//	Territory is the empty points, and the points of dead stones, surrounded by one color.
+//	Captures are the stones captured during the game, and Dead the dead stones removed
+//	at the end, by their color: BlackCaptures are black stones captured by White.
+//	Compensation is the points given to White for the handicap stones, by area scoring,
+//	under the rules of RU (see RuleSet.HandicapCompensation).
+//	Marked is true if the territory was taken from TB and TW.
+type Score struct {
+	Method         ScoringMethod
+	Komi           float32
+	Compensation   int
+	BlackStones    int
+	WhiteStones    int
+	BlackTerritory int
+	WhiteTerritory int
+	BlackCaptures  int
+	WhiteCaptures  int
+	BlackDead      int
+	WhiteDead      int
+	Marked         bool
+}
+
+// Area returns the area scores of Black and White: stones and territory,
+// with komi, and the handicap compensation, for White.
+func (sc Score) Area() (b float32, w float32) {
+	b = float32(sc.BlackStones + sc.BlackTerritory)
+	w = float32(sc.WhiteStones+sc.WhiteTerritory+sc.Compensation) + sc.Komi
+	return b, w
+}
+
+// Territory returns the territory scores of Black and White: territory and prisoners,
+// with komi for White. The prisoners of Black are the white stones captured, and dead.
+func (sc Score) Territory() (b float32, w float32) {
+	b = float32(sc.BlackTerritory + sc.WhiteCaptures + sc.WhiteDead)
+	w = float32(sc.WhiteTerritory+sc.BlackCaptures+sc.BlackDead) + sc.Komi
+	return b, w
+}
+
+// Margin returns Black's score minus White's score, counted by sc.Method.
+func (sc Score) Margin() float32 {
+	var b, w float32
+	if sc.Method == ScoreArea {
+		b, w = sc.Area()
+	} else {
+		b, w = sc.Territory()
+	}
+	return b - w
+}
+
+// Result returns the result implied by the score.
+func (sc Score) Result() GameResult {
+	m := sc.Margin()
+	switch {
+	case m > 0:
+		return GameResult{Winner: WinBlack, Reason: ReasonScore, Margin: m}
+	case m < 0:
+		return GameResult{Winner: WinWhite, Reason: ReasonScore, Margin: -m}
+	}
+	return GameResult{Winner: WinDraw}
+}
+
+// String formats a score, as in "area B 184, W 177+6.5: B+0.5".
+func (sc Score) String() string {
+	var b, w float32
+	if sc.Method == ScoreArea {
+		b, w = sc.Area()
+	} else {
+		b, w = sc.Territory()
+	}
+	ftoa := func(f float32) string {
+		return strconv.FormatFloat(float64(f), 'f', -1, 32)
+	}
+	return ScoringMethodNames[sc.Method] + " B " + ftoa(b) + ", W " + ftoa(w-sc.Komi) +
+		"+" + ftoa(sc.Komi) + ": " + sc.Result().String()
+}
+
+// Matches returns true if a result agrees with the score.
+// Only results by score are compared: other results, such as B+R, always match.
+func (sc Score) Matches(r GameResult) bool {
+	switch r.Winner {
+	case WinDraw:
+		return sc.Margin() == 0
+	case WinBlack, WinWhite:
+		if r.Reason != ReasonScore {
+			return true
+		}
+		s := sc.Result()
+		return s.Winner == r.Winner && s.Margin == r.Margin
+	}
+	return true
+}
+
+// lastMainNode returns the last node of the main line of the selected game.
+func (gamT *GameTree) lastMainNode() TreeNodeIdx {
+	n := gamT.selectedGameNode()
+	if n == nilTreeNodeIdx {
+		return nilTreeNodeIdx
+	}
+	return gamT.mainLineEnd(n)
+}
+
+// propPoints returns the points of all the values of the properties of type typ at node n.
+func (gamT *GameTree) propPoints(n TreeNodeIdx, typ PropertyDefIdx) (pts []ah.NodeLoc) {
+	switch gamT.treeNodes[n].TNodType {
+	case BlackMoveNode, WhiteMoveNode, SequenceNode:
+		return nil
+	}
+	tail := gamT.treeNodes[n].propListOrNodeLoc
+	if tail == nilPropIdx {
+		return nil
+	}
+	pIdx := tail
+	for {
+		pIdx = gamT.propertyValues[pIdx].NextProp
+		if gamT.propertyValues[pIdx].PropType == typ {
+			more, _ := parsePoints(gamT.propertyValues[pIdx].StrValue)
+			pts = append(pts, more...)
+		}
+		if pIdx == tail {
+			break
+		}
+	}
+	return pts
+}
+
+// HasTerritory returns true if the last node of the main line has TB or TW.
+func (gamT *GameTree) HasTerritory() bool {
+	n := gamT.lastMainNode()
+	return n != nilTreeNodeIdx && (gamT.findProp(n, TB_idx) != nilPropIdx || gamT.findProp(n, TW_idx) != nilPropIdx)
+}
+
+// ScoreGame replays the main line of the game, and counts the final position,
+// by the rules of its RU property (see ScoringMethodFor), with komi from KM.
+//	If dead is nil, and the last node of the main line has TB or TW, the dead
+//	stones are the stones on the marked points, and the territory is the marked points.
+//	Otherwise, the strings at the points of dead are removed, and the territory
+//	is the empty regions that touch stones of only one color.
+// Errors are reported for moves that are not on the board, other than passes.
+func (gamT *GameTree) ScoreGame(dead []ah.NodeLoc) (sc Score, errs ah.ErrorList) {
+	return gamT.ScoreGameWith(ScoringMethodFor(string(gamT.rU)), dead)
+}
+
+// ScoreGameWith is ScoreGame, with the scoring method given by the caller.
+// The main line is replayed on a replayBoard, leaving the AbstHier of the game unchanged.
+func (gamT *GameTree) ScoreGameWith(method ScoringMethod, dead []ah.NodeLoc) (sc Score, errs ah.ErrorList) {
+	sc.Method = method
+	if gamT.kM.set && gamT.kM.known {
+		sc.Komi = gamT.kM.val
+	}
+	rs, _ := RuleSetFor(string(gamT.rU))
+	sc.Compensation = rs.HandicapCompensation(gamT.GetHandicap())
+	nCol, nRow := gamT.boardSize()
+	brd := newReplayBoard(nCol, nRow)
+	for _, nl := range gamT.aB {
+		if i := brd.index(nl); i >= 0 {
+			brd.pts[i] = ah.Black
+		}
+	}
+	for _, nl := range gamT.aW {
+		if i := brd.index(nl); i >= 0 {
+			brd.pts[i] = ah.White
+		}
+	}
+	for k, mov := range gamT.mainLineMoves() {
+		i := brd.index(mov.loc)
+		if i < 0 {
+			if mov.loc != ah.PassNodeLoc && mov.loc != ah.NilNodeLoc && !isOldPass(mov.loc, brd) {
+				errs.Add(ah.NoPos, "ScoreGame: move "+strconv.Itoa(k+1)+" not on the board")
+			}
+			continue
+		}
+		// a suicide removes only the stones of the player
+		capt, suicide := brd.play(i, mov.colr)
+		if (mov.colr == ah.Black) != suicide {
+			sc.WhiteCaptures += len(capt)
+		} else {
+			sc.BlackCaptures += len(capt)
+		}
+	}
+
+	// the owner of each point, after removing the dead stones
+	owner := make([]ah.PointStatus, len(brd.pts))
+	for i := range owner {
+		owner[i] = ah.Unocc
+	}
+	if dead == nil && gamT.HasTerritory() {
+		sc.Marked = true
+		last := gamT.lastMainNode()
+		mark := func(typ PropertyDefIdx, colr ah.PointStatus) {
+			for _, nl := range gamT.propPoints(last, typ) {
+				if i := brd.index(nl); i >= 0 && brd.pts[i] != colr {
+					sc.removeDead(brd, i)
+					owner[i] = colr
+				}
+			}
+		}
+		mark(TB_idx, ah.Black)
+		mark(TW_idx, ah.White)
+	} else {
+		for _, nl := range dead {
+			if i := brd.index(nl); i >= 0 && brd.pts[i] != ah.Unocc {
+				stones, _ := brd.group(i)
+				for _, s := range stones {
+					sc.removeDead(brd, s)
+				}
+			}
+		}
+		brd.fillTerritory(owner)
+	}
+	for i, st := range brd.pts {
+		switch {
+		case st == ah.Black:
+			sc.BlackStones += 1
+		case st == ah.White:
+			sc.WhiteStones += 1
+		case owner[i] == ah.Black:
+			sc.BlackTerritory += 1
+		case owner[i] == ah.White:
+			sc.WhiteTerritory += 1
+		}
+	}
+	return sc, errs
+}
+
+// isOldPass returns true for "tt", a pass in FF[3], on boards up to 19 by 19.
+func isOldPass(nl ah.NodeLoc, brd *replayBoard) bool {
+	c, r := ah.GetColRow(nl)
+	return c == 19 && r == 19 && brd.nCol <= 19 && brd.nRow <= 19
+}
+
+// removeDead removes the dead stone at point i, and counts it.
+func (sc *Score) removeDead(brd *replayBoard, i int) {
+	switch brd.pts[i] {
+	case ah.Black:
+		sc.BlackDead += 1
+	case ah.White:
+		sc.WhiteDead += 1
+	}
+	brd.pts[i] = ah.Unocc
+}
+
+// fillTerritory sets the owner of each empty region that touches stones of only one color.
+// Regions that touch both colors, or none, are left ah.Unocc.
+func (b *replayBoard) fillTerritory(owner []ah.PointStatus) {
+	b.newMarks()
+	for i, st := range b.pts {
+		if st != ah.Unocc || b.mark[i] == b.markGen {
+			continue
+		}
+		region := []int{i}
+		b.mark[i] = b.markGen
+		touchB, touchW := false, false
+		for k := 0; k < len(region); k++ {
+			b.eachAdj(region[k], func(j int) {
+				switch b.pts[j] {
+				case ah.Black:
+					touchB = true
+				case ah.White:
+					touchW = true
+				default:
+					if b.mark[j] != b.markGen {
+						b.mark[j] = b.markGen
+						region = append(region, j)
+					}
+				}
+			})
+		}
+		if touchB != touchW {
+			colr := ah.White
+			if touchB {
+				colr = ah.Black
+			}
+			for _, j := range region {
+				owner[j] = colr
+			}
+		}
+	}
+}
+
+// A ScoreMismatch is a game whose RE does not agree with the score of its final position.
+type ScoreMismatch struct {
+	File   string
+	Game   int // the index of the game in the file, see SelectGame
+	RE     string
+	Result GameResult
+	Score  Score
+}
+
+// CheckScores parses the .sgf files in dir, and its subdirectories, scores the games
+// whose RE is a score, and whose final position is marked with TB and TW,
+// and returns the games where RE does not match the score. Each game of a collection is checked.
+// Games whose final position is not marked with TB and TW are skipped, and not
+// counted in checked: their dead stones are not known (see ScoreGame).
+// A file with errors is reported, and not included.
+func CheckScores(dir string) (mismatches []ScoreMismatch, checked int, errs ah.ErrorList) {
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs.Add(ah.NoPos, path+": "+err.Error())
+			return nil
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".sgf") {
+			return nil
+		}
+		prsr, errL := ParseFile(path, nil, 0, 0)
+		if len(errL) != 0 {
+			errs.Add(ah.NoPos, path+": "+errL.Error())
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		gamT := &prsr.GameTree
+		for g := 0; g < gamT.NumGames(); g++ {
+			gamT.SelectGame(g)
+			res, err := gamT.GetResult()
+			if err != nil || res.Reason != ReasonScore && res.Winner != WinDraw || !gamT.HasTerritory() {
+				continue
+			}
+			sc, errS := gamT.ScoreGame(nil)
+			if len(errS) != 0 {
+				errs.Add(ah.NoPos, path+": game "+strconv.Itoa(g)+": "+errS.Error())
+				continue
+			}
+			checked += 1
+			if !sc.Matches(res) {
+				mismatches = append(mismatches, ScoreMismatch{File: rel, Game: g, RE: string(gamT.rE.val), Result: res, Score: sc})
+			}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		errs.Add(ah.NoPos, dir+": "+walkErr.Error())
+	}
+	return mismatches, checked, errs
+}
@@ -0,0 +1,440 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/searchPatterns.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 2/24/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file searches a data base of games for local patterns (shapes).
+ *	The games are first collected in a PatternIndex, which can be saved,
+ *	so repeated searches do not need to parse the SGF files, or replay the games, again.
+ */
+
+package sgf
+
+import (
+	"encoding/gob"
+	"errors"
+	"github.com/Ken1JF/ah"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A LocalPattern is a rectangle of points to search for.
+// Each point is one of:
+//	'X' a Black stone
+//	'O' a White stone
+//	'.' an empty point
+//	'?' any point (don't care)
+type LocalPattern struct {
+	Cols, Rows int
+	pts        []byte // row major
+}
+
+// NewLocalPattern makes a LocalPattern from its rows, for example:
+//	[]string{"?XO?", "XO.O", "?XO?"}
+// All rows must have the same length.
+func NewLocalPattern(rows []string) (pat *LocalPattern, err ah.ErrorList) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		err.Add(ah.NoPos, "NewLocalPattern: empty pattern")
+		return nil, err
+	}
+	pat = new(LocalPattern)
+	pat.Cols = len(rows[0])
+	pat.Rows = len(rows)
+	for i, row := range rows {
+		if len(row) != pat.Cols {
+			err.Add(ah.NoPos, "NewLocalPattern: row "+strconv.Itoa(i)+" has "+strconv.Itoa(len(row))+
+				" points, not "+strconv.Itoa(pat.Cols))
+			return nil, err
+		}
+		for j := 0; j < len(row); j++ {
+			switch row[j] {
+			case 'X', 'O', '.', '?':
+			default:
+				err.Add(ah.NoPos, "NewLocalPattern: bad point \""+row[j:j+1]+"\" in row "+strconv.Itoa(i))
+				return nil, err
+			}
+		}
+		pat.pts = append(pat.pts, row...)
+	}
+	return pat, err
+}
+
+// patternVariant is a LocalPattern after a symmetry, and perhaps swapping the colors.
+type patternVariant struct {
+	trans      ah.BoardTrans
+	swapped    bool
+	cols, rows int
+	pts        []byte
+}
+
+// variants returns the different variants of a pattern, under the 8 symmetries,
+// and, if swapColors is true, with the colors swapped.
+// Variants that are the same as an earlier one (for symmetric patterns) are not included.
+func (pat *LocalPattern) variants(swapColors bool) (vars []patternVariant) {
+	seen := make(map[string]bool)
+	for s := 0; s < 2; s++ {
+		if s == 1 && !swapColors {
+			break
+		}
+		for t := ah.T_FIRST; t <= ah.T_LAST; t++ {
+			v := patternVariant{trans: t, swapped: s == 1, cols: pat.Cols, rows: pat.Rows}
+			switch t {
+			case ah.T_ROTA_090, ah.T_ROTA_270, ah.T_FLP_SLAS, ah.T_FLP_BACK:
+				v.cols, v.rows = pat.Rows, pat.Cols
+			}
+			v.pts = make([]byte, len(pat.pts))
+			for y := 0; y < pat.Rows; y++ {
+				for x := 0; x < pat.Cols; x++ {
+					p := pat.pts[y*pat.Cols+x]
+					if v.swapped {
+						if p == 'X' {
+							p = 'O'
+						} else if p == 'O' {
+							p = 'X'
+						}
+					}
+					tx, ty := transLocal(t, x, y, pat.Cols, pat.Rows)
+					v.pts[ty*v.cols+tx] = p
+				}
+			}
+			key := strconv.Itoa(v.cols) + ":" + string(v.pts)
+			if !seen[key] {
+				seen[key] = true
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars
+}
+
+// An IndexedMove is a move, or a setup stone, in an IndexedGame.
+//	Capt are the stones captured by a move, so a search only places and removes
+//	stones, and does not need to find the captures again.
+type IndexedMove struct {
+	Loc   ah.NodeLoc
+	Color ah.PointStatus
+	Capt  []ah.NodeLoc
+}
+
+// An IndexedGame holds the main line of one game, in a compact form.
+//	Game is the index of the game in File, see SelectGame.
+//	EverBlack and EverWhite are bit sets of the points (r*NCol + c)
+//	that have held a Black or a White stone, at any time in the game.
+type IndexedGame struct {
+	File       string
+	Game       int
+	NCol, NRow int
+	Setup      []IndexedMove
+	Moves      []IndexedMove
+	EverBlack  []uint64
+	EverWhite  []uint64
+}
+
+// patternIndexVersion is the version of the PatternIndex saved by WriteIndex.
+//	2: the captures of each move (IndexedMove.Capt) are saved.
+//	3: the index of the game in its file (IndexedGame.Game) is saved.
+const patternIndexVersion = 3
+
+// A PatternIndex holds the games of a data base, so they can be searched
+// without parsing the SGF files again.
+type PatternIndex struct {
+	Version int
+	Dir     string
+	Games   []IndexedGame
+}
+
+// A PatternMatch records where a LocalPattern was found.
+//	Game is the index of the game in File, see SelectGame.
+//	Move is the number of moves played when the pattern appeared (0 => the setup position).
+//	Col, Row is the upper left corner of the (transformed) pattern on the board.
+//	Trans is the symmetry applied to the pattern, and Swapped is true if the colors were swapped.
+type PatternMatch struct {
+	File     string
+	Game     int
+	Move     int
+	Col, Row int
+	Trans    ah.BoardTrans
+	Swapped  bool
+}
+
+// AddGame adds the main line of the selected game in gamT to the index.
+//	file is the name recorded in the results, usually relative to idx.Dir
+// The game is replayed once, here, to record the captures of each move.
+func (idx *PatternIndex) AddGame(file string, gamT *GameTree) {
+	nCol, nRow := gamT.boardSize()
+	g := IndexedGame{File: file, Game: gamT.SelectedGame(), NCol: nCol, NRow: nRow}
+	nWords := (g.NCol*g.NRow + 63) / 64
+	g.EverBlack = make([]uint64, nWords)
+	g.EverWhite = make([]uint64, nWords)
+	brd := newReplayBoard(g.NCol, g.NRow)
+	ever := func(i int, colr ah.PointStatus) {
+		if colr == ah.Black {
+			g.EverBlack[i/64] |= 1 << uint(i%64)
+		} else {
+			g.EverWhite[i/64] |= 1 << uint(i%64)
+		}
+	}
+	for _, nl := range gamT.aB {
+		g.Setup = append(g.Setup, IndexedMove{Loc: nl, Color: ah.Black})
+	}
+	for _, nl := range gamT.aW {
+		g.Setup = append(g.Setup, IndexedMove{Loc: nl, Color: ah.White})
+	}
+	for _, m := range g.Setup {
+		if i := brd.index(m.Loc); i >= 0 {
+			brd.pts[i] = m.Color
+			ever(i, m.Color)
+		}
+	}
+	for _, mov := range gamT.mainLineMoves() {
+		m := IndexedMove{Loc: mov.loc, Color: mov.colr}
+		if i := brd.index(mov.loc); i >= 0 {
+			capt, _ := brd.play(i, mov.colr)
+			for _, j := range capt {
+				m.Capt = append(m.Capt, brd.nodeLoc(j))
+			}
+			ever(i, mov.colr)
+		}
+		g.Moves = append(g.Moves, m)
+	}
+	idx.Games = append(idx.Games, g)
+}
+
+// BuildPatternIndex parses the .sgf files in dir, and its subdirectories,
+// and returns an index of each of their games.
+// The files are parsed with ParserPlay. A file with errors is reported, and not indexed.
+func BuildPatternIndex(dir string) (idx *PatternIndex, errs ah.ErrorList) {
+	idx = new(PatternIndex)
+	idx.Dir = dir
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs.Add(ah.NoPos, path+": "+err.Error())
+			return nil
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".sgf") {
+			return nil
+		}
+		prsr, errL := ParseFile(path, nil, ParserPlay, 0)
+		if len(errL) != 0 {
+			errs.Add(ah.NoPos, path+": "+errL.Error())
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		for g := 0; g < prsr.NumGames(); g++ {
+			prsr.SelectGame(g)
+			idx.AddGame(rel, &prsr.GameTree)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		errs.Add(ah.NoPos, dir+": "+walkErr.Error())
+	}
+	return idx, errs
+}
+
+// WriteIndex saves the index in a file.
+func (idx *PatternIndex) WriteIndex(fileName string) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return errors.New("WriteIndex: " + fileName + " " + err.Error())
+	}
+	defer f.Close()
+	idx.Version = patternIndexVersion
+	err = gob.NewEncoder(f).Encode(idx)
+	if err != nil {
+		return errors.New("WriteIndex: " + fileName + " " + err.Error())
+	}
+	return f.Close()
+}
+
+// ReadPatternIndex reads an index saved by WriteIndex.
+// An index saved by an older version is an error: build it again.
+func ReadPatternIndex(fileName string) (idx *PatternIndex, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.New("ReadPatternIndex: " + fileName + " " + err.Error())
+	}
+	defer f.Close()
+	idx = new(PatternIndex)
+	err = gob.NewDecoder(f).Decode(idx)
+	if err != nil {
+		return nil, errors.New("ReadPatternIndex: " + fileName + " " + err.Error())
+	}
+	if idx.Version != patternIndexVersion {
+		return nil, errors.New("ReadPatternIndex: " + fileName + " is version " + strconv.Itoa(idx.Version) +
+			", not " + strconv.Itoa(patternIndexVersion))
+	}
+	return idx, nil
+}
+
+// mayContain returns true if the stones of variant v could all be present in
+// game g, at some offset. It is used to skip games without replaying them.
+func (g *IndexedGame) mayContain(v *patternVariant) bool {
+	for or := 0; or+v.rows <= g.NRow; or++ {
+		for oc := 0; oc+v.cols <= g.NCol; oc++ {
+			ok := true
+			for y := 0; y < v.rows && ok; y++ {
+				for x := 0; x < v.cols && ok; x++ {
+					i := (or+y)*g.NCol + oc + x
+					switch v.pts[y*v.cols+x] {
+					case 'X':
+						ok = g.EverBlack[i/64]&(1<<uint(i%64)) != 0
+					case 'O':
+						ok = g.EverWhite[i/64]&(1<<uint(i%64)) != 0
+					}
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchAt returns true if variant v matches the points of g, pts, at offset oc, or.
+func (g *IndexedGame) matchAt(pts []ah.PointStatus, v *patternVariant, oc int, or int) bool {
+	for y := 0; y < v.rows; y++ {
+		for x := 0; x < v.cols; x++ {
+			s := pts[(or+y)*g.NCol+oc+x]
+			switch v.pts[y*v.cols+x] {
+			case 'X':
+				if s != ah.Black {
+					return false
+				}
+			case 'O':
+				if s != ah.White {
+					return false
+				}
+			case '.':
+				if s != ah.Unocc {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// searchGame steps through game g, and returns the matches of the variants.
+// A match is reported when the pattern appears, at the first move it is on the board.
+// Each move places its stone, and removes its captures (Capt), so the game is not replayed.
+// After the setup position, only the windows containing a changed point are checked.
+func (g *IndexedGame) searchGame(vars []patternVariant) (matches []PatternMatch) {
+	var cand []int
+	for i := range vars {
+		if g.mayContain(&vars[i]) {
+			cand = append(cand, i)
+		}
+	}
+	if len(cand) == 0 {
+		return matches
+	}
+	nWin := g.NCol * g.NRow
+	pts := make([]ah.PointStatus, nWin)
+	for i := range pts {
+		pts[i] = ah.Unocc
+	}
+	index := func(nl ah.NodeLoc) int {
+		if nl == ah.PassNodeLoc || nl == ah.NilNodeLoc || nl == ah.IllegalNodeLoc {
+			return -1
+		}
+		c, r := ah.GetColRow(nl)
+		if int(c) >= g.NCol || int(r) >= g.NRow {
+			return -1
+		}
+		return int(r)*g.NCol + int(c)
+	}
+	matched := make([]bool, len(vars)*nWin) // by variant, and upper left point
+	checked := make([]int, len(vars)*nWin)  // last move at which a window was checked
+	check := func(vi int, oc int, or int, movN int) {
+		w := vi*nWin + or*g.NCol + oc
+		if checked[w] == movN+1 {
+			return
+		}
+		checked[w] = movN + 1
+		m := g.matchAt(pts, &vars[vi], oc, or)
+		if m && !matched[w] {
+			matches = append(matches, PatternMatch{File: g.File, Game: g.Game, Move: movN, Col: oc, Row: or,
+				Trans: vars[vi].trans, Swapped: vars[vi].swapped})
+		}
+		matched[w] = m
+	}
+	// checkAround checks the windows that contain point i
+	checkAround := func(i int, movN int) {
+		c := i % g.NCol
+		r := i / g.NCol
+		for _, vi := range cand {
+			v := &vars[vi]
+			for or := r - v.rows + 1; or <= r; or++ {
+				if or < 0 || or+v.rows > g.NRow {
+					continue
+				}
+				for oc := c - v.cols + 1; oc <= c; oc++ {
+					if oc < 0 || oc+v.cols > g.NCol {
+						continue
+					}
+					check(vi, oc, or, movN)
+				}
+			}
+		}
+	}
+	for _, m := range g.Setup {
+		if i := index(m.Loc); i >= 0 {
+			pts[i] = m.Color
+		}
+	}
+	for _, vi := range cand {
+		v := &vars[vi]
+		for or := 0; or+v.rows <= g.NRow; or++ {
+			for oc := 0; oc+v.cols <= g.NCol; oc++ {
+				check(vi, oc, or, 0)
+			}
+		}
+	}
+	for n, m := range g.Moves {
+		i := index(m.Loc)
+		if i < 0 { // pass
+			continue
+		}
+		pts[i] = m.Color
+		for _, nl := range m.Capt {
+			if j := index(nl); j >= 0 {
+				pts[j] = ah.Unocc
+			}
+		}
+		checkAround(i, n+1)
+		for _, nl := range m.Capt {
+			// a suicide captures the stone just played, which has been checked
+			if j := index(nl); j >= 0 && j != i {
+				checkAround(j, n+1)
+			}
+		}
+	}
+	return matches
+}
+
+// Search returns the places where pat appears in the games of the index,
+// under all symmetries, and, if swapColors is true, with the colors swapped.
+func (idx *PatternIndex) Search(pat *LocalPattern, swapColors bool) (matches []PatternMatch) {
+	vars := pat.variants(swapColors)
+	for gi := range idx.Games {
+		matches = append(matches, idx.Games[gi].searchGame(vars)...)
+	}
+	return matches
+}
+
+// SearchDirectory builds an index of the games in dir, and searches it for pat.
+// To search the same games more than once, use BuildPatternIndex, and Search.
+func SearchDirectory(dir string, pat *LocalPattern, swapColors bool) (matches []PatternMatch, errs ah.ErrorList) {
+	idx, errs := BuildPatternIndex(dir)
+	matches = idx.Search(pat, swapColors)
+	return matches, errs
+}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 	"unsafe"
 )
@@ -130,10 +131,9 @@ func checkPropertyType(prop *Property, inGameRoot bool) (ret error) {
 				ret = errors.New("RootProp not in root node")
 			case SetupProp:
 				ret = errors.New("SetupProp not in root node")
-			case GameInfoProp:
-				ret = errors.New("GameInfoProp not in root node")
-			case NoType, MoveProp:
-				// do nothing
+			case NoType, MoveProp, GameInfoProp:
+				// do nothing: game-info is allowed in any node,
+				// but only once on each path, which the Parser checks
 			default:
 				ret = errors.New("unknown SGFPropNodeType")
 			}
@@ -244,8 +244,27 @@ type Property struct {
 
 // theProperties is an internal array containing the properties
 // It is const after initialization, i.e. thread-safe to share.
+// It is read with properties, so it is not read while it is being built.
 var theProperties []Property
 
+// specCounts are the counts of the lines, bytes, and properties
+// read from the SGF Specification file, reported by SetupSGFProperties.
+var specCounts struct {
+	lines, bytes, props int
+}
+
+// propertiesMu makes the initialization of theProperties happen once,
+// even when SetupSGFProperties is called from many goroutines,
+// and keeps the readers of theProperties (see properties) out while it happens.
+var propertiesMu sync.RWMutex
+
+// properties returns theProperties, once no SetupSGFProperties is building them.
+func properties() []Property {
+	propertiesMu.RLock()
+	defer propertiesMu.RUnlock()
+	return theProperties
+}
+
 // PropertyDefIdx is an index into theProperties
 type PropertyDefIdx int8 // index into theProperties
 
@@ -325,14 +344,15 @@ const (
 	W_idx  PropertyDefIdx = 70
 	WB_idx PropertyDefIdx = 71
 	WC_idx PropertyDefIdx = 72
-	WL_idx PropertyDefIdx = 73
-	WO_idx PropertyDefIdx = 74
-	WR_idx PropertyDefIdx = 75
-	WT_idx PropertyDefIdx = 76
-	WW_idx PropertyDefIdx = 77
+	WG_idx PropertyDefIdx = 73
+	WL_idx PropertyDefIdx = 74
+	WO_idx PropertyDefIdx = 75
+	WR_idx PropertyDefIdx = 76
+	WT_idx PropertyDefIdx = 77
+	WW_idx PropertyDefIdx = 78
 )
 
-type ID_CountArray [78]int
+type ID_CountArray [79]int
 
 // TODO: should this be 0 (initially) => len(theProperties) after initialization?
 // to allow more then 127 properties?
@@ -341,8 +361,9 @@ const UnknownPropIdx PropertyDefIdx = PropertyDefIdx(-1)
 // GetProperty is an accessor function, returning the Property strut associated
 // with a PropertyDefIdx value
 func GetProperty(idx PropertyDefIdx) (p *Property) {
-	if idx >= 0 && idx < PropertyDefIdx(len(theProperties)) {
-		p = &((theProperties)[idx])
+	props := properties()
+	if idx >= 0 && idx < PropertyDefIdx(len(props)) {
+		p = &(props[idx])
 	}
 	return p
 }
@@ -497,7 +518,7 @@ func addPropertyDef(sp []Property, p Property) []Property {
 // readSpecFile reads the SGF Specification file,
 // and stores the properties read in theProperties.
 // if an error occurs, it returns an Error other than io.EOF
-func readSpecFile(fn string, verbose bool) (err error) {
+func readSpecFile(fn string) (err error) {
 	var (
 		line                               []byte
 		line_count, byte_count, prop_count int
@@ -534,9 +555,7 @@ func readSpecFile(fn string, verbose bool) (err error) {
 			break
 		}
 	}
-	if verbose {
-		fmt.Printf("Read: %d lines, %d bytes, %d properties.\n", line_count, byte_count, prop_count)
-	}
+	specCounts.lines, specCounts.bytes, specCounts.props = line_count, byte_count, prop_count
 	return err
 }
 
@@ -544,56 +563,62 @@ func readSpecFile(fn string, verbose bool) (err error) {
 //	0 if all properties are in order
 //	-1 if SGF Specification file cannot be read,
 //	n if n properties are out of order
+// The file is read by the first successful call. Each call lists (verbose),
+// and verifies (verifyOrder), the properties, so the result does not depend
+// on which call reads the file.
 func SetupSGFProperties(specFile string, verifyOrder bool, verbose bool) (ret int) {
+	propertiesMu.Lock()
+	defer propertiesMu.Unlock()
 	if theProperties == nil {
-		err := readSpecFile(specFile, verbose)
+		err := readSpecFile(specFile)
 		if err != nil && err != io.EOF {
 			fmt.Printf("Error reading SGF Spec File: %s, %s\n", specFile, err)
+			theProperties = nil // try again on the next call
 			return -1
 		}
-		if verbose {
-			for i, p := range theProperties {
-				fmt.Printf("%2d:%3s:%16s:%10v:%10s:%8s:%3d:%s\n",
-					i, p.ID, p.Description, p.FF4Type,
-					QualifierNames[p.Qualifier], FF4NoteNames[p.Note],
-					p.Value, ValueNames[p.Value])
-			}
-			for i, p := range theProperties {
-				fmt.Printf("%s_idx PropertyDefIdx = %d\n", p.ID, i)
-			}
-			for _, p := range theProperties {
-				fmt.Printf("{ %d", p.Note)
-				fmt.Printf(", \"%s\"", p.ID)
-				fmt.Printf(", \"%s\", ", p.Description)
-				fmt.Printf(" %d", p.FF4Type)
-				fmt.Printf(", %d", p.Qualifier)
-				fmt.Printf(", %d },\n", p.Value)
-			}
+	}
+	if verbose {
+		fmt.Printf("Read: %d lines, %d bytes, %d properties.\n", specCounts.lines, specCounts.bytes, specCounts.props)
+		for i, p := range theProperties {
+			fmt.Printf("%2d:%3s:%16s:%10v:%10s:%8s:%3d:%s\n",
+				i, p.ID, p.Description, p.FF4Type,
+				QualifierNames[p.Qualifier], FF4NoteNames[p.Note],
+				p.Value, ValueNames[p.Value])
 		}
-		var prev_p Property
-		if verifyOrder {
-			for i, p := range theProperties {
-				if i > 0 { // skip first one, no previous one to compare to
-					if bytes.Compare(prev_p.ID, p.ID) >= 0 {
-						fmt.Printf("Error, properties out of order: \"%s\" >= \"%s\"\n", prev_p.ID, p.ID)
-						ret++
-					}
+		for i, p := range theProperties {
+			fmt.Printf("%s_idx PropertyDefIdx = %d\n", p.ID, i)
+		}
+		for _, p := range theProperties {
+			fmt.Printf("{ %d", p.Note)
+			fmt.Printf(", \"%s\"", p.ID)
+			fmt.Printf(", \"%s\", ", p.Description)
+			fmt.Printf(" %d", p.FF4Type)
+			fmt.Printf(", %d", p.Qualifier)
+			fmt.Printf(", %d },\n", p.Value)
+		}
+	}
+	var prev_p Property
+	if verifyOrder {
+		for i, p := range theProperties {
+			if i > 0 { // skip first one, no previous one to compare to
+				if bytes.Compare(prev_p.ID, p.ID) >= 0 {
+					fmt.Printf("Error, properties out of order: \"%s\" >= \"%s\"\n", prev_p.ID, p.ID)
+					ret++
 				}
-				prev_p = p
 			}
+			prev_p = p
 		}
-		return ret
-	} else {
-		return 0
 	}
+	return ret
 }
 
 // LookUp does a binary search on theProperties,
 // and returns the PropertyDefIdx corresponding to the id.
 // If not found, returns UnknownPropIdx
 func LookUp(id []byte) (prop PropertyDefIdx) {
+	props := properties()
 	var (
-		LEN      int = len(theProperties)
+		LEN      int = len(props)
 		min, max int = 0, LEN - 1
 		mid      int
 	)
@@ -604,7 +629,7 @@ Loop:
 		if mid >= LEN {
 			break
 		} // not found
-		switch bytes.Compare(id, (theProperties)[mid].ID) {
+		switch bytes.Compare(id, props[mid].ID) {
 		case -1:
 			max = mid - 1
 		case 0:
@@ -620,6 +645,38 @@ Loop:
 // SGF FF[4] suuports up to 52x52 board sizes:
 const sgf_coords = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
+// MaxBoardSize is the most columns, or rows, of a board in FF[4].
+const MaxBoardSize = len(sgf_coords)
+
+// ParseSize parses the value of an SZ property: "19" for a square board,
+// or "19:13" for a board of 19 columns and 13 rows.
+// Each size must be from 1 to MaxBoardSize.
+func ParseSize(s []byte) (nCol int, nRow int, err ah.ErrorList) {
+	str := strings.TrimSpace(string(s))
+	colStr, rowStr := str, str
+	if idx := strings.Index(str, ":"); idx >= 0 {
+		colStr, rowStr = str[0:idx], str[idx+1:]
+	}
+	var errC, errR error
+	nCol, errC = strconv.Atoi(strings.TrimSpace(colStr))
+	nRow, errR = strconv.Atoi(strings.TrimSpace(rowStr))
+	if errC != nil || errR != nil {
+		err.Add(ah.NoPos, "ParseSize: bad size "+str)
+	} else if nCol < 1 || nCol > MaxBoardSize || nRow < 1 || nRow > MaxBoardSize {
+		err.Add(ah.NoPos, "ParseSize: size "+str+" not 1 to "+strconv.Itoa(MaxBoardSize))
+	}
+	return nCol, nRow, err
+}
+
+// FormatSize writes the value of an SZ property: a single number for a square board,
+// and columns:rows for a rectangular board.
+func FormatSize(nCol int, nRow int) string {
+	if nCol == nRow {
+		return strconv.Itoa(nCol)
+	}
+	return strconv.Itoa(nCol) + ":" + strconv.Itoa(nRow)
+}
+
 // Given a NodeLoc, convert to SGF coordinates:
 // isFF4 handles the FF[3] vs FF[4] variation in representation of "pass"
 func SGFCoords(n ah.NodeLoc, isFF4 bool) (ret []byte) {
@@ -725,7 +725,7 @@ func ExampleCannonicalHandicap() {
 			var gam *GameTree = new(GameTree)
 			gam.InitAbstHier(19, 19, ah.StringLevel, true)
 			gam.SetHandicap(ha)
-			gam.PlaceHandicap(ha, 19)
+			gam.PlaceHandicap(ha, 19, 19)
 			for r := 0; r < 19; r++ {
 				for c := 0; c < 19; c++ {
 					nl := ah.MakeNodeLoc(ah.ColValue(c), ah.RowValue(r))
@@ -1100,3 +1100,163 @@ func ExamplePrintBoard() {
 	// [K 11]: [K 10], [J 11], [L 11]
 	// [L 11]: [L 10], [K 11]
 }
+
+func ExampleParseRank() {
+	for _, s := range []string{"5k", "3 dan", "9-Dan", "9p", "2d?", "4k* (KGS)", "?", "9di", "98d", "0d", "2.5", "Holder", "9"} {
+		r, err := ParseRank(s)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%q: %s, %s, level %d\n", s, r, RankKindNames[r.Kind], r.Level())
+	}
+	k1, _ := ParseRank("1k")
+	d2, _ := ParseRank("2d")
+	p1, _ := ParseRank("1p")
+	diff, _ := d2.Diff(k1)
+	fmt.Println("2d - 1k:", diff, "compare:", d2.Compare(k1), p1.Compare(d2), k1.Compare(Rank{}))
+	// Output:
+	// "5k": 5k, kyu, level -4
+	// "3 dan": 3d, dan, level 3
+	// "9-Dan": 9d, dan, level 9
+	// "9p": 9p, pro, level 18
+	// "2d?": 2d?, dan, level 2
+	// "4k* (KGS)": 4k* (KGS), kyu, level -3
+	// "?": ?, unknown, level 0
+	// rank "9di": unexpected "i", at 2
+	// rank "98d": dan grade greater than 9, at 0
+	// rank "0d": grade must be at least 1, at 0
+	// rank "2.5": unexpected ".", at 1
+	// rank "Holder": missing grade, at 0
+	// rank "9": missing k, d, or p, at 1
+	// 2d - 1k: 2 compare: 1 1 1
+}
+
+func ExampleParseResult() {
+	for _, s := range []string{"B+R", "W+2.5", "0", "Draw", "Void", "?", "B+Resign", "W+T", "B+",
+		"w+r", "B+2,5", "B+.5", "W4.5", "B++", "W+40 zi", "Jigo", "UF", "Left unfinished", "W+1.5 (moves beyond 200 not known)",
+		"B+8.8", "B+).5", "W+jigo", "Holder"} {
+		r, fix, err := ParseResult(s)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%q: %s, %s, %v", s, WinnerNames[r.Winner], ResultReasonNames[r.Reason], r.Margin)
+		if fix != "" {
+			fmt.Printf(", fix: %s", fix)
+		}
+		if !r.HalfPoints() {
+			fmt.Printf(", margin not half points")
+		}
+		fmt.Println()
+	}
+	// Output:
+	// "B+R": Black, resign, 0
+	// "W+2.5": White, score, 2.5
+	// "0": draw, , 0
+	// "Draw": draw, , 0
+	// "Void": void, , 0
+	// "?": unknown, , 0
+	// "B+Resign": Black, resign, 0
+	// "W+T": White, time, 0
+	// "B+": Black, , 0
+	// "w+r": White, resign, 0, fix: W+R
+	// "B+2,5": Black, score, 2.5, fix: B+2.5
+	// "B+.5": Black, score, 0.5, fix: B+0.5
+	// "W4.5": White, score, 4.5, fix: W+4.5
+	// "B++": Black, , 0, fix: B+
+	// "W+40 zi": White, score, 40, fix: W+40
+	// "Jigo": draw, , 0, fix: 0
+	// "UF": void, , 0, fix: Void
+	// "Left unfinished": void, , 0
+	// "W+1.5 (moves beyond 200 not known)": White, score, 1.5
+	// "B+8.8": Black, score, 8.8, margin not half points
+	// result "B+).5": bad margin ").5"
+	// result "W+jigo": a win by jigo
+	// result "Holder": must start with B or W
+}
+
+func ExampleParseDate() {
+	for _, s := range []string{"1996", "1996-05", "1996-05-06,07,08", "1996-05,06", "1996-12-27,28,1997-01-03,04",
+		"1996/05/06", "19960506", "1996-05-30~06-02", "May 6, 1996", "6 May 1996", "Autumn 1934",
+		"1996-13", "1996-02-30", "05-06", "1996,07", "Showa"} {
+		dates, err := ParseDate(s)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%q: %s, %s, earliest %s", s, FormatDate(dates), DatePrecisionNames[dates[0].Precision()], DateKey(s))
+		fmt.Println()
+	}
+	// Output:
+	// "1996": 1996, year, earliest 1996-00-00
+	// "1996-05": 1996-05, month, earliest 1996-05-00
+	// "1996-05-06,07,08": 1996-05-06,07,08, day, earliest 1996-05-06
+	// "1996-05,06": 1996-05,06, month, earliest 1996-05-00
+	// "1996-12-27,28,1997-01-03,04": 1996-12-27,28,1997-01-03,04, day, earliest 1996-12-27
+	// "1996/05/06": 1996-05-06, day, earliest 1996-05-06
+	// "19960506": 1996-05-06, day, earliest 1996-05-06
+	// "1996-05-30~06-02": 1996-05-30,31,06-01,02, day, earliest 1996-05-30
+	// "May 6, 1996": 1996-05-06, day, earliest 1996-05-06
+	// "6 May 1996": 1996-05-06, day, earliest 1996-05-06
+	// "Autumn 1934": 1934-09,10,11, month, earliest 1934-09-00
+	// date "1996-13": month 13 out of range
+	// date "1996-02-30": day 30 out of range
+	// date "05-06": must start with a year
+	// date "1996,07": bad abbreviation "07"
+	// date "Showa": no year
+}
+
+func ExampleParseTimeControl() {
+	for _, tmot := range [][2]string{{"2700", ""}, {"1h 30m", ""}, {"90 min each", ""}, {"1h sudden death", ""},
+		{"3600", "5x30 byo-yomi"}, {"600", "3 periods of 30 seconds"}, {"0", "30s byo-yomi"},
+		{"1800", "25/600 Canadian"}, {"600", "25 moves in 10 min"}, {"300", "25 stones/10:00"},
+		{"300", "10s Fischer"}, {"", "Fischer +10 sec"}, {"1h + 5x30s byo-yomi", ""},
+		{"abc", ""}, {"600", "overtime"}} {
+		tc, err := ParseTimeControl(tmot[0], tmot[1])
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%q %q: %s (%s)\n", tmot[0], tmot[1], tc, OvertimeKindNames[tc.Overtime])
+	}
+	// Output:
+	// "2700" "": 45m (unknown)
+	// "1h 30m" "": 1h30m (unknown)
+	// "90 min each" "": 1h30m (unknown)
+	// "1h sudden death" "": 1h, absolute (absolute)
+	// "3600" "5x30 byo-yomi": 1h, 5x30s byo-yomi (byo-yomi)
+	// "600" "3 periods of 30 seconds": 10m, 3x30s byo-yomi (byo-yomi)
+	// "0" "30s byo-yomi": 0s, 1x30s byo-yomi (byo-yomi)
+	// "1800" "25/600 Canadian": 30m, 25/10m Canadian (Canadian)
+	// "600" "25 moves in 10 min": 10m, 25/10m Canadian (Canadian)
+	// "300" "25 stones/10:00": 5m, 25/10m Canadian (Canadian)
+	// "300" "10s Fischer": 5m, 10s Fischer (Fischer)
+	// "" "Fischer +10 sec": 10s Fischer (Fischer)
+	// "1h + 5x30s byo-yomi" "": 1h, 5x30s byo-yomi (byo-yomi)
+	// TM "abc": no time
+	// OT "overtime": unknown overtime
+}
+
+func ExampleParseSize() {
+	for _, s := range []string{"19", "19:13", " 9 : 7 ", "52:52", "53", "0:9", "19x19"} {
+		nCol, nRow, err := ParseSize([]byte(s))
+		if len(err) != 0 {
+			fmt.Println(err.Error())
+			continue
+		}
+		fmt.Printf("%q: %d by %d, SZ[%s]\n", s, nCol, nRow, FormatSize(nCol, nRow))
+	}
+	// the corners of the largest board
+	far, _ := SGFPoint([]byte("ZZ"))
+	fmt.Println(string(SGFCoords(ah.MakeNodeLoc(0, 0), true)), string(SGFCoords(far, true)))
+	// Output:
+	// "19": 19 by 19, SZ[19]
+	// "19:13": 19 by 13, SZ[19:13]
+	// " 9 : 7 ": 9 by 7, SZ[9:7]
+	// "52:52": 52 by 52, SZ[52]
+	// ParseSize: size 53 not 1 to 52
+	// ParseSize: size 0:9 not 1 to 52
+	// ParseSize: bad size 19x19
+	// aa ZZ
+}
@@ -15,17 +15,17 @@ func ExampleSGFTypeSizes() {
 	// Type PropIdx size 2 alignment 2
 	// Type TreeNode size 12 alignment 2
 	// Type PropertyValue size 32 alignment 8
-	// Type GameTree size 1520 alignment 8
-	// Type Parser size 1840 alignment 8
-	// Type PlayerInfo size 72 alignment 8
-	// Type DBStatistics size 704 alignment 8
+	// Type GameTree size 1656 alignment 8
+	// Type Parser size 2016 alignment 8
+	// Type PlayerInfo size 152 alignment 8
+	// Type DBStatistics size 728 alignment 8
 	// Type FF4Note size 1 alignment 1
 	// Type SGFPropNodeType size 1 alignment 1
 	// Type QualifierType size 1 alignment 1
 	// Type PropValueType size 1 alignment 1
 	// Type Property size 56 alignment 8
 	// Type PropertyDefIdx size 1 alignment 1
-	// Type ID_CountArray size 624 alignment 8
+	// Type ID_CountArray size 632 alignment 8
 	// Type Scanner size 112 alignment 8
 	// Type ErrorHandler size 8 alignment 8
 	// Type ah.ErrorList size 24 alignment 8
@@ -43,7 +43,7 @@ func ExampleTheProperties_tt() {
 		fmt.Println("Can't read Specification file:", defaultSpecFile)
 	}
 	// Output:
-	// Read: 78 lines, 3914 bytes, 78 properties.
+	// Read: 79 lines, 3963 bytes, 79 properties.
 	//  0: AB:       Add Black:     setup:          :     Std: 19:list of stone
 	//  1: AE:       Add Empty:     setup:          :     Std:  8:list of point
 	//  2: AN:      Annotation: game-info:          :     Std: 11:simpletext
@@ -117,11 +117,12 @@ func ExampleTheProperties_tt() {
 	// 70:  W:           White:      move:          :     Std: 18:move
 	// 71: WB:      Wins Black:      move:          : Non_std: 17:number
 	// 72: WC:    Win Continue:      move:          : Non_std: 10:composed simpletext ':' simpletext
-	// 73: WL: White time left:      move:          :     Std: 21:real
-	// 74: WO:      Wins Other:      move:          : Non_std: 17:number
-	// 75: WR:      White rank: game-info:          :     Std: 11:simpletext
-	// 76: WT:      White team: game-info:          :     Std: 11:simpletext
-	// 77: WW:      Wins White:      move:          : Non_std: 17:number
+	// 73: WG:       Win Games:      move:          : Non_std: 11:simpletext
+	// 74: WL: White time left:      move:          :     Std: 21:real
+	// 75: WO:      Wins Other:      move:          : Non_std: 17:number
+	// 76: WR:      White rank: game-info:          :     Std: 11:simpletext
+	// 77: WT:      White team: game-info:          :     Std: 11:simpletext
+	// 78: WW:      Wins White:      move:          : Non_std: 17:number
 	// AB_idx PropertyDefIdx = 0
 	// AE_idx PropertyDefIdx = 1
 	// AN_idx PropertyDefIdx = 2
@@ -195,11 +196,12 @@ func ExampleTheProperties_tt() {
 	// W_idx PropertyDefIdx = 70
 	// WB_idx PropertyDefIdx = 71
 	// WC_idx PropertyDefIdx = 72
-	// WL_idx PropertyDefIdx = 73
-	// WO_idx PropertyDefIdx = 74
-	// WR_idx PropertyDefIdx = 75
-	// WT_idx PropertyDefIdx = 76
-	// WW_idx PropertyDefIdx = 77
+	// WG_idx PropertyDefIdx = 73
+	// WL_idx PropertyDefIdx = 74
+	// WO_idx PropertyDefIdx = 75
+	// WR_idx PropertyDefIdx = 76
+	// WT_idx PropertyDefIdx = 77
+	// WW_idx PropertyDefIdx = 78
 	// { 0, "AB", "Add Black",  2, 0, 19 },
 	// { 0, "AE", "Add Empty",  2, 0, 8 },
 	// { 0, "AN", "Annotation",  3, 0, 11 },
@@ -273,6 +275,7 @@ func ExampleTheProperties_tt() {
 	// { 0, "W", "White",  4, 0, 18 },
 	// { 3, "WB", "Wins Black",  4, 0, 17 },
 	// { 3, "WC", "Win Continue",  4, 0, 10 },
+	// { 3, "WG", "Win Games",  4, 0, 11 },
 	// { 0, "WL", "White time left",  4, 0, 21 },
 	// { 3, "WO", "Wins Other",  4, 0, 17 },
 	// { 0, "WR", "White rank",  3, 0, 11 },
@@ -1,13 +1,19 @@
 package sgf_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"github.com/Ken1JF/ah"
 	"github.com/Ken1JF/sgf"
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const SGFDir = "./testdata"
@@ -460,3 +466,1104 @@ func ExampleReadWriteSGFFile() {
 	// Move[34]: Loc: S4, Type: Black, Num: 35, Ko: L3
 	// Move[35]: Loc: Q5, Type: White, Num: 36,
 }
+
+func ExampleGameInfoIndex_Query() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	dir := OutDir + "/gameindex"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir+"/1950s", os.ModeDir|os.ModePerm)
+	games := map[string]string{
+		"1950s/a.sgf": "(;FF[4]GM[1]SZ[19]PB[Go Seigen]BR[9d]PW[Fujisawa Kuranosuke]WR[9d]DT[1951-06-12]RE[B+2]KM[0];B[pd];W[dp];B[pq])",
+		"1950s/b.sgf": "(;FF[4]GM[1]SZ[19]PB[Fujisawa Kuranosuke]PW[Go Seigen]DT[1952]RE[W+R]KM[0];B[pd];W[dd])",
+		"c.sgf":       "(;FF[4]GM[1]SZ[19]PB[Go Seigen]PW[Takagawa Kaku]DT[1949-11-03]RE[B+R]HA[2];W[pd])",
+		// a collection of two games
+		"d.sgf": "(;FF[4]GM[1]SZ[19]PB[Go Seigen]PW[Hashimoto Utaro]DT[1953]RE[B+R];B[pd])" +
+			"(;FF[4]GM[1]SZ[9]PB[Sakata Eio]PW[Go Seigen]DT[1955]RE[W+R];B[ee];W[ce])",
+		// a game with an error, which is reported, and indexed
+		"e.sgf": "(;FF[4]GM[6]SZ[19]PB[Kitani Minoru]PW[Go Seigen]DT[1954];B[pd])",
+		// a DT written in words, which is compared as 1934-09
+		"f.SGF": "(;FF[4]GM[1]SZ[19]PB[Kitani Minoru]PW[Go Seigen]DT[Autumn 1934];B[pd])",
+	}
+	for f, g := range games {
+		ioutil.WriteFile(dir+"/"+f, []byte(g), 0644)
+	}
+	idx, errL := sgf.BuildGameInfoIndex(dir)
+	if len(errL) != 0 {
+		fmt.Println("Error while indexing:", errL.Error())
+	}
+	for _, q := range []string{
+		`DT>=1953`,
+		`DT<1951-07`,
+		`PB="Go Seigen" AND DT>=1950 AND RE~"B+"`,
+		`(PB="Go Seigen" OR PW="Go Seigen") AND NOT DT=1952`,
+		`HA>=2 OR MOVES>2`,
+		`PB="Go Seigen" AND`,
+		`XX=1`,
+	} {
+		fmt.Println("Query:", q)
+		recs, errL := idx.Query(q)
+		if len(errL) != 0 {
+			fmt.Println(errL.Error())
+		}
+		for _, r := range recs {
+			fmt.Println(" ", r.File, r.Game, r.PB, "vs.", r.PW, r.DT, r.RE, r.Moves, "moves")
+		}
+	}
+	// change one file, remove another, and update the saved index
+	errW := idx.WriteIndex(dir + "/games.idx")
+	if errW != nil {
+		fmt.Println(errW)
+		return
+	}
+	ioutil.WriteFile(dir+"/c.sgf", []byte("(;FF[4]GM[1]SZ[19]PB[Go Seigen]PW[Takagawa Kaku]DT[1949-11-03]RE[B+R]HA[2];W[pd];B[dp])"), 0644)
+	os.Remove(dir + "/1950s/b.sgf")
+	idx2, errR := sgf.ReadGameInfoIndex(dir + "/games.idx")
+	if errR != nil {
+		fmt.Println(errR)
+		return
+	}
+	added, changed, removed, errL := idx2.Update()
+	fmt.Println("Update:", added, "added,", changed, "changed,", removed, "removed", errL.Error())
+	recs, _ := idx2.Query(`FILE~c.sgf`)
+	for _, r := range recs {
+		fmt.Println(" ", r.File, r.HA, "handicap", r.Moves, "moves")
+	}
+	// Output:
+	// not in range 1-5 or 7-15: 6
+	// Error while indexing: testout/gameindex/e.sgf: not in range 1-5 or 7-15: 6
+	// Query: DT>=1953
+	//   d.sgf 0 Go Seigen vs. Hashimoto Utaro 1953 B+R 1 moves
+	//   d.sgf 1 Sakata Eio vs. Go Seigen 1955 W+R 2 moves
+	//   e.sgf 0 Kitani Minoru vs. Go Seigen 1954  1 moves
+	// Query: DT<1951-07
+	//   1950s/a.sgf 0 Go Seigen vs. Fujisawa Kuranosuke 1951-06-12 B+2 3 moves
+	//   c.sgf 0 Go Seigen vs. Takagawa Kaku 1949-11-03 B+R 1 moves
+	//   f.SGF 0 Kitani Minoru vs. Go Seigen Autumn 1934  1 moves
+	// Query: PB="Go Seigen" AND DT>=1950 AND RE~"B+"
+	//   1950s/a.sgf 0 Go Seigen vs. Fujisawa Kuranosuke 1951-06-12 B+2 3 moves
+	//   d.sgf 0 Go Seigen vs. Hashimoto Utaro 1953 B+R 1 moves
+	// Query: (PB="Go Seigen" OR PW="Go Seigen") AND NOT DT=1952
+	//   1950s/a.sgf 0 Go Seigen vs. Fujisawa Kuranosuke 1951-06-12 B+2 3 moves
+	//   c.sgf 0 Go Seigen vs. Takagawa Kaku 1949-11-03 B+R 1 moves
+	//   d.sgf 0 Go Seigen vs. Hashimoto Utaro 1953 B+R 1 moves
+	//   d.sgf 1 Sakata Eio vs. Go Seigen 1955 W+R 2 moves
+	//   e.sgf 0 Kitani Minoru vs. Go Seigen 1954  1 moves
+	//   f.SGF 0 Kitani Minoru vs. Go Seigen Autumn 1934  1 moves
+	// Query: HA>=2 OR MOVES>2
+	//   1950s/a.sgf 0 Go Seigen vs. Fujisawa Kuranosuke 1951-06-12 B+2 3 moves
+	//   c.sgf 0 Go Seigen vs. Takagawa Kaku 1949-11-03 B+R 1 moves
+	// Query: PB="Go Seigen" AND
+	// query: expected a field name, at 18
+	// Query: XX=1
+	// query: unknown field "XX", at 0
+	// Update: 0 added, 1 changed, 1 removed no errors
+	//   c.sgf 2 handicap 2 moves
+}
+
+func ExampleDupDetector_Clusters() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	games := []struct{ file, sgf string }{
+		{"a.sgf", "(;FF[4]SZ[19]PB[Go Seigen]PW[Kitani Minoru]DT[1933-10-16]RE[B+2];B[pd];W[dp];B[pq];W[dd];B[fq];W[cn];B[qk];W[jd])"},
+		// rotated, with the colors reversed, and the names misspelled
+		{"b.sgf", "(;FF[4]SZ[19]PB[Kitani Minoru]PW[Seigen Go]DT[1933];W[dd];B[pp];W[qd];B[dp];W[qn];B[nq];W[kc];B[dj])"},
+		// flipped, and only the first 5 moves
+		{"c.sgf", "(;FF[4]SZ[19]PB[Go Seigen]PW[Kitani];B[dd];W[pp];B[dq];W[pd];B[nq])"},
+		// 6 moves in common, then a different ending, with matching and other headers
+		{"e.sgf", "(;FF[4]SZ[19]PB[Go Seigen]PW[Kitani Minoru];B[pd];W[dp];B[pq];W[dd];B[fq];W[cn];B[cq];W[dq])"},
+		{"f.sgf", "(;FF[4]SZ[19]PB[Honinbo Shusai]PW[Go Seigen];B[pd];W[dp];B[pq];W[dd];B[fq];W[cn];B[qp];W[jj])"},
+		// a short game, and a copy with the S property
+		{"g.sgf", "(;FF[4]SZ[19]PB[Sakata Eio];B[qd];W[dc];B[cp])"},
+		{"h.sgf", "(;FF[4]SZ[19];S[qddccp])"},
+		// a game, and a prefix in the same orientation, which is symmetric
+		{"k.sgf", "(;FF[4]SZ[19]PB[Cho Chikun]PW[Kobayashi Koichi];B[jj];W[dd];B[pp];W[cc];B[pd];W[dp])"},
+		{"l.sgf", "(;FF[4]SZ[19]PB[Cho Chikun]PW[Kobayashi Koichi];B[jj];W[dd];B[pp];W[cc])"},
+		// a collection, with a copy of g.sgf as its second game
+		{"m.sgf", "(;FF[4]SZ[19]PB[Otake Hideo];B[cc];W[dd];B[ee];W[ff])(;FF[4]SZ[19]PB[Sakata Eio];B[qd];W[dc];B[cp])"},
+	}
+	dd := sgf.DupDetector{MinMoves: 4}
+	for _, g := range games {
+		prsr, errL := sgf.ParseFile(g.file, []byte(g.sgf), 0, 0)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", g.file, errL.Error())
+			return
+		}
+		for i := 0; i < prsr.NumGames(); i++ {
+			prsr.SelectGame(i)
+			dd.AddGame(g.file, &prsr.GameTree)
+		}
+	}
+	for _, cl := range dd.Clusters() {
+		fmt.Println("Cluster, keep", cl.Games[0].File, "("+cl.Reason+")")
+		for _, d := range cl.Games[1:] {
+			fmt.Println(" ", d.File, d.Game, sgf.DupKindNames[d.Kind], d.Moves, "moves,", d.Common, "in common,",
+				ah.TransName[d.Trans], "swapped", d.Swapped, "headers match", d.HeadersMatch)
+		}
+	}
+	// Output:
+	// Cluster, keep a.sgf (most moves)
+	//   b.sgf 0 exact 8 moves, 8 in common, T_ROTA_270 swapped true headers match true
+	//   c.sgf 0 prefix 5 moves, 5 in common, T_FLP_VERT swapped false headers match true
+	//   e.sgf 0 diverges 8 moves, 6 in common, T_IDENTITY swapped false headers match true
+	// Cluster, keep g.sgf (most complete game-info)
+	//   h.sgf 0 exact 3 moves, 3 in common, T_IDENTITY swapped false headers match true
+	//   m.sgf 1 exact 3 moves, 3 in common, T_IDENTITY swapped false headers match true
+	// Cluster, keep k.sgf (most moves)
+	//   l.sgf 0 prefix 4 moves, 4 in common, T_IDENTITY swapped false headers match true
+}
+
+func ExampleDBStatistics_Merge() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	games := []string{
+		"(;FF[4]GM[1]SZ[19]PB[Go Seigen]BR[5d]PW[Kitani Minoru]WR[5d]RE[B+2]RU[Japanese];B[pd];W[dp])",
+		"(;FF[4]GM[1]SZ[19]PB[Kitani Minoru]BR[6d]PW[Go Seigen]WR[6d]RE[W+R]RU[Japanese];B[pd];W[dp])",
+		"(;FF[4]GM[1]SZ[19]PB[Go Seigen]BR[9d]PW[Sakata Eio]WR[9d]RE[B+R]HA[2];W[pd])",
+	}
+	// each worker collects its own statistics, which are merged in order
+	workers := make([]*sgf.DBStatistics, len(games))
+	done := make(chan bool)
+	for i, g := range games {
+		workers[i] = sgf.NewDBStatistics()
+		go func(i int, g string) {
+			_, errL := sgf.ParseFileStats("game"+strconv.Itoa(i+1)+".sgf", []byte(g), 0, 0, workers[i])
+			if len(errL) != 0 {
+				fmt.Println("Error while parsing:", errL.Error())
+			}
+			done <- true
+		}(i, g)
+	}
+	for range games {
+		<-done
+	}
+	all := sgf.NewDBStatistics()
+	for _, w := range workers {
+		all.Merge(w)
+	}
+	errW := all.WriteCSV(os.Stdout)
+	if errW != nil {
+		fmt.Println(errW)
+	}
+	errW = workers[2].WriteJSON(os.Stdout)
+	if errW != nil {
+		fmt.Println(errW)
+	}
+	// Output:
+	// table,value,count,first game,first rank,last game,last rank
+	// Property,BR,3,,,,
+	// Property,FF,3,,,,
+	// Property,GM,3,,,,
+	// Property,PB,3,,,,
+	// Property,PW,3,,,,
+	// Property,RE,3,,,,
+	// Property,SZ,3,,,,
+	// Property,W,3,,,,
+	// Property,WR,3,,,,
+	// Property,B,2,,,,
+	// Property,RU,2,,,,
+	// Property,HA,1,,,,
+	// Handicap,2,1,,,,
+	// Result,B+2,1,,,,
+	// Result,B+R,1,,,,
+	// Result,W+R,1,,,,
+	// Rules,Japanese,2,,,,
+	// Rank,5d,2,,,,
+	// Rank,6d,2,,,,
+	// Rank,9d,2,,,,
+	// Player,Go Seigen,3,game1,5d,game3,9d
+	// Player,Kitani Minoru,2,game1,5d,game2,6d
+	// Player,Sakata Eio,1,game3,9d,game3,9d
+	// {"FirstBRankNotSet":0,"FirstWRankNotSet":0,"Handicap":{"2":1},"OldHandicap":{},"Players":{"Go Seigen":{"NGames":1,"FirstGame":"game3","FirstRank":"9d","FirstDate":"","LastGame":"game3","LastRank":"9d","LastDate":"","LowRank":"9d","HighRank":"9d"},"Sakata Eio":{"NGames":1,"FirstGame":"game3","FirstRank":"9d","FirstDate":"","LastGame":"game3","LastRank":"9d","LastDate":"","LowRank":"9d","HighRank":"9d"}},"Properties":{"BR":1,"FF":1,"GM":1,"HA":1,"PB":1,"PW":1,"RE":1,"SZ":1,"W":1,"WR":1},"Rank":{"9d":2},"Result":{"B+R":1},"ResultComment":{},"Rules":{}}
+}
+
+func ExamplePlayerAliases_UnresolvedAliases() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	pa, errA := sgf.ReadPlayerAliases("../sgf/sgf_player_aliases.txt")
+	if errA != nil {
+		fmt.Println(errA)
+		return
+	}
+	fmt.Println(pa.Resolve("Wu Qingyuan"), "|", pa.Resolve("go seigen 9p"), "|", pa.Resolve("Kitani  Minoru 7 dan"))
+	games := []string{
+		"(;FF[4]GM[1]SZ[19]PB[Go Seigen]BR[5d]PW[Kitani Minoru]WR[5d];B[pd])",
+		"(;FF[4]GM[1]SZ[19]PB[Kitani Minoru]BR[6d]PW[Wu Qingyuan]WR[6d];B[pd])",
+		"(;FF[4]GM[1]SZ[19]PB[Minoru Kitani]BR[7d]PW[Go Seigen 9p];B[pd])",
+		"(;FF[4]GM[1]SZ[19]PB[Kitani Minorou]PW[Cho Chikun Meijin];B[pd])",
+	}
+	stats := sgf.NewDBStatistics()
+	stats.Aliases = pa
+	for i, g := range games {
+		_, errL := sgf.ParseFileStats("game"+strconv.Itoa(i+1)+".sgf", []byte(g), 0, 0, stats)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", errL.Error())
+		}
+	}
+	for _, a := range stats.UnresolvedAliases() {
+		fmt.Printf("%s (%d games): %s? %s\n", a.Name, a.Games, a.Suggest, a.Reason)
+	}
+	// Output:
+	// Go Seigen | Go Seigen | Kitani Minoru
+	// Cho Chikun Meijin (1 games): Cho Chikun? part of name
+	// Kitani Minorou (1 games): Kitani Minoru? similar spelling
+	// Minoru Kitani (1 games): Kitani Minoru? same words
+}
+
+func ExamplePlayerAliases_IsSuspect() {
+	pa, errA := sgf.ReadPlayerAliases("../sgf/sgf_player_aliases.txt")
+	if errA != nil {
+		fmt.Println(errA)
+		return
+	}
+	var none *sgf.PlayerAliases
+	for _, name := range []string{"artu", "Anon.", "Go Seigen"} {
+		fmt.Println(name, pa.IsSuspect(name), none.IsSuspect(name))
+	}
+	// Output:
+	// artu true true
+	// Anon. true true
+	// Go Seigen false false
+}
+func ExamplePlayerInfo_dates() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// the files are not in the order of their dates
+	games := []string{
+		"(;FF[4]GM[1]SZ[19]DT[1939-09-28,29]PB[Go Seigen]BR[7d]PW[Kitani Minoru]WR[7d];B[pd])",
+		"(;FF[4]GM[1]SZ[19]DT[1933-10-16]PB[Kitani Minoru]BR[5d]PW[Go Seigen]WR[5d];B[pd])",
+		"(;FF[4]GM[1]SZ[19]PB[Go Seigen]PW[Kitani Minoru];B[pd])",
+		"(;FF[4]GM[1]SZ[19]DT[Autumn 1934]PB[Kitani Minoru]BR[6d]PW[Go Seigen]WR[5d];B[pd])",
+	}
+	stats := sgf.NewDBStatistics()
+	for i, g := range games {
+		_, errL := sgf.ParseFileStats("game"+strconv.Itoa(i+1)+".sgf", []byte(g), 0, 0, stats)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", errL.Error())
+		}
+	}
+	for _, name := range []string{"Go Seigen", "Kitani Minoru"} {
+		pi := stats.BWPlayer_map[name]
+		fmt.Printf("%s: %d games, first: %s %s %s, last: %s %s %s\n", name, pi.NGames,
+			pi.FirstGame, pi.FirstRank, pi.FirstDate, pi.LastGame, pi.LastRank, pi.LastDate)
+	}
+	// Output:
+	// BAD Property Value: game4.sgf:1:34: DT[Autumn 1934] check value: Autumn 1934 (must be 1934-09,10,11)
+	// Go Seigen: 4 games, first: game2 5d 1933-10-16, last: game1 7d 1939-09-28
+	// Kitani Minoru: 4 games, first: game2 5d 1933-10-16, last: game1 7d 1939-09-28
+}
+
+func ExampleGameTree_WriteTimeUsage() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// 60 seconds main time, then 3 periods of 10 seconds
+	game := "(;FF[4]GM[1]SZ[9]TM[60]OT[3x10 byo-yomi]" +
+		";B[ee]BL[55];W[cc]WL[50.5];B[gg]BL[20];W[cg]WL[2];B[gc]BL[6]OB[3];W[ge]WL[8]OW[2]" +
+		";B[ce]BL[4]OB[3];W[ec]WL[9]OW[1])"
+	prsr, errL := sgf.ParseFile("time.sgf", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	errW := prsr.GameTree.WriteTimeUsage(os.Stdout)
+	if errW != nil {
+		fmt.Println(errW)
+	}
+	// Output:
+	// Time control: 1m, 3x10s byo-yomi
+	// Move Color     Used     Left Stones
+	//    1     B      5.0     55.0
+	//    2     W      9.5     50.5
+	//    3     B     35.0     20.0
+	//    4     W     48.5      2.0
+	//    5     B     24.0      6.0      3
+	//    6     W     14.0      8.0      2
+	//    7     B      6.0      4.0      3
+	//    8     W     11.0      9.0      1
+	// Black: 4 moves, 70.0 seconds, 17.5 per move
+	// White: 4 moves, 83.0 seconds, 20.8 per move
+}
+
+func ExampleGameTree_ScoreGame() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// Black has the left side, with a dead white stone at ab, and White the right side.
+	game := "(;FF[4]GM[1]SZ[5]KM[0.5]RU[Japanese]RE[B+5.5]" +
+		"AB[ca][cb][cc][cd][ce]AW[da][db][dc][dd][de][ab]" +
+		";B[];W[]TB[aa][ba][ab][bb][ac][bc][ad][bd][ae][be]TW[ea][eb][ec][ed][ee])"
+	prsr, errL := sgf.ParseFile("score.sgf", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	gamT := &prsr.GameTree
+	sc, errL := gamT.ScoreGame(nil)
+	fmt.Println("marked:", sc, errL)
+	sc, _ = gamT.ScoreGameWith(sgf.ScoreArea, nil)
+	fmt.Println("area:", sc)
+	ab, _ := sgf.SGFPoint([]byte("ab"))
+	sc, _ = gamT.ScoreGame([]ah.NodeLoc{ab})
+	fmt.Println("dead ab:", sc, "dead:", sc.WhiteDead)
+	sc, _ = gamT.ScoreGame([]ah.NodeLoc{})
+	fmt.Println("no dead:", sc)
+	fmt.Printf("CheckProperties: %q\n", gamT.CheckProperties(false))
+	// The same game, scored by Chinese rules.
+	chinese := strings.Replace(game, "Japanese", "Chinese", 1)
+	prsr, errL = sgf.ParseFile("chinese.sgf", []byte(chinese), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	fmt.Printf("CheckProperties: %q\n", prsr.GameTree.CheckProperties(false))
+
+	// The games, in a data base.
+	dir := OutDir + "/scores"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, os.ModeDir|os.ModePerm)
+	ioutil.WriteFile(dir+"/japanese.sgf", []byte(game), 0644)
+	ioutil.WriteFile(dir+"/chinese.sgf", []byte(chinese), 0644)
+	// a collection, whose second game is scored
+	ioutil.WriteFile(dir+"/collection.sgf", []byte("(;FF[4]GM[1]SZ[5]RE[W+R];B[cc];W[dd])"+chinese), 0644)
+	mismatches, checked, errL := sgf.CheckScores(dir)
+	fmt.Println("checked:", checked, errL)
+	for _, m := range mismatches {
+		fmt.Println(m.File, m.Game, "RE:", m.RE, "score:", m.Score)
+	}
+	// Output:
+	// marked: territory B 11, W 5+0.5: B+5.5 no errors
+	// area: area B 15, W 10+0.5: B+4.5
+	// dead ab: territory B 11, W 5+0.5: B+5.5 dead: 1
+	// no dead: territory B 0, W 5+0.5: W+5.5
+	// CheckProperties: "AB not equal AW (mov1 not set)"
+	// CheckProperties: "AB not equal AW (mov1 not set)RE not equal score of final position "
+	// checked: 3 no errors
+	// chinese.sgf 0 RE: B+5.5 score: area B 15, W 10+0.5: B+4.5
+	// collection.sgf 1 RE: B+5.5 score: area B 15, W 10+0.5: B+4.5
+}
+
+func ExampleParser_MoveDiagnostics() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// B[cb] takes a ko. The first variation retakes it at once, and the second
+	// fills it, plays on an occupied point, and then W[aa] is a suicide.
+	game := "(;FF[4]GM[1]SZ[5]RU[Japanese]AB[ba][ab][bc]AW[ca][db][cc][bb]" +
+		";B[cb](;W[bb])(;W[ee];B[bb];W[ba];W[aa]))"
+	for _, rs := range []sgf.RuleSet{sgf.RulesFromRU, sgf.RulesChinese, sgf.RulesNewZealand, sgf.RulesTrompTaylor} {
+		prsr, errL := sgf.ParseFileRules("ko.sgf", []byte(game), sgf.ParserPlay, 0, rs)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", errL.Error())
+			return
+		}
+		fmt.Println(sgf.RuleSetNames[rs]+":", len(prsr.MoveDiagnostics()), "illegal moves")
+		for _, d := range prsr.MoveDiagnostics() {
+			fmt.Println("   ", d)
+		}
+	}
+	// RU, after the setup stones, is used for the moves.
+	late := "(;FF[4]GM[1]SZ[5]AB[ba][ab][bc]AW[ca][db][cc][bb]RU[NZ]" +
+		";B[cb](;W[bb])(;W[ee];B[bb];W[ba];W[aa]))"
+	prsr, errL := sgf.ParseFileRules("late.sgf", []byte(late), sgf.ParserPlay, 0, sgf.RulesFromRU)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	fmt.Println("late RU:", len(prsr.MoveDiagnostics()), "illegal moves")
+	// RU in a variation applies only to it.
+	inVar := "(;FF[4]GM[1]SZ[5]AB[ba][ab][bc]AW[ca][db][cc][bb]" +
+		";B[cb](;RU[NZ]W[ee];B[bb];W[aa])(;W[ee];B[bb];W[aa]))"
+	prsr, errL = sgf.ParseFileRules("variation.sgf", []byte(inVar), sgf.ParserPlay, 0, sgf.RulesFromRU)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	for _, d := range prsr.MoveDiagnostics() {
+		fmt.Println("variation RU:", d)
+	}
+	// Output:
+	// RU: 3 illegal moves
+	//     illegal move 2 W[bb] at node 4: ko (Japanese rules)
+	//     illegal move 4 W[ba] at node 7: occupied point (Japanese rules)
+	//     illegal move 5 W[aa] at node 8: suicide (Japanese rules)
+	// Chinese: 3 illegal moves
+	//     illegal move 2 W[bb] at node 4: ko (Chinese rules)
+	//     illegal move 4 W[ba] at node 7: occupied point (Chinese rules)
+	//     illegal move 5 W[aa] at node 8: suicide (Chinese rules)
+	// NZ: 2 illegal moves
+	//     illegal move 2 W[bb] at node 4: ko (NZ rules)
+	//     illegal move 4 W[ba] at node 7: occupied point (NZ rules)
+	// Tromp-Taylor: 3 illegal moves
+	//     illegal move 2 W[bb] at node 4: ko (Tromp-Taylor rules)
+	//     illegal move 4 W[ba] at node 7: occupied point (Tromp-Taylor rules)
+	//     illegal move 5 W[aa] at node 8: superko (Tromp-Taylor rules)
+	// late RU: 2 illegal moves
+	// variation RU: illegal move 4 W[aa] at node 9: suicide (Japanese rules)
+}
+
+func ExampleGameTree_PositionHash() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	parse := func(game string, mode sgf.ParserMode) *sgf.GameTree {
+		prsr, errL := sgf.ParseFile("hash.sgf", []byte(game), mode, 0)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", errL.Error())
+			return nil
+		}
+		return &prsr.GameTree
+	}
+	// Nodes 0, 1, and 2 are the root, collection, and game-info nodes.
+	// The two variations reach the same position, at nodes 6 and 8.
+	game := "(;FF[4]GM[1]SZ[9];B[cc];W[gg](;B[cg];W[gc])(;B[gc];W[cg]))"
+	played := parse(game, sgf.ParserPlay)
+	replayed := parse(game, 0)
+	if played == nil || replayed == nil {
+		return
+	}
+	fmt.Println("transposition:", played.PositionHash(6) == played.PositionHash(8))
+	same := true
+	for n := sgf.TreeNodeIdx(0); n <= 8; n++ {
+		same = same && played.PositionHash(n) == replayed.PositionHash(n)
+	}
+	fmt.Println("ParserPlay, and replayed:", same)
+
+	// The same position, reflected, and set up with White to move.
+	mirror := parse("(;FF[4]GM[1]SZ[9];B[gc];W[cc];B[gg];W[cg])", sgf.ParserPlay)
+	setup := parse("(;FF[4]GM[1]SZ[9]AB[cc][cg]AW[gg][gc]PL[B])", 0)
+	setupW := parse("(;FF[4]GM[1]SZ[9]AB[cc][cg]AW[gg][gc]PL[W])", 0)
+	if mirror == nil || setup == nil || setupW == nil {
+		return
+	}
+	fmt.Println("reflected:", played.PositionHash(6) == mirror.PositionHash(6))
+	fmt.Println("setup, Black to move:", played.PositionHash(6) == setup.PositionHash(2))
+	fmt.Println("setup, White to move:", played.PositionHash(6) == setupW.PositionHash(2))
+	fmt.Println("empty board:", played.PositionHash(2))
+
+	// The hashes follow a change of the setup.
+	added := parse("(;FF[4]GM[1]SZ[9]AB[cc][cg][ee]AW[gg][gc]PL[B])", 0)
+	if added == nil {
+		return
+	}
+	ee, _ := sgf.SGFPoint([]byte("ee"))
+	errL := setup.AddAProp(2, sgf.PropertyValue{PropType: sgf.AB_idx, ValType: sgf.Point, StrValue: sgf.SGFCoords(ee, true)})
+	fmt.Println("setup changed:", setup.PositionHash(2) == added.PositionHash(2), errL)
+
+	// Nodes added after parsing are replayed in the order of the tree, not of their indexes:
+	// the new node 9 is a variation of node 3, and the new node 10 continues from node 6.
+	longer := parse("(;FF[4]GM[1]SZ[9];B[cc];W[gg];B[cg];W[gc];B[ee])", 0)
+	if longer == nil {
+		return
+	}
+	gc, _ := sgf.SGFPoint([]byte("gc"))
+	n9, _ := replayed.AddChild(3, sgf.InteriorNode, 0)
+	replayed.AddAProp(n9, sgf.PropertyValue{PropType: sgf.W_idx, ValType: sgf.Move, StrValue: sgf.SGFCoords(gc, true)})
+	n10, _ := replayed.AddChild(6, sgf.InteriorNode, 0)
+	replayed.AddAProp(n10, sgf.PropertyValue{PropType: sgf.B_idx, ValType: sgf.Move, StrValue: sgf.SGFCoords(ee, true)})
+	fmt.Println("nodes added:", n9, n10, replayed.PositionHash(n10) == longer.PositionHash(7))
+	// Output:
+	// transposition: true
+	// ParserPlay, and replayed: true
+	// reflected: true
+	// setup, Black to move: true
+	// setup, White to move: false
+	// empty board: 0
+	// setup changed: true no errors
+	// nodes added: 9 10 true
+}
+
+func ExampleGameTree_GetHandicapSetup() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	coords := func(pts []ah.NodeLoc) string {
+		var s []string
+		for _, nl := range pts {
+			s = append(s, string(sgf.SGFCoords(nl, true)))
+		}
+		return strings.Join(s, " ")
+	}
+	fmt.Println("4 on 19x19:", coords(sgf.HandicapPoints(4, 19, 19)))
+	fmt.Println("5 on 19x13:", coords(sgf.HandicapPoints(5, 19, 13)))
+	fmt.Println("10 on 19x19:", sgf.HandicapPoints(10, 19, 19) == nil)
+	games := []string{
+		// the fixed points, in another symmetry
+		"(;FF[4]GM[1]SZ[19]HA[3]RU[Japanese]AB[dp][pd][dd];W[pp])",
+		// free placement, not allowed by AGA rules
+		"(;FF[4]GM[1]SZ[19]HA[2]RU[AGA]AB[dd][qq];W[pp])",
+		// free placement, played as moves, by Chinese rules
+		"(;FF[4]GM[1]SZ[19]HA[3]RU[Chinese];B[dd];W[];B[qq];W[];B[dq];W[qd])",
+		// handicap moves, without HA
+		"(;FF[4]GM[1]SZ[19];B[dd];W[];B[pp];W[pd])",
+		// Black passes, to give White the first move
+		"(;FF[4]GM[1]SZ[9]HA[2]RU[AGA]AB[gc][cg];B[];W[ee])",
+		// more than 9 stones have no fixed points
+		"(;FF[4]GM[1]SZ[19]HA[10]RU[Japanese]AB[dd][jd][pd][dj][jj][pj][dp][jp][pp][cc];W[qc])",
+	}
+	for i, g := range games {
+		prsr, errL := sgf.ParseFile("handicap.sgf", []byte(g), 0, 0)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", errL.Error())
+			return
+		}
+		gamT := &prsr.GameTree
+		hs := gamT.GetHandicapSetup()
+		fmt.Printf("%d: %s, moves %v %d pass %v fixed %v\n", i, coords(hs.Stones),
+			hs.FromMoves, hs.Moves, hs.PassFirst, hs.Fixed)
+		fmt.Printf("   CheckProperties: %q\n", gamT.CheckProperties(false))
+	}
+	fmt.Println("compensation:", sgf.RulesChinese.HandicapCompensation(3),
+		sgf.RulesAGA.HandicapCompensation(3), sgf.RulesJapanese.HandicapCompensation(3))
+	// Output:
+	// 4 on 19x19: pd dp pp dd
+	// 5 on 19x13: pd dj pj dd jg
+	// 10 on 19x19: true
+	// 0: dp pd dd, moves false 0 pass false fixed true
+	//    CheckProperties: ""
+	// 1: dd qq, moves false 0 pass false fixed false
+	//    CheckProperties: "handicap not on fixed points for AGA rules "
+	// 2: dd qq dq, moves true 5 pass false fixed false
+	//    CheckProperties: ""
+	// 3: dd pp, moves true 3 pass false fixed true
+	//    CheckProperties: "handicap moves, but no HA "
+	// 4: gc cg, moves false 0 pass true fixed true
+	//    CheckProperties: ""
+	// 5: dd jd pd dj jj pj dp jp pp cc, moves false 0 pass false fixed false
+	//    CheckProperties: ""
+	// compensation: 3 2 0
+}
+
+func ExampleGameTree_GetMarkup() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// The second node has a point with two marks, and an arrow of length zero.
+	game := "(;FF[4]GM[1]SZ[5]CR[aa:bb]LB[dd:B][cc:A]AR[aa:cc]LN[ee:ac]DD[ea]" +
+		";B[cc]TR[dd]SQ[dd]AR[ab:ab];W[bd]DD[])"
+	prsr, errL := sgf.ParseFile("markup.sgf", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	gamT := &prsr.GameTree
+	for n := sgf.TreeNodeIdx(2); n <= 4; n++ {
+		mk, errL := gamT.GetMarkup(n)
+		fmt.Printf("node %d: %s, dimmed %d, errors %d\n", n, mk, len(gamT.DimmedAt(n)), len(errL))
+	}
+	pt := func(s string) ah.NodeLoc {
+		nl, _ := sgf.SGFPoint([]byte(s))
+		return nl
+	}
+	mk, _ := gamT.GetMarkup(2)
+	fmt.Println("mark aa:", len(mk.Mark(pt("aa"), sgf.MarkSquare)))
+	fmt.Println("arrow aa:cc:", len(mk.AddArrow(pt("aa"), pt("cc"))))
+	fmt.Println("line ac:ee:", len(mk.AddLine(pt("ac"), pt("ee"))))
+	mk.Mark(pt("cc"), sgf.MarkCross)
+	mk.Unmark(pt("ab"))
+	mk.Unlabel(pt("dd"))
+	mk.AddArrow(pt("cc"), pt("aa"))
+	mk.RemoveLine(pt("ac"), pt("ee"))
+	mk.SetView([]ah.NodeLoc{pt("aa"), pt("bb"), pt("ab"), pt("ba")})
+	fmt.Println("set:", len(gamT.SetMarkup(2, mk)))
+	mk.Mark(pt("ff"), sgf.MarkCircle)
+	fmt.Println("off the board:", len(gamT.SetMarkup(2, mk)))
+	mk, _ = gamT.GetMarkup(2)
+	fmt.Println("node 2:", mk)
+	view, part := gamT.ViewAt(4)
+	fmt.Println("view at node 4:", len(view), part)
+
+	os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+	fileName := OutDir + "/markup.sgf"
+	if err := gamT.WriteFile(fileName, sgf.DefaultNumPerLine); err != nil {
+		fmt.Println("Error writing:", err)
+		return
+	}
+	out, _ := ioutil.ReadFile(fileName)
+	fmt.Println(strings.TrimSpace(string(out)))
+
+	// The text of a label is escaped: "]" and "\\" do not end it.
+	prsr, errL = sgf.ParseFile("labels.sgf", []byte(`(;FF[4]GM[1]SZ[5]LB[aa:x\]][bb:\\])`), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	mk, errL = prsr.GameTree.GetMarkup(2)
+	fmt.Printf("labels: %q %q, errors %d\n", mk.Labels[pt("aa")], mk.Labels[pt("bb")], len(errL))
+	mk.Label(pt("cc"), "[y]")
+	prsr.GameTree.SetMarkup(2, mk)
+	mk, _ = prsr.GameTree.GetMarkup(2)
+	fmt.Println("node 2:", mk)
+	// Output:
+	// BAD Property Value: markup.sgf:1:92: AR[ab:ab] DoAR: ab:ab has length zero
+	// node 2: AR[aa:cc]CR[aa][ab][ba][bb]DD[ea]LB[cc:A][dd:B]LN[ee:ac], dimmed 1, errors 0
+	// node 3: SQ[dd], dimmed 1, errors 2
+	// node 4: DD[], dimmed 0, errors 0
+	// mark aa: 1
+	// arrow aa:cc: 1
+	// line ac:ee: 1
+	// set: 0
+	// off the board: 1
+	// node 2: AR[aa:cc][cc:aa]CR[aa][ba][bb]DD[ea]LB[cc:A]MA[cc]VW[aa][ab][ba][bb]
+	// view at node 4: 4 true
+	// (;FF[4]GM[1]
+	// SZ[5]
+	// AR[aa:cc][cc:aa]
+	// CR[aa][ba][bb]
+	// DD[ea]
+	// LB[cc:A]
+	// MA[cc]
+	// VW[aa][ab][ba][bb]
+	// ;B[cc]TR[dd]SQ[dd]AR[ab:ab];W[bd]DD[]
+	// )
+	// labels: "x]" "\\", errors 0
+	// node 2: LB[aa:x\]][bb:\\][cc:[y\]]
+}
+
+func ExampleGameTree_MoveQuality() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// Node 4 has both BM and TE, which FF[4] does not allow.
+	game := "(;FF[4]GM[1]SZ[9]GB[1];B[ee]TE[2]V[1.5];W[cc]BM[1]TE[1]UC[1];B[gc]DO[]HO[2])"
+	prsr, errL := sgf.ParseFile("annotations.sgf", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	gamT := &prsr.GameTree
+	for _, a := range gamT.FindAnnotations(nil) {
+		fmt.Printf("node %d: move %s %d, position %s %d, hotspot %d, value %v %v\n", a.Node,
+			sgf.MoveAnnotationNames[a.Move], a.MoveEmphasis,
+			sgf.PositionAnnotationNames[a.Position], a.PositionEmphasis, a.Hotspot, a.Value, a.ValueSet)
+	}
+	fmt.Println("node 4:", gamT.CheckAnnotations(4))
+	fmt.Printf("CheckProperties: %q\n", gamT.CheckProperties(false))
+
+	// The setters replace the annotations that exclude each other.
+	fmt.Println("set:", len(gamT.SetMoveQuality(4, sgf.Doubtful, 0)), len(gamT.SetPositionJudgement(4, sgf.GoodForWhite, 2)))
+	fmt.Println("no move:", gamT.SetMoveQuality(2, sgf.Tesuji, 1))
+	fmt.Println("emphasis:", gamT.SetMoveQuality(3, sgf.BadMove, 3))
+	gamT.SetMoveQuality(5, sgf.MoveNotAnnotated, 0)
+	gamT.SetValue(5, -0.5)
+	q, emph := gamT.MoveQuality(4)
+	j, jEmph := gamT.PositionJudgement(4)
+	fmt.Println("node 4:", sgf.MoveAnnotationNames[q], emph, sgf.PositionAnnotationNames[j], jEmph, len(gamT.CheckAnnotations(4)))
+	good := gamT.FindAnnotations(func(a sgf.Annotation) bool {
+		return a.Position == sgf.GoodForBlack || a.Position == sgf.GoodForWhite
+	})
+	for _, a := range good {
+		fmt.Println("good at node", a.Node, sgf.PositionAnnotationNames[a.Position])
+	}
+	v, _ := gamT.Value(5)
+	fmt.Println("node 5 value:", v, "hotspot:", gamT.Hotspot(5))
+	// Output:
+	// node 2: move none 0, position good for Black 1, hotspot 0, value 0 false
+	// node 3: move tesuji 2, position none 0, hotspot 0, value 1.5 true
+	// node 4: move bad move 1, position unclear 1, hotspot 0, value 0 false
+	// node 5: move doubtful 1, position none 0, hotspot 2, value 0 false
+	// node 4: node 4: more than one of BM, DO, IT and TE
+	// CheckProperties: "annotations conflict at node 4 "
+	// set: 0 0
+	// no move: SetMoveQuality: node 2 has no move
+	// emphasis: SetMoveQuality: emphasis 3 not 1 or 2
+	// node 4: doubtful 1 good for White 2 0
+	// good at node 2 good for Black
+	// good at node 4 good for White
+	// node 5 value: -0.5 hotspot: 2
+}
+
+func ExampleGameTree_SetGameInfo() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	game := "(;FF[4]GM[1]SZ[19]PB[Honinbo Shusaku]PW[Gennan Inseki]BR[4d]GC[the ear-reddening move]" +
+		"DT[1846-09-11]RE[B+2];B[qd];W[dc])"
+	prsr, errL := sgf.ParseFile("gameinfo.sgf", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	gamT := &prsr.GameTree
+	fmt.Println("game-info nodes:", gamT.GameInfoNodes())
+	gi := gamT.GetGameInfo(2)
+	fmt.Printf("PB %q, PW %q, BR %q, DT %q, RE %q, KM %q\n", gi.PB, gi.PW, gi.BR, gi.DT, gi.RE, gi.KM)
+	gi.PB = "Shusaku"
+	gi.BR = ""
+	gi.GC = ""
+	gi.RE = "W+R"
+	gi.KM = "0"
+	gi.EV = "Castle game"
+	fmt.Println("set:", len(gamT.SetGameInfo(gi)))
+	res, _ := gamT.GetResult()
+	fmt.Printf("cached: PB %q, BR %q, RE %s\n", gamT.GetPB(), gamT.GetBR(), res)
+	gi.KM = "x"
+	fmt.Println("bad KM:", gamT.SetGameInfo(gi))
+	fmt.Printf("not changed: KM %q\n", gamT.GetGameInfo(2).KM)
+	gi = gamT.GetGameInfo(2)
+	gi.RE, gi.DT, gi.BR, gi.WR = "Holder", "Autumn", "4x", "7d"
+	fmt.Println("bad RE, DT, BR:", len(gamT.SetGameInfo(gi)))
+	fmt.Printf("not changed: RE %q, DT %q, BR %q, WR %q\n", gamT.GetGameInfo(2).RE, gamT.GetGameInfo(2).DT,
+		gamT.GetGameInfo(2).BR, gamT.GetGameInfo(2).WR)
+
+	os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+	fileName := OutDir + "/gameinfo.sgf"
+	if err := gamT.WriteFile(fileName, sgf.DefaultNumPerLine); err != nil {
+		fmt.Println("Error writing:", err)
+		return
+	}
+	out, _ := ioutil.ReadFile(fileName)
+	fmt.Println(strings.TrimSpace(string(out)))
+	// Output:
+	// game-info nodes: [2]
+	// PB "Honinbo Shusaku", PW "Gennan Inseki", BR "4d", DT "1846-09-11", RE "B+2", KM ""
+	// set: 0
+	// cached: PB "Shusaku", BR "", RE W+R
+	// bad KM: SetGameInfo: KM strconv.ParseFloat: parsing "x": invalid syntax
+	// not changed: KM "0"
+	// bad RE, DT, BR: 3
+	// not changed: RE "W+R", DT "1846-09-11", BR "", WR ""
+	// (;FF[4]GM[1]
+	// SZ[19]
+	// PW[Gennan Inseki]
+	// DT[1846-09-11]
+	// EV[Castle game]
+	// KM[0]
+	// PB[Shusaku]
+	// RE[W+R]
+	// ;B[qd];W[dc]
+	// )
+}
+
+func ExampleGameTree_GoverningGameInfo() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// two games, sharing their opening moves
+	game := "(;FF[4]GM[1]SZ[19];B[pd];W[dp]" +
+		"(;PB[Alpha]PW[Beta]DT[2014-03-26]RE[B+R];B[pp];W[dd])" +
+		"(;PB[Gamma]PW[Delta]DT[2014-03-27]KM[6.5]RE[W+3.5];B[dd]))"
+	prsr, errL := sgf.ParseFile("variations.sgf", []byte(game), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	gamT := &prsr.GameTree
+	fmt.Println("game-info nodes:", gamT.GameInfoNodes())
+	for _, l := range gamT.LeafGameInfos() {
+		gi := gamT.GameInfoAt(l.Leaf)
+		fmt.Printf("leaf %d: game-info at node %d, %s-%s %s %s KM %q\n", l.Leaf, l.GameInfo, gi.PB, gi.PW, gi.DT, gi.RE, gi.KM)
+	}
+	fmt.Println("opening governed by node", gamT.GoverningGameInfo(4))
+	fmt.Printf("cached: PB %q, PW %q\n", gamT.GetPB(), gamT.GetPW())
+	fmt.Printf("CheckGameInfo: %q\n", gamT.CheckGameInfo())
+	gi := gamT.GetGameInfo(2)
+	gi.GN = "Opening"
+	gamT.SetGameInfo(gi)
+	fmt.Printf("CheckGameInfo: %q\n", gamT.CheckGameInfo())
+	// a value that cannot be parsed, off the main line
+	gi = gamT.GetGameInfo(8)
+	gi.KM = "x"
+	fmt.Println("bad KM:", gamT.SetGameInfo(gi))
+	fmt.Printf("not changed: KM %q\n", gamT.GetGameInfo(8).KM)
+	// Output:
+	// game-info nodes: [2 5 8]
+	// leaf 7: game-info at node 5, Alpha-Beta 2014-03-26 B+R KM ""
+	// leaf 9: game-info at node 8, Gamma-Delta 2014-03-27 W+3.5 KM "6.5"
+	// opening governed by node 2
+	// cached: PB "Alpha", PW "Beta"
+	// CheckGameInfo: ""
+	// CheckGameInfo: "game-info at node 5 below game-info at node 2 game-info at node 8 below game-info at node 2 "
+	// bad KM: SetGameInfo: KM strconv.ParseFloat: parsing "x": invalid syntax
+	// not changed: KM "6.5"
+}
+
+func ExampleGameTree_SelectGame() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	coll := "(;FF[4]GM[1]SZ[19]PB[Alpha]PW[Beta]KM[6.5]RE[B+R];B[pd];W[dp])" +
+		"(;FF[4]GM[1]SZ[13]PB[Gamma]PW[Delta]HA[2]AB[jd][dj]RE[W+3];W[dd])"
+	prsr, errL := sgf.ParseFile("collection.sgf", []byte(coll), 0, 0)
+	if len(errL) != 0 {
+		fmt.Println("Error while parsing:", errL.Error())
+		return
+	}
+	gamT := &prsr.GameTree
+	show := func() {
+		c, r := gamT.GetSize()
+		res, _ := gamT.GetResult()
+		fmt.Printf("game %d of %d: %dx%d %s-%s HA %d %s\n", gamT.SelectedGame(), gamT.NumGames(),
+			c, r, gamT.GetPB(), gamT.GetPW(), gamT.GetHA(), res)
+	}
+	show()
+	fmt.Printf("game 1: PB %s\n", gamT.GetGame(1).GetPB())
+	gamT.SelectGame(1)
+	show()
+	i, errA := gamT.AppendGame(9, 9)
+	fmt.Println("appended game", i, len(errA))
+	gamT.MoveGame(2, 0)
+	show()
+	gamT.RemoveGame(1)
+	show()
+	fmt.Println("select 2:", gamT.SelectGame(2))
+	gamT.SelectGame(0)
+	show()
+
+	os.MkdirAll(OutDir, os.ModeDir|os.ModePerm)
+	fileName := OutDir + "/collection.sgf"
+	if err := gamT.WriteFile(fileName, sgf.DefaultNumPerLine); err != nil {
+		fmt.Println("Error writing:", err)
+		return
+	}
+	out, _ := ioutil.ReadFile(fileName)
+	fmt.Println(strings.TrimSpace(string(out)))
+	// Output:
+	// game 0 of 2: 19x19 Alpha-Beta HA 0 B+R
+	// game 1: PB Gamma
+	// game 1 of 2: 13x13 Gamma-Delta HA 2 W+3
+	// appended game 2 0
+	// game 2 of 3: 13x13 Gamma-Delta HA 2 W+3
+	// game 1 of 2: 13x13 Gamma-Delta HA 2 W+3
+	// select 2: SelectGame: no game 2 of 2
+	// game 0 of 2: 9x9 - HA 0 ?
+	// (;FF[4]GM[1]
+	// SZ[9]
+	//
+	// )
+	// (;FF[4]GM[1]
+	// SZ[13]
+	// PB[Gamma]
+	// PW[Delta]
+	// HA[2]
+	// AB[jd][dj]
+	// RE[W+3]
+	// ;W[dd]
+	// )
+}
+
+func ExampleGameTree_SelectGame_noSize() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// The second game has no SZ, so it is played on the default board, 19 by 19.
+	coll := "(;FF[4]GM[1]SZ[9];B[ee];W[cc])(;FF[4]GM[1];B[pd];W[dp])"
+	single := "(;FF[4]GM[1];B[pd];W[dp])"
+	for _, mode := range []sgf.ParserMode{0, sgf.ParserPlay} {
+		prsr, errL := sgf.ParseFile("nosize.sgf", []byte(coll), mode, 0)
+		prsr1, errL1 := sgf.ParseFile("single.sgf", []byte(single), mode, 0)
+		if len(errL) != 0 || len(errL1) != 0 {
+			fmt.Println("Error while parsing:", errL, errL1)
+			return
+		}
+		gamT := &prsr.GameTree
+		fmt.Println("mode", mode, "games:", gamT.NumGames(), "illegal moves:", len(prsr.MoveDiagnostics()))
+		// nodes 5, 6, and 7 are the game-info node, and the moves, of the second game
+		for n := sgf.TreeNodeIdx(0); n <= 2; n++ {
+			fmt.Printf("   node %d: same as the single game: %v\n", n+5, gamT.PositionHash(n+5) == prsr1.GameTree.PositionHash(n+2))
+		}
+	}
+	// Output:
+	// mode 0 games: 2 illegal moves: 0
+	//    node 5: same as the single game: true
+	//    node 6: same as the single game: true
+	//    node 7: same as the single game: true
+	// mode 4 games: 2 illegal moves: 0
+	//    node 5: same as the single game: true
+	//    node 6: same as the single game: true
+	//    node 7: same as the single game: true
+}
+
+func ExampleParseBatch() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	games := map[string]string{
+		"a.sgf": "(;FF[4]GM[1]SZ[19]PB[Go Seigen]PW[Kitani Minoru];B[pd];W[dp];B[pq])",
+		"b.sgf": "(;FF[4]GM[1]SZ[19]PB[Kitani Minoru]PW[Go Seigen];B[pd];W[dd])",
+		"c.sgf": "(;FF[4]GM[1]SZ[19]PB[Sakata Eio]PW[Go Seigen];B[qd])",
+	}
+	names := []string{"a.sgf", "b.sgf", "c.sgf"}
+	// put the games in a directory, a zip file, and a tar.gz file
+	dir := OutDir + "/batch"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir+"/games", os.ModeDir|os.ModePerm)
+	zf, _ := os.Create(dir + "/games.zip")
+	zw := zip.NewWriter(zf)
+	tf, _ := os.Create(dir + "/games.tar.gz")
+	gw := gzip.NewWriter(tf)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		ioutil.WriteFile(dir+"/games/"+name, []byte(games[name]), 0644)
+		w, _ := zw.Create(name)
+		w.Write([]byte(games[name]))
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(games[name])), Typeflag: tar.TypeReg})
+		tw.Write([]byte(games[name]))
+	}
+	zw.Close()
+	zf.Close()
+	tw.Close()
+	gw.Close()
+	tf.Close()
+
+	for _, src := range []string{dir + "/games", dir + "/games/[ab].sgf", dir + "/games/*.txt", dir + "/games.zip", dir + "/games.tar.gz"} {
+		// collect the statistics, and count the games with a callback
+		stats := sgf.NewDBStatistics()
+		var mu sync.Mutex
+		nGames := 0
+		res, errB := sgf.ParseBatch(context.Background(), src, sgf.BatchOptions{Workers: 2, Stats: stats},
+			func(file string, prsr *sgf.Parser) error {
+				mu.Lock()
+				nGames += 1
+				mu.Unlock()
+				return nil
+			})
+		fmt.Println(strings.TrimPrefix(src, dir+"/")+":", res.Files, "files,", res.Failed, "failed,", nGames, "callbacks,",
+			stats.BWPlayer_map["Go Seigen"].NGames, "games by Go Seigen, error:", errB)
+	}
+	// collect the games, without a callback
+	res, _ := sgf.ParseBatch(context.Background(), dir+"/games.zip", sgf.BatchOptions{}, nil)
+	for _, g := range res.Games {
+		fmt.Println(strings.TrimPrefix(g.File, dir+"/"), string(g.Parser.GameTree.GetPB()))
+	}
+	// a cancelled context stops the batch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, errB := sgf.ParseBatch(ctx, dir+"/games", sgf.BatchOptions{}, nil)
+	fmt.Println("cancelled:", res.Files, "files, error:", errB)
+	// Output:
+	// games: 3 files, 0 failed, 3 callbacks, 3 games by Go Seigen, error: <nil>
+	// games/[ab].sgf: 2 files, 0 failed, 2 callbacks, 2 games by Go Seigen, error: <nil>
+	// games/*.txt: 0 files, 0 failed, 0 callbacks, 0 games by Go Seigen, error: ./testout/batch/games/*.txt: no matching files
+	// games.zip: 3 files, 0 failed, 3 callbacks, 3 games by Go Seigen, error: <nil>
+	// games.tar.gz: 3 files, 0 failed, 3 callbacks, 3 games by Go Seigen, error: <nil>
+	// games.zip/a.sgf Go Seigen
+	// games.zip/b.sgf Kitani Minoru
+	// games.zip/c.sgf Sakata Eio
+	// cancelled: 0 files, error: context canceled
+}
+
+// Many goroutines parse, write, and read back games at once.
+// Run with "go test -race" to check that they share no mutable state.
+func ExampleParseFileStats_concurrent() {
+	const nGoroutines = 8
+	const nGames = 10
+	const game = "(;FF[4]GM[1]SZ[19]PB[Go Seigen]BR[9d]PW[Kitani Minoru]WR[9d]RE[B+R]C[comment];B[pd];W[dp](;B[pq];W[dd])(;B[dd]))"
+	dir := OutDir + "/concurrent"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, os.ModeDir|os.ModePerm)
+	stats := sgf.NewDBStatistics()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	nSame := 0
+	for g := 0; g < nGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			// every goroutine sets up the properties, only one reads the file
+			if sgf.SetupSGFProperties(defaultSpecFile, false, false) != 0 {
+				fmt.Println("Can't read Specification file:", defaultSpecFile)
+				return
+			}
+			for i := 0; i < nGames; i++ {
+				name := dir + "/g" + strconv.Itoa(g) + "_" + strconv.Itoa(i) + ".sgf"
+				prsr, errL := sgf.ParseFileStats(name, game, sgf.ParserPlay, 0, stats)
+				if len(errL) != 0 {
+					fmt.Println("Error while parsing:", errL.Error())
+					return
+				}
+				errW := prsr.GameTree.WriteFile(name, sgf.DefaultNumPerLine)
+				if errW != nil {
+					fmt.Println(errW)
+					return
+				}
+				prsr2, errL := sgf.ParseFile(name, nil, 0, 0)
+				if len(errL) != 0 {
+					fmt.Println("Error while reading back:", errL.Error())
+					return
+				}
+				if string(prsr2.GameTree.GetPB()) == "Go Seigen" {
+					mu.Lock()
+					nSame += 1
+					mu.Unlock()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	fmt.Println("games read back:", nSame)
+	fmt.Println("games by Go Seigen:", stats.BWPlayer_map["Go Seigen"].NGames)
+	// Output:
+	// games read back: 80
+	// games by Go Seigen: 80
+}
+
+func ExampleGameTree_CheckProperties_result() {
+	err := sgf.SetupSGFProperties(defaultSpecFile, false, false)
+	if err != 0 {
+		fmt.Println("Can't read Specification file:", defaultSpecFile)
+		return
+	}
+	// The margin of RE must have the fraction of KM, and be a multiple of 0.5,
+	// except for a seki with the Ing rules.
+	for _, inf := range []string{"KM[6.5]RE[W+1.5]", "KM[6.5]RE[W+2]", "KM[-0.5]RE[B+0.5]", "KM[-0.5]RE[W+1]",
+		"KM[0]RE[B+3]", "KM[8]RU[Ing]RE[W+6.6]", "KM[8]RE[B+1.83]RU[Ing]",
+		"KM[5.5]RU[Japanese]RE[B+1.55]"} {
+		game := "(;FF[4]GM[1]SZ[9]" + inf + ";B[ee])"
+		stats := sgf.NewDBStatistics()
+		prsr, errL := sgf.ParseFileStats(inf, []byte(game), 0, 0, stats)
+		if len(errL) != 0 {
+			fmt.Println("Error while parsing:", errL.Error())
+			continue
+		}
+		fmt.Printf("%s: %q\n", inf, prsr.GameTree.CheckProperties(false))
+	}
+	// Output:
+	// KM[6.5]RE[W+1.5]: ""
+	// KM[6.5]RE[W+2]: "RE margin not consistent with KM "
+	// KM[-0.5]RE[B+0.5]: ""
+	// KM[-0.5]RE[W+1]: "RE margin not consistent with KM "
+	// KM[0]RE[B+3]: ""
+	// KM[8]RU[Ing]RE[W+6.6]: ""
+	// KM[8]RE[B+1.83]RU[Ing]: ""
+	// KM[5.5]RU[Japanese]RE[B+1.55]: "RE margin not a multiple of 0.5 "
+}
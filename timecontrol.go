@@ -0,0 +1,482 @@
+/*
+ *  File:		src/github.com/Ken1JF/sgf/timecontrol.go
+ *  Project:	abst-hier
+ *
+ *  Created by Ken Friedenbach on 3/18/2014.
+ *  Copyright 2014 Ken Friedenbach. All rights reserved.
+ *
+ *	This file implements the time controls of games, as found in TM and OT
+ *	properties, the clocks recorded by BL, WL, OB, and OW, and a report of
+ *	the time used for each move.
+ */
+
+package sgf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/Ken1JF/ah"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OvertimeKind is the kind of overtime that follows the main time.
+type OvertimeKind uint8
+
+const (
+	OvertimeUnknown  OvertimeKind = iota // no OT, or not understood
+	OvertimeAbsolute                     // sudden death: no overtime
+	OvertimeByoYomi                      // Periods periods of PeriodTime, reset after each move
+	OvertimeCanadian                     // Stones moves in each PeriodTime
+	OvertimeFischer                      // Increment added after each move
+)
+
+var OvertimeKindNames = []string{"unknown", "absolute", "byo-yomi", "Canadian", "Fischer"}
+
+// A TimeControl is the time limit of a game, for each player, in seconds.
+//	Main is the main time, from TM. MainSet is false if there is no TM.
+//	Overtime, and the fields for each kind, are from OT.
+type TimeControl struct {
+	Main       float64
+	MainSet    bool
+	Overtime   OvertimeKind
+	Periods    int     // byo-yomi periods
+	PeriodTime float64 // time of each byo-yomi, or Canadian, period
+	Stones     int     // moves in each Canadian period
+	Increment  float64 // Fischer increment
+}
+
+// timeUnits are the words for units of time, in seconds.
+var timeUnits = map[string]float64{
+	"h": 3600, "hr": 3600, "hrs": 3600, "hour": 3600, "hours": 3600,
+	"m": 60, "min": 60, "mins": 60, "minute": 60, "minutes": 60,
+	"s": 1, "sec": 1, "secs": 1, "second": 1, "seconds": 1,
+}
+
+// A timeTok is a number, a word (in lower case), or one of the symbols: / : +
+type timeTok struct {
+	num   float64
+	isNum bool
+	word  string
+}
+
+// timeTokens breaks s into numbers, words, and symbols. Other characters separate tokens.
+func timeTokens(s string) (toks []timeTok) {
+	s = strings.ToLower(s)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' ||
+				s[j] == '.' && j+1 < len(s) && s[j+1] >= '0' && s[j+1] <= '9') {
+				j += 1
+			}
+			n, _ := strconv.ParseFloat(s[i:j], 64)
+			toks = append(toks, timeTok{num: n, isNum: true})
+			i = j
+		case c >= 'a' && c <= 'z':
+			j := i
+			for j < len(s) && s[j] >= 'a' && s[j] <= 'z' {
+				j += 1
+			}
+			toks = append(toks, timeTok{word: s[i:j]})
+			i = j
+		case c == '/' || c == ':' || c == '+':
+			toks = append(toks, timeTok{word: s[i : i+1]})
+			i += 1
+		default:
+			i += 1
+		}
+	}
+	return toks
+}
+
+// durationAt parses a duration, starting at toks[i], such as: 90, 1h 30m,
+// 1 hour 30 minutes, or 10:00 (minutes and seconds, or hours, minutes, and seconds).
+// A number without a unit is in seconds.
+// returns the duration in seconds, the index of the next token, and false if there is no duration.
+func durationAt(toks []timeTok, i int) (secs float64, next int, ok bool) {
+	if i >= len(toks) || !toks[i].isNum {
+		return 0, i, false
+	}
+	// a clock: mm:ss, or hh:mm:ss
+	if i+2 < len(toks) && toks[i+1].word == ":" && toks[i+2].isNum {
+		secs, next = toks[i].num, i+1
+		for n := 0; n < 2 && next+1 < len(toks) && toks[next].word == ":" && toks[next+1].isNum; n++ {
+			secs = 60*secs + toks[next+1].num
+			next += 2
+		}
+		return secs, next, true
+	}
+	next = i
+	for next < len(toks) && toks[next].isNum {
+		if next+1 < len(toks) {
+			if u, found := timeUnits[toks[next+1].word]; found {
+				secs += toks[next].num * u
+				next += 2
+				continue
+			}
+		}
+		if next == i {
+			// a number without a unit
+			return toks[next].num, next + 1, true
+		}
+		break
+	}
+	return secs, next, true
+}
+
+// hasWord returns true if one of the words is in toks.
+func hasWord(toks []timeTok, words ...string) bool {
+	for _, t := range toks {
+		for _, w := range words {
+			if t.word == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseTimeControl parses the values of the TM and OT properties.
+// Either may be "". TM is a number of seconds, or a free-text duration,
+// such as "1h 30m", "90 min each", or "1h sudden death".
+// OT is free text, in one of the common forms:
+//	5x30 byo-yomi, 5x30s, 3 periods of 30 seconds, 30s byo-yomi
+//	25/600 Canadian, 25 moves in 10 min, 25 stones/10:00
+//	10s Fischer, Fischer +10 sec, 10 second increment
+//	sudden death, absolute, none
+func ParseTimeControl(tm string, ot string) (tc TimeControl, err error) {
+	if strings.TrimSpace(tm) != "" {
+		toks := timeTokens(tm)
+		secs, next, ok := durationAt(toks, 0)
+		if !ok {
+			return tc, errors.New("TM \"" + tm + "\": no time")
+		}
+		tc.Main, tc.MainSet = secs, true
+		rest := toks[next:]
+		switch {
+		case len(rest) == 0 || hasWord(rest, "each", "per", "player"):
+		case hasWord(rest, "sudden", "absolute"):
+			tc.Overtime = OvertimeAbsolute
+		default:
+			if strings.TrimSpace(ot) == "" {
+				// the overtime follows the main time, as in "1h + 5x30 byo-yomi"
+				if rest[0].word == "+" {
+					rest = rest[1:]
+				}
+				if err := tc.parseOvertime(rest); err != nil {
+					return tc, errors.New("TM \"" + tm + "\": " + err.Error())
+				}
+			} else {
+				return tc, errors.New("TM \"" + tm + "\": unexpected text")
+			}
+		}
+	}
+	if strings.TrimSpace(ot) != "" {
+		if err := tc.parseOvertime(timeTokens(ot)); err != nil {
+			return tc, errors.New("OT \"" + ot + "\": " + err.Error())
+		}
+	}
+	return tc, nil
+}
+
+// parseOvertime sets the overtime of tc from the tokens of an OT value.
+func (tc *TimeControl) parseOvertime(toks []timeTok) error {
+	// the first duration
+	firstDur := func() (float64, bool) {
+		for i := range toks {
+			if secs, _, ok := durationAt(toks, i); ok {
+				return secs, true
+			}
+		}
+		return 0, false
+	}
+	switch {
+	case hasWord(toks, "fischer", "increment", "bonus"):
+		secs, ok := firstDur()
+		if !ok {
+			return errors.New("no increment")
+		}
+		tc.Overtime, tc.Increment = OvertimeFischer, secs
+
+	case hasWord(toks, "canadian", "moves", "stones", "/"):
+		// the number of stones, then the time
+		for i := 0; i < len(toks); i++ {
+			if !toks[i].isNum {
+				continue
+			}
+			j := i + 1
+			for j < len(toks) && !toks[j].isNum {
+				j += 1
+			}
+			secs, _, ok := durationAt(toks, j)
+			if !ok {
+				break
+			}
+			tc.Overtime, tc.Stones, tc.PeriodTime = OvertimeCanadian, int(toks[i].num), secs
+			return nil
+		}
+		return errors.New("no stones and time")
+
+	case hasWord(toks, "byo", "byoyomi", "x", "period", "periods"):
+		tc.Overtime, tc.Periods = OvertimeByoYomi, 1
+		for i := 0; i+1 < len(toks); i++ {
+			// 5x30, or 5 periods of 30s
+			if toks[i].isNum && (toks[i+1].word == "x" || toks[i+1].word == "period" || toks[i+1].word == "periods") {
+				j := i + 2
+				for j < len(toks) && !toks[j].isNum {
+					j += 1
+				}
+				secs, _, ok := durationAt(toks, j)
+				if !ok {
+					return errors.New("no period time")
+				}
+				tc.Periods, tc.PeriodTime = int(toks[i].num), secs
+				return nil
+			}
+		}
+		secs, ok := firstDur()
+		if !ok {
+			return errors.New("no period time")
+		}
+		tc.PeriodTime = secs
+
+	case hasWord(toks, "sudden", "absolute", "none", "no"):
+		tc.Overtime = OvertimeAbsolute
+
+	default:
+		return errors.New("unknown overtime")
+	}
+	return nil
+}
+
+// formatSeconds writes a number of seconds in hours, minutes, and seconds, such as 1h30m, or 12.5s.
+func formatSeconds(secs float64) string {
+	if secs == 0 {
+		return "0s"
+	}
+	s := ""
+	if h := int(secs / 3600); h > 0 {
+		s += strconv.Itoa(h) + "h"
+		secs -= float64(h) * 3600
+	}
+	if m := int(secs / 60); m > 0 {
+		s += strconv.Itoa(m) + "m"
+		secs -= float64(m) * 60
+	}
+	if secs > 0.0005 {
+		s += strconv.FormatFloat(secs, 'f', -1, 64) + "s"
+	}
+	return s
+}
+
+// String writes the time control, such as "1h, 5x30s byo-yomi", or "10m, 25/5m Canadian".
+func (tc TimeControl) String() string {
+	s := ""
+	if tc.MainSet {
+		s = formatSeconds(tc.Main)
+	}
+	ot := ""
+	switch tc.Overtime {
+	case OvertimeAbsolute:
+		ot = "absolute"
+	case OvertimeByoYomi:
+		ot = strconv.Itoa(tc.Periods) + "x" + formatSeconds(tc.PeriodTime) + " byo-yomi"
+	case OvertimeCanadian:
+		ot = strconv.Itoa(tc.Stones) + "/" + formatSeconds(tc.PeriodTime) + " Canadian"
+	case OvertimeFischer:
+		ot = formatSeconds(tc.Increment) + " Fischer"
+	}
+	if s != "" && ot != "" {
+		s += ", "
+	}
+	return s + ot
+}
+
+// GetTimeControl returns the time control of the selected game, from its TM and OT properties.
+func (gamT *GameTree) GetTimeControl() (tc TimeControl, err error) {
+	gi := gamT.selectedGameNode()
+	if gi == nilTreeNodeIdx {
+		return tc, nil
+	}
+	return ParseTimeControl(gamT.propString(gi, TM_idx), gamT.propString(gi, OT_idx))
+}
+
+// propString returns the value of the first property of type typ at node n, or "".
+func (gamT *GameTree) propString(n TreeNodeIdx, typ PropertyDefIdx) string {
+	pIdx := gamT.findProp(n, typ)
+	if pIdx == nilPropIdx {
+		return ""
+	}
+	return string(gamT.propertyValues[pIdx].StrValue)
+}
+
+// A MoveClock is the clock of a player after a move.
+//	TimeLeft is from BL or WL, and TimeSet is false if there is none.
+//	Stones is from OB or OW: the moves left in a Canadian period, or the
+//	byo-yomi periods left. StonesSet is false if there is none, which
+//	means the player is still in the main time.
+//	Used is the time used for the move, and UsedSet is false if it is not known.
+type MoveClock struct {
+	Node      TreeNodeIdx
+	Move      int // the move number, from 1
+	Color     ah.PointStatus
+	TimeLeft  float64
+	TimeSet   bool
+	Stones    int
+	StonesSet bool
+	Used      float64
+	UsedSet   bool
+}
+
+// ClockPath returns the clocks of the moves along a path of nodes,
+// with the time used for each move, computed using the time control tc.
+func (gamT *GameTree) ClockPath(path []TreeNodeIdx, tc TimeControl) (clocks []MoveClock) {
+	var last [2]MoveClock // the last clock of each player
+	var hasLast [2]bool
+	nMoves := 0
+	for _, n := range path {
+		mov, ok := gamT.nodeMove(n)
+		if !ok {
+			continue
+		}
+		nMoves += 1
+		mc := MoveClock{Node: n, Move: nMoves, Color: mov.colr}
+		tIdx, sIdx := BL_idx, OB_idx
+		if mov.colr == ah.White {
+			tIdx, sIdx = WL_idx, OW_idx
+		}
+		if v := gamT.propString(n, tIdx); v != "" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				mc.TimeLeft, mc.TimeSet = f, true
+			}
+		}
+		if v := gamT.propString(n, sIdx); v != "" {
+			if i, err := strconv.Atoi(v); err == nil {
+				mc.Stones, mc.StonesSet = i, true
+			}
+		}
+		c := 0
+		if mov.colr == ah.White {
+			c = 1
+		}
+		if mc.TimeSet {
+			if hasLast[c] {
+				mc.Used, mc.UsedSet = tc.used(&last[c], &mc)
+			} else {
+				mc.Used, mc.UsedSet = tc.used(nil, &mc)
+			}
+			last[c], hasLast[c] = mc, true
+		}
+		clocks = append(clocks, mc)
+	}
+	return clocks
+}
+
+// used returns the time used for the move with clock cur, after the player's
+// previous clock prev (nil for the first move of the player), and false if it
+// is not known.
+func (tc TimeControl) used(prev *MoveClock, cur *MoveClock) (float64, bool) {
+	var u float64
+	switch {
+	case prev == nil && !tc.MainSet:
+		return 0, false
+	case prev == nil && !cur.StonesSet:
+		u = tc.Main - cur.TimeLeft
+		if tc.Overtime == OvertimeFischer {
+			u += tc.Increment
+		}
+	case prev == nil:
+		// the main time was used, and the player is in overtime
+		u = tc.Main + tc.overtimeUsed(tc.Periods, cur)
+	case tc.Overtime == OvertimeFischer:
+		u = prev.TimeLeft + tc.Increment - cur.TimeLeft
+	case !cur.StonesSet:
+		u = prev.TimeLeft - cur.TimeLeft
+	case !prev.StonesSet:
+		// the rest of the main time, and some of the overtime
+		u = prev.TimeLeft + tc.overtimeUsed(tc.Periods, cur)
+	case tc.Overtime == OvertimeByoYomi:
+		u = tc.overtimeUsed(prev.Stones, cur)
+	case tc.Overtime == OvertimeCanadian && prev.Stones == 0:
+		// a new period
+		u = tc.PeriodTime - cur.TimeLeft
+	default:
+		u = prev.TimeLeft - cur.TimeLeft
+	}
+	if u < 0 {
+		return 0, false
+	}
+	return u, true
+}
+
+// overtimeUsed returns the overtime used by a byo-yomi, or Canadian, move with clock cur,
+// which started with periods byo-yomi periods left, and the time of a full period.
+func (tc TimeControl) overtimeUsed(periods int, cur *MoveClock) float64 {
+	u := tc.PeriodTime - cur.TimeLeft
+	if tc.Overtime == OvertimeByoYomi && periods > cur.Stones {
+		u += float64(periods-cur.Stones) * tc.PeriodTime
+	}
+	return u
+}
+
+// MainLineClock returns the clocks of the moves of the main line of the selected game.
+func (gamT *GameTree) MainLineClock() (clocks []MoveClock, err error) {
+	tc, err := gamT.GetTimeControl()
+	var path []TreeNodeIdx
+	for _, mov := range gamT.mainLineMoves() {
+		path = append(path, mov.nod)
+	}
+	return gamT.ClockPath(path, tc), err
+}
+
+// WriteTimeUsage writes a report of the time used for each move of the main line,
+// and the total and average time used by each player.
+func (gamT *GameTree) WriteTimeUsage(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	tc, err := gamT.GetTimeControl()
+	if err != nil {
+		fmt.Fprintln(bw, "Time control:", err)
+	} else {
+		fmt.Fprintln(bw, "Time control:", tc)
+	}
+	var path []TreeNodeIdx
+	for _, mov := range gamT.mainLineMoves() {
+		path = append(path, mov.nod)
+	}
+	var total [2]float64
+	var nUsed [2]int
+	fmt.Fprintln(bw, "Move Color     Used     Left Stones")
+	for _, mc := range gamT.ClockPath(path, tc) {
+		c, colr := 0, "B"
+		if mc.Color == ah.White {
+			c, colr = 1, "W"
+		}
+		used, left, stones := "", "", ""
+		if mc.UsedSet {
+			used = strconv.FormatFloat(mc.Used, 'f', 1, 64)
+			total[c] += mc.Used
+			nUsed[c] += 1
+		}
+		if mc.TimeSet {
+			left = strconv.FormatFloat(mc.TimeLeft, 'f', 1, 64)
+		}
+		if mc.StonesSet {
+			stones = strconv.Itoa(mc.Stones)
+		}
+		line := fmt.Sprintf("%4d %5s %8s %8s %6s", mc.Move, colr, used, left, stones)
+		fmt.Fprintln(bw, strings.TrimRight(line, " "))
+	}
+	for c, name := range []string{"Black", "White"} {
+		if nUsed[c] > 0 {
+			fmt.Fprintf(bw, "%s: %d moves, %.1f seconds, %.1f per move\n", name, nUsed[c], total[c], total[c]/float64(nUsed[c]))
+		} else {
+			fmt.Fprintf(bw, "%s: no times\n", name)
+		}
+	}
+	return bw.Flush()
+}
@@ -80,19 +80,16 @@ type PropertyValue struct {
 	ValType  PropValueType
 }
 
-// A GameTree consists of two slices:
-// the first holds the tree/ADG Nodes
-// the second holds property values other than moves
-type GameTree struct {
+// A Game holds the state of one game of a collection: its board,
+// and the fields that cache its root and game-info properties.
+//	InfoNode is the GameInfoNode of the game.
+type Game struct {
 	ah.AbstHier
-	treeNodes      []TreeNode
-	propertyValues []PropertyValue // TODO: add an avail list for deleted properties
-	// for now, count and report
-	NumberOfDeletedProperties int
-	kM                        Komi
-	rU                        []byte  // Rules
-	rE                        Result  // Result
-	tM                        float32 // Timelimit
+	InfoNode TreeNodeIdx
+	kM       Komi
+	rU       []byte  // Rules
+	rE       Result  // Result
+	tM       float32 // Timelimit
 	// how the board was setup
 	aB ah.NodeLocList // Add Black
 	// not needed to check consistency:	aE ah.NodeLocList	// Add Empty
@@ -105,10 +102,6 @@ type GameTree struct {
 	pW []byte // Player White
 	wR []byte // White Rank
 	wT []byte // White Team
-	// TODO: can these two "helper" vaules, and parser logic now be removed?
-	// helper values for the parser
-	setFirstBRank bool
-	setFirstWRank bool
 	// info about the game record
 	fF []byte // File Format
 	sT []byte // Style
@@ -123,13 +116,55 @@ type GameTree struct {
 	aN []byte // Annotation
 	cP []byte // Copyright
 	oH []byte // Old Handicap
-	// drawing info
-	// TODO: implement SGF drawing?
-	// TODO: or remove these (currently) unused arrays
+	// drawing info, from all the nodes (see GetMarkup for the markup of each node)
 	aR [][2]ah.NodeLoc // Arrows
 	lN [][2]ah.NodeLoc // Lines
 }
 
+// A GameTree consists of two slices:
+// the first holds the tree/ADG Nodes
+// the second holds property values other than moves
+// The embedded Game is the selected game of the collection (see SelectGame):
+// the board, and the cached properties, of each game are kept in games.
+type GameTree struct {
+	Game
+	treeNodes      []TreeNode
+	propertyValues []PropertyValue // TODO: add an avail list for deleted properties
+	// for now, count and report
+	NumberOfDeletedProperties int
+	NumberOfAddedLabels       int      // LB properties added by DoAddLabels
+	nodeHashes                []uint64 // the position hash of each node, see PositionHash
+	// the games of the collection, in order; games[selGame] is out of date
+	// while it is selected, and its state is in Game.
+	games   []Game
+	selGame int
+	// name of the game, from the file name (see GameName)
+	srcName string
+	// statistics kept when the GameTree holds patterns
+	PatternOpts PatternOptions
+	patInfo     map[TreeNodeIdx]PatternInfo
+	patSources  []string       // names of the games the patterns came from
+	patSrcIdx   map[string]int // index of each name in patSources
+}
+
+// PatternInfo records statistics about a node in a pattern tree.
+//	Each time a game reaches the node, one of WinsB, WinsW, or WinsO is incremented,
+//	based on the RE property of the game. These are written as WB, WW, and WO.
+type PatternInfo struct {
+	Count int           // number of times the sequence ending at the node was seen
+	WinsB int           // number of those games won by Black
+	WinsW int           // number of those games won by White
+	WinsO int           // number of those games with another result (jigo, void, unknown)
+	Games []int         // the games in which it was seen, see GetPatternSource, written as WG
+	Conts []PatternCont // games that continue past the node, written as WC
+}
+
+// A PatternCont records a game that continues after a pattern node.
+type PatternCont struct {
+	Src  int // index of the source game, see GetPatternSource
+	Move int // the move number in the source game
+}
+
 // initGameTree needs to be called before the GameTree can be used
 func (gT *GameTree) initGameTree() {
 	// TODO: remove this? nilTreeNodeIdx has been changed to 0xFFFF, so 0 can be used...
@@ -139,6 +174,128 @@ func (gT *GameTree) initGameTree() {
 	//	_ := gT.AddAProp(0, pv)
 }
 
+// countPattern increments the count of times a pattern node has been reached
+//	src is the index of the source game, or -1 if not known
+//	win is the color of the winner, or ah.Unocc for other results
+func (gT *GameTree) countPattern(n TreeNodeIdx, src int, win ah.PointStatus) {
+	if gT.patInfo == nil {
+		gT.patInfo = make(map[TreeNodeIdx]PatternInfo, 100)
+	}
+	inf, _ := gT.patInfo[n]
+	inf.Count += 1
+	switch win {
+	case ah.Black:
+		inf.WinsB += 1
+	case ah.White:
+		inf.WinsW += 1
+	default:
+		inf.WinsO += 1
+	}
+	if src >= 0 {
+		nG := len(inf.Games)
+		if nG == 0 || inf.Games[nG-1] != src {
+			inf.Games = append(inf.Games, src)
+		}
+	}
+	gT.patInfo[n] = inf
+}
+
+// addPatternGame records that game src reached node n, when read from a file.
+func (gT *GameTree) addPatternGame(n TreeNodeIdx, src int) {
+	if gT.patInfo == nil {
+		gT.patInfo = make(map[TreeNodeIdx]PatternInfo, 100)
+	}
+	inf, _ := gT.patInfo[n]
+	for _, g := range inf.Games {
+		if g == src {
+			return
+		}
+	}
+	inf.Games = append(inf.Games, src)
+	gT.patInfo[n] = inf
+}
+
+// addPatternSource records the name of a game added to a pattern tree.
+// returns the index of the name, or -1 if name is empty
+func (gT *GameTree) addPatternSource(name string) int {
+	if name == "" {
+		return -1
+	}
+	if i, ok := gT.patSrcIdx[name]; ok {
+		return i
+	}
+	if gT.patSrcIdx == nil {
+		gT.patSrcIdx = make(map[string]int, 100)
+	}
+	nS := len(gT.patSources)
+	gT.patSources = append(gT.patSources, name)
+	gT.patSrcIdx[name] = nS
+	return nS
+}
+
+// setPatternWins sets one of the win counts of a pattern node, when read from a file.
+// The Count is the sum of the win counts.
+func (gT *GameTree) setPatternWins(n TreeNodeIdx, win ah.PointStatus, wins int) {
+	if gT.patInfo == nil {
+		gT.patInfo = make(map[TreeNodeIdx]PatternInfo, 100)
+	}
+	inf, _ := gT.patInfo[n]
+	switch win {
+	case ah.Black:
+		inf.WinsB = wins
+	case ah.White:
+		inf.WinsW = wins
+	default:
+		inf.WinsO = wins
+	}
+	inf.Count = inf.WinsB + inf.WinsW + inf.WinsO
+	gT.patInfo[n] = inf
+}
+
+// addPatternCont records that game src continues after node n, at move number mov.
+// Each game and move is recorded once.
+func (gT *GameTree) addPatternCont(n TreeNodeIdx, src int, mov int) {
+	if src < 0 {
+		return
+	}
+	if gT.patInfo == nil {
+		gT.patInfo = make(map[TreeNodeIdx]PatternInfo, 100)
+	}
+	inf, _ := gT.patInfo[n]
+	for _, cont := range inf.Conts {
+		if cont.Src == src && cont.Move == mov {
+			return
+		}
+	}
+	inf.Conts = append(inf.Conts, PatternCont{Src: src, Move: mov})
+	gT.patInfo[n] = inf
+}
+
+// GetPatternSource returns the name of source game i, from PatternInfo.Games
+func (gT *GameTree) GetPatternSource(i int) string {
+	if i < 0 || i >= len(gT.patSources) {
+		return ""
+	}
+	return gT.patSources[i]
+}
+
+// NumPatternSources returns the number of source games recorded in a pattern tree.
+func (gT *GameTree) NumPatternSources() int {
+	return len(gT.patSources)
+}
+
+// GetSourceName returns the name of the game, set from the file name by ParseFile.
+func (gT *GameTree) GetSourceName() string {
+	return gT.srcName
+}
+
+// GetPatternInfo returns the statistics recorded for a node of a pattern tree.
+// ok is false if nothing has been recorded for the node.
+func (gT *GameTree) GetPatternInfo(n TreeNodeIdx) (inf PatternInfo, ok bool) {
+	inf, ok = gT.patInfo[n]
+	return inf, ok
+}
+
 // TODO: add an avail list for deleted properties
 // for now count and report
 func (gT *GameTree) ReportDeletedProperties() {
@@ -157,8 +314,6 @@ func (gT *GameTree) AddToAvailProps(pidx PropIdx) {
 
 type TreeTraverseVisitFunc func(*GameTree, TreeNodeIdx)
 
-var NumberOfAddedLabels = 0
-
 func DoAddLabels(gamT *GameTree, nodIdx TreeNodeIdx) {
 	lastCh := gamT.treeNodes[nodIdx].Children
 	if lastCh != nilTreeNodeIdx {
@@ -238,7 +393,7 @@ func DoAddLabels(gamT *GameTree, nodIdx TreeNodeIdx) {
 				}
 				//add the LB property
 				_ = gamT.addProperty(pv, nodIdx)
-				NumberOfAddedLabels += 1
+				gamT.NumberOfAddedLabels += 1
 			}
 		}
 	}
@@ -471,6 +626,66 @@ func (gamT *GameTree) HasSiblings(nd TreeNodeIdx) (ret bool) {
 	return ret
 }
 
+// findProp returns the index of the first property of type typ at node n,
+// or nilPropIdx if there is none.
+func (gamT *GameTree) findProp(n TreeNodeIdx, typ PropertyDefIdx) PropIdx {
+	switch gamT.treeNodes[n].TNodType {
+	case BlackMoveNode, WhiteMoveNode, SequenceNode:
+		return nilPropIdx
+	}
+	tail := gamT.treeNodes[n].propListOrNodeLoc
+	if tail == nilPropIdx {
+		return nilPropIdx
+	}
+	pIdx := tail
+	for {
+		pIdx = gamT.propertyValues[pIdx].NextProp
+		if gamT.propertyValues[pIdx].PropType == typ {
+			return pIdx
+		}
+		if pIdx == tail {
+			break
+		}
+	}
+	return nilPropIdx
+}
+
+// removeProps removes the properties of type typ from node n.
+// The removed properties are put on the avail list.
+func (gamT *GameTree) removeProps(n TreeNodeIdx, typ PropertyDefIdx) {
+	gamT.dropHashes(typ)
+	switch gamT.treeNodes[n].TNodType {
+	case BlackMoveNode, WhiteMoveNode, SequenceNode:
+		return
+	}
+	tail := gamT.treeNodes[n].propListOrNodeLoc
+	if tail == nilPropIdx {
+		return
+	}
+	prev := tail
+	for {
+		cur := gamT.propertyValues[prev].NextProp
+		if gamT.propertyValues[cur].PropType == typ {
+			if cur == prev { // the only property
+				gamT.treeNodes[n].propListOrNodeLoc = nilPropIdx
+				gamT.AddToAvailProps(cur)
+				return
+			}
+			gamT.propertyValues[prev].NextProp = gamT.propertyValues[cur].NextProp
+			gamT.AddToAvailProps(cur)
+			if cur == tail { // the tail was the last one to check
+				gamT.treeNodes[n].propListOrNodeLoc = prev
+				return
+			}
+			continue
+		}
+		if cur == tail {
+			return
+		}
+		prev = cur
+	}
+}
+
 // addProperty appends the new property, and maintains a circular linked list
 func (gamT *GameTree) addProperty(pv PropertyValue, nd TreeNodeIdx) (err ah.ErrorList) {
 	cur_l := len(gamT.propertyValues)
@@ -495,7 +710,14 @@ func (gamT *GameTree) addProperty(pv PropertyValue, nd TreeNodeIdx) (err ah.Erro
 // AddAProp adds a property to a node.
 // AddAProp changes a BlackMoveNode or a WhiteMoveNode into an InteriorNode,
 // when a property is added, making the B or W property the first in the list.
+// The position hashes are computed again, if the property changes the positions.
 func (gamT *GameTree) AddAProp(n TreeNodeIdx, pv PropertyValue) (err ah.ErrorList) {
+	gamT.dropHashes(pv.PropType)
+	return gamT.addAProp(n, pv)
+}
+
+// addAProp is AddAProp, used by the Parser, which keeps the position hashes as it parses.
+func (gamT *GameTree) addAProp(n TreeNodeIdx, pv PropertyValue) (err ah.ErrorList) {
 	mov := gamT.treeNodes[n].propListOrNodeLoc
 	if gamT.treeNodes[n].TNodType == BlackMoveNode {
 		var movPV *PropertyValue = new(PropertyValue)
@@ -590,12 +812,14 @@ func (gamT *GameTree) FindChild(par TreeNodeIdx, mov ah.NodeLoc) (found TreeNode
 			var tail_p PropIdx = gamT.treeNodes[ch].propListOrNodeLoc
 			if tail_p != nilPropIdx {
 				p_idx = tail_p
-				// check for mov
-				checkMov()
-				for p_idx != tail_p && found == nilTreeNodeIdx {
+				// check each property, starting with the head of the list
+				for found == nilTreeNodeIdx {
 					p_idx = gamT.propertyValues[p_idx].NextProp
 					// check for mov
 					checkMov()
+					if p_idx == tail_p {
+						break
+					}
 				}
 			}
 		case BlackMoveNode, WhiteMoveNode:
@@ -619,3 +843,57 @@ func (gamT *GameTree) FindChild(par TreeNodeIdx, mov ah.NodeLoc) (found TreeNode
 	}
 	return found
 }
+
+// firstChild returns the first child of node n, or nilTreeNodeIdx if n has no children.
+func (gamT *GameTree) firstChild(n TreeNodeIdx) TreeNodeIdx {
+	lastCh := gamT.treeNodes[n].Children
+	if lastCh == nilTreeNodeIdx {
+		return nilTreeNodeIdx
+	}
+	return gamT.treeNodes[lastCh].NextSib
+}
+
+// A gameMove records a move found while walking a GameTree.
+type gameMove struct {
+	nod  TreeNodeIdx
+	loc  ah.NodeLoc
+	colr ah.PointStatus
+}
+
+// nodeMove returns the move at node n, and false if there is no move at n.
+// The moves of an S[] property are stored in SequenceNodes, and alternate
+// colors, starting with Black.
+func (gamT *GameTree) nodeMove(n TreeNodeIdx) (mov gameMove, ok bool) {
+	nod := gamT.treeNodes[n]
+	switch nod.TNodType {
+	case BlackMoveNode, WhiteMoveNode, InteriorNode:
+		nl, c, err := gamT.GetMove(nod)
+		if len(err) == 0 {
+			mov = gameMove{nod: n, loc: nl, colr: c}
+			ok = true
+		}
+	case SequenceNode:
+		c := ah.Black
+		par := nod.Parent
+		for par != nilTreeNodeIdx && gamT.treeNodes[par].TNodType == SequenceNode {
+			c = ah.OppositeColor(c)
+			par = gamT.treeNodes[par].Parent
+		}
+		mov = gameMove{nod: n, loc: ah.NodeLoc(nod.propListOrNodeLoc), colr: c}
+		ok = true
+	}
+	return mov, ok
+}
+
+// mainLineMoves returns the moves of the main line of the selected game in gamT.
+// The main line follows the first child of each node.
+func (gamT *GameTree) mainLineMoves() (movs []gameMove) {
+	n := gamT.selectedGameNode()
+	for n != nilTreeNodeIdx {
+		if mov, ok := gamT.nodeMove(n); ok {
+			movs = append(movs, mov)
+		}
+		n = gamT.firstChild(n)
+	}
+	return movs
+}